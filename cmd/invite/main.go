@@ -0,0 +1,62 @@
+// Command invite issues single-use invite codes for invite-only registration.
+//
+// Usage:
+//
+//	go run ./cmd/invite [-n count] [-ttl duration]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/example/notes-template/internal/config"
+	"github.com/example/notes-template/internal/database"
+	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/services"
+)
+
+func main() {
+	if err := run(); err != nil {
+		logging.Error("Application error", map[string]interface{}{"error": err.Error()})
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	count := flag.Int("n", 1, "number of invite codes to create")
+	ttl := flag.Duration("ttl", cfg.Auth.InviteTTL, "lifetime of each code (0 for no expiry)")
+	flag.Parse()
+
+	if *count < 1 {
+		return fmt.Errorf("-n must be at least 1")
+	}
+
+	db, err := database.NewPostgresDB(cfg.Database.DSN())
+	if err != nil {
+		return fmt.Errorf("connecting to postgres: %w", err)
+	}
+	defer db.Close()
+
+	inviteService := services.NewInviteService(services.NewPoolAdapter(db.Pool))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := 0; i < *count; i++ {
+		code, _, err := inviteService.Create(ctx, nil, *ttl)
+		if err != nil {
+			return err
+		}
+		fmt.Println(code)
+	}
+
+	return nil
+}
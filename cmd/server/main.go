@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"os"
@@ -15,6 +16,7 @@ import (
 	"github.com/example/notes-template/internal/logging"
 	"github.com/example/notes-template/internal/middleware"
 	"github.com/example/notes-template/internal/services"
+	"github.com/example/notes-template/internal/storage"
 )
 
 func main() {
@@ -83,19 +85,94 @@ func run() error {
 	userService := services.NewUserService(dbAdapter)
 	authService := services.NewAuthService(dbAdapter, redisAdapter)
 	emailService := services.NewEmailService(&cfg.Email, dbAdapter)
-	noteService := services.NewNoteService(dbAdapter)
+	noteEvents := services.NewNoteEvents(redisAdapter)
+	noteService := services.NewNoteService(dbAdapter, noteEvents)
+	tagService := services.NewTagService(dbAdapter, noteEvents)
+	notebookService := services.NewNotebookService(dbAdapter, noteEvents)
+	revisionService := services.NewRevisionService(dbAdapter, noteEvents)
+	shareService := services.NewShareService(dbAdapter, redisAdapter)
+	noteLinkService := services.NewNoteLinkService(dbAdapter, redisAdapter)
+	inviteService := services.NewInviteService(dbAdapter)
+	importService := services.NewImportService(dbAdapter, noteEvents)
+
+	var blobStore storage.BlobStore
+	switch cfg.Storage.Backend {
+	case "s3":
+		blobStore, err = storage.NewS3Store(storage.S3Options{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Bucket:    cfg.Storage.S3Bucket,
+			Region:    cfg.Storage.S3Region,
+			AccessKey: cfg.Storage.S3AccessKey,
+			SecretKey: cfg.Storage.S3SecretKey,
+			UseSSL:    cfg.Storage.S3UseSSL,
+			Prefix:    cfg.Storage.S3Prefix,
+		})
+	default:
+		blobStore, err = storage.NewLocalStore(cfg.Storage.LocalDir)
+	}
+	if err != nil {
+		return fmt.Errorf("creating blob store: %w", err)
+	}
+	attachmentService := services.NewAttachmentService(dbAdapter, blobStore, cfg.Notes.AttachmentMaxSize, cfg.Notes.AttachmentQuota)
+	registrationPolicy := services.NewRegistrationPolicy(cfg.Auth.RegistrationMode, cfg.Auth.AllowedDomains, inviteService)
+
+	challengeSecret := []byte(cfg.Challenge.Secret)
+	if len(challengeSecret) == 0 {
+		// Challenges issued by one instance are rejected by others and after restarts
+		challengeSecret = make([]byte, 32)
+		if _, err := rand.Read(challengeSecret); err != nil {
+			return fmt.Errorf("generating challenge secret: %w", err)
+		}
+		logger.Warn("CHALLENGE_SECRET is not set; using a random per-process secret")
+	}
+	challengeService := services.NewChallengeService(challengeSecret, cfg.Challenge.Difficulty, cfg.Challenge.TTL, redisAdapter)
+
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	// Stopping it also ends open event streams, which would hold up shutdown
+	go noteEvents.Run(jobsCtx)
+
+	var metricsCollectors []handlers.MetricsCollector
+	if cfg.Janitor.Enabled {
+		tasks := append(services.DefaultCleanupTasks(cfg.Janitor.Retention),
+			services.NoteRevisionCleanupTask(cfg.Notes.RevisionsKeep, cfg.Notes.RevisionsMaxAge),
+			services.NoteLinkCleanupTask(cfg.Janitor.Retention),
+			services.NoteImportCleanupTask(cfg.Janitor.Retention))
+		if cfg.Notes.TrashRetention > 0 {
+			tasks = append(tasks, services.TrashPurgeTask(cfg.Notes.TrashRetention))
+		}
+		janitor := services.NewJanitor(dbAdapter, redisAdapter, tasks, cfg.Janitor.Interval, cfg.Janitor.BatchSize)
+		metricsCollectors = append(metricsCollectors, janitor)
+		go janitor.Run(jobsCtx)
+		go attachmentService.RunBlobSweeper(jobsCtx, cfg.Janitor.Interval, cfg.Janitor.BatchSize)
+		logger.Info("Janitor started", map[string]interface{}{
+			"interval": cfg.Janitor.Interval.String(),
+		})
+	}
 
 	// Initialize handlers
 	healthHandler := handlers.NewHealthHandler(db, redisDB)
-	authHandler := handlers.NewAuthHandler(userService, authService, emailService, cfg.Server.Secure)
+	authHandler := handlers.NewAuthHandler(userService, authService, emailService, registrationPolicy, cfg)
+	challengeHandler := handlers.NewChallengeHandler(challengeService)
+	inviteHandler := handlers.NewInviteHandler(inviteService, cfg.Auth.RegistrationMode, cfg.Auth.InviteTTL)
 	noteHandler := handlers.NewNoteHandler(noteService)
+	tagHandler := handlers.NewTagHandler(tagService)
+	notebookHandler := handlers.NewNotebookHandler(notebookService)
+	revisionHandler := handlers.NewRevisionHandler(revisionService)
+	shareHandler := handlers.NewShareHandler(shareService)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService, cfg.Notes.AttachmentMaxSize)
+	importHandler := handlers.NewImportHandler(importService, cfg.Notes.ImportMaxSize)
+	noteEventHandler := handlers.NewNoteEventHandler(noteEvents)
 	pageHandler, err := handlers.NewPageHandler("web/templates")
 	if err != nil {
 		return fmt.Errorf("loading templates: %w", err)
 	}
+	noteLinkHandler := handlers.NewNoteLinkHandler(noteLinkService, pageHandler, cfg.Email.BaseURL)
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(authService, userService)
+	authMiddleware := middleware.NewAuthMiddleware(authService, userService, cfg.Auth.ReauthWindow, cfg.Auth.VerificationGrace)
 	csrfMiddleware := middleware.NewCSRFMiddleware(cfg.Server.Secure)
 	securityHeaders := middleware.NewSecurityHeaders(cfg.Server.Secure)
 	cacheControl := middleware.NewCacheControl()
@@ -104,6 +181,26 @@ func run() error {
 
 	requireAuth := authMiddleware.RequireAuth
 
+	// Sensitive account changes (password, email, deletion) also need a recent
+	// password or magic link confirmation
+	requireRecentAuth := func(next http.Handler) http.Handler {
+		return requireAuth(authMiddleware.RequireRecentAuth(next))
+	}
+
+	// Content-creating endpoints can require a verified email
+	requireVerified := requireAuth
+	if cfg.Auth.RequireVerified {
+		requireVerified = func(next http.Handler) http.Handler {
+			return requireAuth(authMiddleware.RequireVerified(next))
+		}
+	}
+
+	// Endpoints that create accounts or send email require a solved challenge
+	requireChallenge := func(next http.Handler) http.Handler { return next }
+	if cfg.Challenge.Enabled {
+		requireChallenge = middleware.NewProofOfWork(challengeService).Require
+	}
+
 	mux := http.NewServeMux()
 
 	// Health endpoints
@@ -111,28 +208,93 @@ func run() error {
 	mux.HandleFunc("GET /ready", healthHandler.Ready)
 	mux.HandleFunc("GET /live", healthHandler.Live)
 
+	// Metrics endpoint (only when a scrape token is configured)
+	if cfg.Server.MetricsToken != "" {
+		metricsHandler := handlers.NewMetricsHandler(cfg.Server.MetricsToken, metricsCollectors...)
+		mux.HandleFunc("GET /metrics", metricsHandler.Metrics)
+	}
+
 	// CSRF token endpoint
 	mux.Handle("GET /api/csrf", http.HandlerFunc(csrfMiddleware.GetToken))
 
 	// Auth endpoints
-	mux.Handle("POST /api/auth/register", http.HandlerFunc(authHandler.Register))
+	mux.Handle("GET /api/auth/challenge", http.HandlerFunc(challengeHandler.Issue))
+	mux.Handle("POST /api/auth/register", requireChallenge(http.HandlerFunc(authHandler.Register)))
 	mux.Handle("POST /api/auth/login", http.HandlerFunc(authHandler.Login))
 	mux.Handle("POST /api/auth/logout", requireAuth(http.HandlerFunc(authHandler.Logout)))
 	mux.Handle("GET /api/auth/me", requireAuth(http.HandlerFunc(authHandler.Me)))
-	mux.Handle("POST /api/auth/password", requireAuth(http.HandlerFunc(authHandler.ChangePassword)))
+	mux.Handle("POST /api/auth/password", requireRecentAuth(http.HandlerFunc(authHandler.ChangePassword)))
+	mux.Handle("POST /api/auth/reauthenticate", requireAuth(http.HandlerFunc(authHandler.Reauthenticate)))
 	mux.Handle("POST /api/auth/verify-email", http.HandlerFunc(authHandler.VerifyEmail))
 	mux.Handle("POST /api/auth/resend-verification", requireAuth(http.HandlerFunc(authHandler.ResendVerification)))
-	mux.Handle("POST /api/auth/magic-link", http.HandlerFunc(authHandler.MagicLink))
+	mux.Handle("POST /api/auth/magic-link", requireChallenge(http.HandlerFunc(authHandler.MagicLink)))
 	mux.Handle("GET /api/auth/magic-link/verify", http.HandlerFunc(authHandler.MagicLinkVerify))
-	mux.Handle("POST /api/auth/forgot-password", http.HandlerFunc(authHandler.ForgotPassword))
+	mux.Handle("POST /api/auth/forgot-password", requireChallenge(http.HandlerFunc(authHandler.ForgotPassword)))
 	mux.Handle("POST /api/auth/reset-password", http.HandlerFunc(authHandler.ResetPassword))
 
+	// Invite endpoints
+	mux.Handle("GET /api/invites", requireAuth(http.HandlerFunc(inviteHandler.List)))
+	mux.Handle("POST /api/invites", requireAuth(http.HandlerFunc(inviteHandler.Create)))
+
 	// Notes endpoints
 	mux.Handle("GET /api/notes", requireAuth(http.HandlerFunc(noteHandler.List)))
-	mux.Handle("POST /api/notes", requireAuth(http.HandlerFunc(noteHandler.Create)))
+	mux.Handle("GET /api/notes/search", requireAuth(http.HandlerFunc(noteHandler.Search)))
+	mux.Handle("GET /api/notes/trash", requireAuth(http.HandlerFunc(noteHandler.ListTrash)))
+	mux.Handle("DELETE /api/notes/trash", requireAuth(http.HandlerFunc(noteHandler.EmptyTrash)))
+	mux.Handle("GET /api/notes/shared", requireAuth(http.HandlerFunc(shareHandler.SharedWithMe)))
+	mux.Handle("POST /api/notes", requireVerified(http.HandlerFunc(noteHandler.Create)))
+	mux.Handle("POST /api/notes/bulk", requireVerified(http.HandlerFunc(noteHandler.Bulk)))
+	mux.Handle("POST /api/notes/import", requireVerified(http.HandlerFunc(importHandler.Import)))
+	mux.Handle("GET /api/notes/export", requireAuth(http.HandlerFunc(noteHandler.Export)))
+	mux.Handle("GET /api/notes/events", requireAuth(http.HandlerFunc(noteEventHandler.Stream)))
+	mux.Handle("GET /api/imports/{id}", requireAuth(http.HandlerFunc(importHandler.Get)))
 	mux.Handle("GET /api/notes/{id}", requireAuth(http.HandlerFunc(noteHandler.Get)))
-	mux.Handle("PUT /api/notes/{id}", requireAuth(http.HandlerFunc(noteHandler.Update)))
+	mux.Handle("PUT /api/notes/{id}", requireVerified(http.HandlerFunc(noteHandler.Update)))
+	mux.Handle("PATCH /api/notes/{id}", requireVerified(http.HandlerFunc(noteHandler.Patch)))
 	mux.Handle("DELETE /api/notes/{id}", requireAuth(http.HandlerFunc(noteHandler.Delete)))
+	mux.Handle("POST /api/notes/{id}/restore", requireAuth(http.HandlerFunc(noteHandler.Restore)))
+	mux.Handle("PUT /api/notes/{id}/pin", requireAuth(http.HandlerFunc(noteHandler.Pin)))
+	mux.Handle("DELETE /api/notes/{id}/pin", requireAuth(http.HandlerFunc(noteHandler.Unpin)))
+	mux.Handle("PUT /api/notes/{id}/archive", requireAuth(http.HandlerFunc(noteHandler.Archive)))
+	mux.Handle("DELETE /api/notes/{id}/archive", requireAuth(http.HandlerFunc(noteHandler.Unarchive)))
+
+	// Note history endpoints
+	mux.Handle("GET /api/notes/{id}/revisions", requireAuth(http.HandlerFunc(revisionHandler.List)))
+	mux.Handle("GET /api/notes/{id}/revisions/diff", requireAuth(http.HandlerFunc(revisionHandler.Diff)))
+	mux.Handle("GET /api/notes/{id}/revisions/{rev}", requireAuth(http.HandlerFunc(revisionHandler.Get)))
+	mux.Handle("POST /api/notes/{id}/revisions/{rev}/restore", requireVerified(http.HandlerFunc(revisionHandler.Restore)))
+
+	// Note sharing endpoints
+	mux.Handle("GET /api/notes/{id}/shares", requireAuth(http.HandlerFunc(shareHandler.List)))
+	mux.Handle("POST /api/notes/{id}/shares", requireVerified(http.HandlerFunc(shareHandler.Create)))
+	mux.Handle("DELETE /api/notes/{id}/shares/{user_id}", requireAuth(http.HandlerFunc(shareHandler.Revoke)))
+
+	// Note attachment endpoints
+	mux.Handle("GET /api/notes/{id}/attachments", requireAuth(http.HandlerFunc(attachmentHandler.List)))
+	mux.Handle("POST /api/notes/{id}/attachments", requireVerified(http.HandlerFunc(attachmentHandler.Upload)))
+	mux.Handle("GET /api/notes/{id}/attachments/{attachment_id}", requireAuth(http.HandlerFunc(attachmentHandler.Download)))
+	mux.Handle("DELETE /api/notes/{id}/attachments/{attachment_id}", requireAuth(http.HandlerFunc(attachmentHandler.Delete)))
+
+	// Public note link endpoints
+	mux.Handle("GET /api/notes/{id}/links", requireAuth(http.HandlerFunc(noteLinkHandler.List)))
+	mux.Handle("POST /api/notes/{id}/links", requireVerified(http.HandlerFunc(noteLinkHandler.Create)))
+	mux.Handle("DELETE /api/notes/{id}/links/{link_id}", requireAuth(http.HandlerFunc(noteLinkHandler.Revoke)))
+	mux.Handle("GET /s/{token}", http.HandlerFunc(noteLinkHandler.View))
+	mux.Handle("POST /s/{token}", http.HandlerFunc(noteLinkHandler.View))
+
+	// Tag endpoints
+	mux.Handle("GET /api/tags", requireAuth(http.HandlerFunc(tagHandler.List)))
+	mux.Handle("PUT /api/tags/{id}", requireAuth(http.HandlerFunc(tagHandler.Rename)))
+	mux.Handle("POST /api/tags/{id}/merge", requireAuth(http.HandlerFunc(tagHandler.Merge)))
+	mux.Handle("DELETE /api/tags/{id}", requireAuth(http.HandlerFunc(tagHandler.Delete)))
+
+	// Notebook endpoints
+	mux.Handle("GET /api/notebooks", requireAuth(http.HandlerFunc(notebookHandler.List)))
+	mux.Handle("POST /api/notebooks", requireVerified(http.HandlerFunc(notebookHandler.Create)))
+	mux.Handle("GET /api/notebooks/{id}", requireAuth(http.HandlerFunc(notebookHandler.Get)))
+	mux.Handle("PUT /api/notebooks/{id}", requireAuth(http.HandlerFunc(notebookHandler.Update)))
+	mux.Handle("POST /api/notebooks/{id}/move", requireAuth(http.HandlerFunc(notebookHandler.Move)))
+	mux.Handle("DELETE /api/notebooks/{id}", requireAuth(http.HandlerFunc(notebookHandler.Delete)))
 
 	// Static files
 	fs := http.FileServer(http.Dir("web/static"))
@@ -169,6 +331,7 @@ func run() error {
 	go func() {
 		<-quit
 		logger.Info("Server is shutting down...")
+		stopJobs()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -179,6 +342,10 @@ func run() error {
 				"error": err.Error(),
 			})
 		}
+		// Let queued auth emails finish and background imports record where
+		// they stopped before the database closes
+		authHandler.Wait()
+		importService.Stop()
 		close(done)
 	}()
 
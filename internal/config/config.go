@@ -5,13 +5,19 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	Email    EmailConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Email     EmailConfig
+	Auth      AuthConfig
+	Challenge ChallengeConfig
+	Janitor   JanitorConfig
+	Notes     NotesConfig
+	Storage   StorageConfig
 }
 
 type ServerConfig struct {
@@ -21,6 +27,7 @@ type ServerConfig struct {
 	Environment   string // "development", "production", "test"
 	Debug         bool
 	DebugMaxChars int
+	MetricsToken  string // Bearer token for GET /metrics; endpoint disabled when empty
 }
 
 type DatabaseConfig struct {
@@ -50,6 +57,57 @@ type EmailConfig struct {
 	SMTPPort int
 }
 
+type AuthConfig struct {
+	MagicLinkSignup  bool          // Create accounts for unknown emails on magic link verification
+	RegistrationMode string        // "open", "invite", "domains"
+	AllowedDomains   []string      // Email domains allowed to register in "domains" mode
+	InviteTTL        time.Duration // Lifetime of user-created invite codes
+	ReauthWindow     time.Duration // How long a password or magic link confirmation unlocks sensitive operations
+	// Register answers the same for new and existing emails and emails the
+	// existing account owner instead of returning 409
+	EnumerationSafeRegister bool
+	RequireVerified         bool          // Block content creation for unverified emails after the grace period
+	VerificationGrace       time.Duration // How long after sign-up unverified accounts keep full access
+}
+
+type ChallengeConfig struct {
+	Enabled    bool          // Require proof-of-work on register, magic link and forgot password
+	Difficulty int           // Leading zero bits required in the solution hash
+	TTL        time.Duration // How long an issued challenge stays valid
+	Secret     string        // HMAC key for signing challenges; random per process when empty
+}
+
+type JanitorConfig struct {
+	Enabled   bool
+	Interval  time.Duration // Time between cleanup cycles
+	BatchSize int           // Maximum rows deleted per statement
+	Retention time.Duration // How long expired or used rows are kept
+}
+
+type NotesConfig struct {
+	RevisionsKeep   int           // Revisions per note the janitor never prunes
+	RevisionsMaxAge time.Duration // Older revisions beyond RevisionsKeep are pruned
+	TrashRetention  time.Duration // Trashed notes are purged after this long; 0 keeps them
+
+	AttachmentMaxSize int64 // Largest single attachment in bytes
+	AttachmentQuota   int64 // Total attachment bytes each user may upload
+
+	ImportMaxSize int64 // Largest upload to POST /api/notes/import in bytes
+}
+
+type StorageConfig struct {
+	Backend  string // "local" or "s3"
+	LocalDir string // Directory for the local backend
+	// S3-compatible backend settings
+	S3Endpoint  string // Host and optional port, without a scheme
+	S3Bucket    string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	S3Prefix    string // Prepended to object names
+}
+
 func (d DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
@@ -70,6 +128,7 @@ func Load() (*Config, error) {
 			Environment:   getEnv("APP_ENV", "development"),
 			Debug:         getEnvBool("DEBUG", false),
 			DebugMaxChars: getEnvInt("DEBUG_LOG_MAX_CHARS", 8000),
+			MetricsToken:  getEnv("METRICS_TOKEN", ""),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -94,11 +153,102 @@ func Load() (*Config, error) {
 			SMTPHost:     getEnv("SMTP_HOST", "localhost"),
 			SMTPPort:     getEnvInt("SMTP_PORT", 1025),
 		},
-}
+		Auth: AuthConfig{
+			MagicLinkSignup:  getEnvBool("AUTH_MAGIC_LINK_SIGNUP", false),
+			RegistrationMode: strings.ToLower(getEnvNonEmpty("AUTH_REGISTRATION_MODE", "open")),
+			AllowedDomains:   getEnvList("AUTH_ALLOWED_DOMAINS"),
+			InviteTTL:        getEnvDuration("AUTH_INVITE_TTL", 7*24*time.Hour),
+			ReauthWindow:     getEnvDuration("AUTH_REAUTH_WINDOW", 10*time.Minute),
+
+			EnumerationSafeRegister: getEnvBool("AUTH_ENUMERATION_SAFE_REGISTER", false),
+			RequireVerified:         getEnvBool("AUTH_REQUIRE_VERIFIED", false),
+			VerificationGrace:       getEnvNonNegativeDuration("AUTH_VERIFICATION_GRACE", 24*time.Hour),
+		},
+		Challenge: ChallengeConfig{
+			Enabled:    getEnvBool("CHALLENGE_ENABLED", true),
+			Difficulty: getEnvInt("CHALLENGE_DIFFICULTY", 18),
+			TTL:        getEnvDuration("CHALLENGE_TTL", 5*time.Minute),
+			Secret:     getEnv("CHALLENGE_SECRET", ""),
+		},
+		Janitor: JanitorConfig{
+			Enabled:   getEnvBool("JANITOR_ENABLED", true),
+			Interval:  getEnvDuration("JANITOR_INTERVAL", time.Hour),
+			BatchSize: getEnvInt("JANITOR_BATCH_SIZE", 1000),
+			Retention: getEnvDuration("JANITOR_RETENTION", 24*time.Hour),
+		},
+		Notes: NotesConfig{
+			RevisionsKeep:   getEnvInt("NOTE_REVISIONS_KEEP", 50),
+			RevisionsMaxAge: getEnvNonNegativeDuration("NOTE_REVISIONS_MAX_AGE", 90*24*time.Hour),
+			TrashRetention:  getEnvNonNegativeDuration("NOTE_TRASH_RETENTION", 30*24*time.Hour),
+
+			AttachmentMaxSize: int64(getEnvInt("NOTE_ATTACHMENT_MAX_MB", 25)) << 20,
+			AttachmentQuota:   int64(getEnvInt("NOTE_ATTACHMENT_QUOTA_MB", 1024)) << 20,
+
+			ImportMaxSize: int64(getEnvInt("NOTE_IMPORT_MAX_MB", 20)) << 20,
+		},
+		Storage: StorageConfig{
+			Backend:     strings.ToLower(getEnvNonEmpty("STORAGE_BACKEND", "local")),
+			LocalDir:    getEnvNonEmpty("STORAGE_LOCAL_DIR", "data/blobs"),
+			S3Endpoint:  getEnv("S3_ENDPOINT", ""),
+			S3Bucket:    getEnv("S3_BUCKET", ""),
+			S3Region:    getEnv("S3_REGION", "us-east-1"),
+			S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+			S3UseSSL:    getEnvBool("S3_USE_SSL", true),
+			S3Prefix:    getEnv("S3_PREFIX", ""),
+		},
+	}
+
+	if err := cfg.Auth.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Challenge.Enabled && (cfg.Challenge.Difficulty < 1 || cfg.Challenge.Difficulty > 32) {
+		return nil, fmt.Errorf("invalid CHALLENGE_DIFFICULTY %d: must be between 1 and 32", cfg.Challenge.Difficulty)
+	}
+	if cfg.Notes.RevisionsKeep < 1 {
+		return nil, fmt.Errorf("invalid NOTE_REVISIONS_KEEP %d: must be at least 1", cfg.Notes.RevisionsKeep)
+	}
+	if cfg.Notes.AttachmentMaxSize < 1<<20 || cfg.Notes.AttachmentQuota < cfg.Notes.AttachmentMaxSize {
+		return nil, fmt.Errorf("invalid attachment limits: NOTE_ATTACHMENT_MAX_MB must be at least 1 and at most NOTE_ATTACHMENT_QUOTA_MB")
+	}
+	if cfg.Notes.ImportMaxSize < 1<<20 {
+		return nil, fmt.Errorf("invalid NOTE_IMPORT_MAX_MB %d: must be at least 1", cfg.Notes.ImportMaxSize>>20)
+	}
+	if err := cfg.Storage.validate(); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
 
+func (a AuthConfig) validate() error {
+	switch a.RegistrationMode {
+	case "open", "invite":
+		return nil
+	case "domains":
+		if len(a.AllowedDomains) == 0 {
+			return fmt.Errorf("AUTH_REGISTRATION_MODE=domains requires AUTH_ALLOWED_DOMAINS")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid AUTH_REGISTRATION_MODE %q: must be open, invite or domains", a.RegistrationMode)
+	}
+}
+
+func (s StorageConfig) validate() error {
+	switch s.Backend {
+	case "local":
+		return nil
+	case "s3":
+		if s.S3Endpoint == "" || s.S3Bucket == "" {
+			return fmt.Errorf("STORAGE_BACKEND=s3 requires S3_ENDPOINT and S3_BUCKET")
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid STORAGE_BACKEND %q: must be local or s3", s.Backend)
+	}
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -133,3 +283,33 @@ func getEnvBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvNonNegativeDuration is getEnvDuration for settings where zero is
+// meaningful, such as disabling a grace period.
+func getEnvNonNegativeDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoad_Defaults(t *testing.T) {
@@ -11,6 +13,11 @@ func TestLoad_Defaults(t *testing.T) {
 		"SERVER_HOST", "SERVER_PORT", "SERVER_SECURE", "DEBUG", "DEBUG_LOG_MAX_CHARS",
 		"DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE",
 		"REDIS_HOST", "REDIS_PORT", "REDIS_PASSWORD", "REDIS_DB",
+		"AUTH_MAGIC_LINK_SIGNUP", "AUTH_REGISTRATION_MODE", "AUTH_ALLOWED_DOMAINS", "AUTH_INVITE_TTL", "AUTH_REAUTH_WINDOW", "AUTH_ENUMERATION_SAFE_REGISTER",
+		"AUTH_REQUIRE_VERIFIED", "AUTH_VERIFICATION_GRACE",
+		"CHALLENGE_ENABLED", "CHALLENGE_DIFFICULTY", "CHALLENGE_TTL", "CHALLENGE_SECRET",
+		"JANITOR_ENABLED", "JANITOR_INTERVAL", "JANITOR_BATCH_SIZE", "JANITOR_RETENTION",
+		"NOTE_REVISIONS_KEEP", "NOTE_REVISIONS_MAX_AGE", "NOTE_TRASH_RETENTION",
 	}
 	for _, v := range envVars {
 		os.Unsetenv(v)
@@ -72,6 +79,84 @@ func TestLoad_Defaults(t *testing.T) {
 		t.Errorf("expected Redis.DB to be 0, got %d", cfg.Redis.DB)
 	}
 
+	// Auth defaults
+	if cfg.Auth.MagicLinkSignup != false {
+		t.Error("expected Auth.MagicLinkSignup to be false")
+	}
+	if cfg.Auth.RegistrationMode != "open" {
+		t.Errorf("expected Auth.RegistrationMode to be open, got %s", cfg.Auth.RegistrationMode)
+	}
+	if len(cfg.Auth.AllowedDomains) != 0 {
+		t.Errorf("expected no Auth.AllowedDomains, got %v", cfg.Auth.AllowedDomains)
+	}
+	if cfg.Auth.InviteTTL != 7*24*time.Hour {
+		t.Errorf("expected Auth.InviteTTL to be 168h, got %s", cfg.Auth.InviteTTL)
+	}
+	if cfg.Auth.ReauthWindow != 10*time.Minute {
+		t.Errorf("expected Auth.ReauthWindow to be 10m, got %s", cfg.Auth.ReauthWindow)
+	}
+	if cfg.Auth.EnumerationSafeRegister {
+		t.Error("expected Auth.EnumerationSafeRegister to be false")
+	}
+	if cfg.Auth.RequireVerified {
+		t.Error("expected Auth.RequireVerified to be false")
+	}
+	if cfg.Auth.VerificationGrace != 24*time.Hour {
+		t.Errorf("expected Auth.VerificationGrace to be 24h, got %s", cfg.Auth.VerificationGrace)
+	}
+
+	// Challenge defaults
+	if !cfg.Challenge.Enabled {
+		t.Error("expected Challenge.Enabled to be true")
+	}
+	if cfg.Challenge.Difficulty != 18 {
+		t.Errorf("expected Challenge.Difficulty to be 18, got %d", cfg.Challenge.Difficulty)
+	}
+	if cfg.Challenge.TTL != 5*time.Minute {
+		t.Errorf("expected Challenge.TTL to be 5m, got %s", cfg.Challenge.TTL)
+	}
+
+	// Janitor defaults
+	if cfg.Janitor.Enabled != true {
+		t.Error("expected Janitor.Enabled to be true")
+	}
+	if cfg.Janitor.Interval != time.Hour {
+		t.Errorf("expected Janitor.Interval to be 1h, got %s", cfg.Janitor.Interval)
+	}
+	if cfg.Janitor.BatchSize != 1000 {
+		t.Errorf("expected Janitor.BatchSize to be 1000, got %d", cfg.Janitor.BatchSize)
+	}
+	if cfg.Janitor.Retention != 24*time.Hour {
+		t.Errorf("expected Janitor.Retention to be 24h, got %s", cfg.Janitor.Retention)
+	}
+
+	// Notes defaults
+	if cfg.Notes.RevisionsKeep != 50 {
+		t.Errorf("expected Notes.RevisionsKeep to be 50, got %d", cfg.Notes.RevisionsKeep)
+	}
+	if cfg.Notes.RevisionsMaxAge != 90*24*time.Hour {
+		t.Errorf("expected Notes.RevisionsMaxAge to be 2160h, got %s", cfg.Notes.RevisionsMaxAge)
+	}
+	if cfg.Notes.TrashRetention != 30*24*time.Hour {
+		t.Errorf("expected Notes.TrashRetention to be 720h, got %s", cfg.Notes.TrashRetention)
+	}
+	if cfg.Notes.AttachmentMaxSize != 25<<20 {
+		t.Errorf("expected Notes.AttachmentMaxSize to be 25 MiB, got %d", cfg.Notes.AttachmentMaxSize)
+	}
+	if cfg.Notes.AttachmentQuota != 1024<<20 {
+		t.Errorf("expected Notes.AttachmentQuota to be 1 GiB, got %d", cfg.Notes.AttachmentQuota)
+	}
+	if cfg.Notes.ImportMaxSize != 20<<20 {
+		t.Errorf("expected Notes.ImportMaxSize to be 20 MiB, got %d", cfg.Notes.ImportMaxSize)
+	}
+
+	// Storage defaults
+	if cfg.Storage.Backend != "local" {
+		t.Errorf("expected Storage.Backend to be local, got %s", cfg.Storage.Backend)
+	}
+	if cfg.Storage.LocalDir != "data/blobs" {
+		t.Errorf("expected Storage.LocalDir to be data/blobs, got %s", cfg.Storage.LocalDir)
+	}
 }
 
 func TestLoad_CustomValues(t *testing.T) {
@@ -91,6 +176,7 @@ func TestLoad_CustomValues(t *testing.T) {
 	os.Setenv("REDIS_PORT", "6380")
 	os.Setenv("REDIS_PASSWORD", "redispass")
 	os.Setenv("REDIS_DB", "1")
+	os.Setenv("AUTH_MAGIC_LINK_SIGNUP", "true")
 
 	defer func() {
 		// Clean up
@@ -109,6 +195,7 @@ func TestLoad_CustomValues(t *testing.T) {
 		os.Unsetenv("REDIS_PORT")
 		os.Unsetenv("REDIS_PASSWORD")
 		os.Unsetenv("REDIS_DB")
+		os.Unsetenv("AUTH_MAGIC_LINK_SIGNUP")
 	}()
 
 	cfg, err := Load()
@@ -166,6 +253,11 @@ func TestLoad_CustomValues(t *testing.T) {
 	if cfg.Redis.DB != 1 {
 		t.Errorf("expected Redis.DB to be 1, got %d", cfg.Redis.DB)
 	}
+
+	// Auth values
+	if cfg.Auth.MagicLinkSignup != true {
+		t.Error("expected Auth.MagicLinkSignup to be true")
+	}
 }
 
 func TestLoad_InvalidIntFallsBackToDefault(t *testing.T) {
@@ -196,6 +288,62 @@ func TestLoad_InvalidBoolFallsBackToDefault(t *testing.T) {
 	}
 }
 
+func TestLoad_RegistrationPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		domains string
+		wantErr bool
+	}{
+		{name: "invite mode", mode: "invite"},
+		{name: "domains mode", mode: "Domains", domains: "example.com, example.org"},
+		{name: "domains mode without domains", mode: "domains", wantErr: true},
+		{name: "unknown mode", mode: "closed", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("AUTH_REGISTRATION_MODE", tt.mode)
+			os.Setenv("AUTH_ALLOWED_DOMAINS", tt.domains)
+			defer os.Unsetenv("AUTH_REGISTRATION_MODE")
+			defer os.Unsetenv("AUTH_ALLOWED_DOMAINS")
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cfg.Auth.RegistrationMode != strings.ToLower(tt.mode) {
+				t.Errorf("expected mode %s, got %s", strings.ToLower(tt.mode), cfg.Auth.RegistrationMode)
+			}
+			if tt.domains != "" && len(cfg.Auth.AllowedDomains) != 2 {
+				t.Errorf("expected 2 allowed domains, got %v", cfg.Auth.AllowedDomains)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidChallengeDifficulty(t *testing.T) {
+	os.Setenv("CHALLENGE_DIFFICULTY", "40")
+	defer os.Unsetenv("CHALLENGE_DIFFICULTY")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for out-of-range difficulty")
+	}
+
+	os.Setenv("CHALLENGE_ENABLED", "false")
+	defer os.Unsetenv("CHALLENGE_ENABLED")
+
+	if _, err := Load(); err != nil {
+		t.Fatalf("unexpected error with challenges disabled: %v", err)
+	}
+}
+
 func TestDatabaseConfig_DSN(t *testing.T) {
 	cfg := DatabaseConfig{
 		Host:     "localhost",
@@ -374,3 +522,139 @@ func TestGetEnvBool(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		envValue     string
+		defaultValue time.Duration
+		expected     time.Duration
+	}{
+		{
+			name:         "returns default when not set",
+			key:          "TEST_GET_ENV_DURATION_1",
+			envValue:     "",
+			defaultValue: time.Minute,
+			expected:     time.Minute,
+		},
+		{
+			name:         "returns parsed duration when set",
+			key:          "TEST_GET_ENV_DURATION_2",
+			envValue:     "90s",
+			defaultValue: time.Minute,
+			expected:     90 * time.Second,
+		},
+		{
+			name:         "returns default when invalid",
+			key:          "TEST_GET_ENV_DURATION_3",
+			envValue:     "soon",
+			defaultValue: time.Minute,
+			expected:     time.Minute,
+		},
+		{
+			name:         "returns default when not positive",
+			key:          "TEST_GET_ENV_DURATION_4",
+			envValue:     "0s",
+			defaultValue: time.Minute,
+			expected:     time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.envValue != "" {
+				os.Setenv(tt.key, tt.envValue)
+				defer os.Unsetenv(tt.key)
+			} else {
+				os.Unsetenv(tt.key)
+			}
+
+			got := getEnvDuration(tt.key, tt.defaultValue)
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGetEnvNonNegativeDuration(t *testing.T) {
+	tests := []struct {
+		name         string
+		envValue     string
+		defaultValue time.Duration
+		expected     time.Duration
+	}{
+		{name: "accepts zero", envValue: "0s", defaultValue: time.Hour, expected: 0},
+		{name: "accepts positive", envValue: "2h", defaultValue: time.Hour, expected: 2 * time.Hour},
+		{name: "returns default when negative", envValue: "-1h", defaultValue: time.Hour, expected: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Setenv("TEST_GET_ENV_NON_NEGATIVE_DURATION", tt.envValue)
+			defer os.Unsetenv("TEST_GET_ENV_NON_NEGATIVE_DURATION")
+
+			got := getEnvNonNegativeDuration("TEST_GET_ENV_NON_NEGATIVE_DURATION", tt.defaultValue)
+			if got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLoad_InvalidNoteRevisionsKeep(t *testing.T) {
+	os.Setenv("NOTE_REVISIONS_KEEP", "0")
+	defer os.Unsetenv("NOTE_REVISIONS_KEEP")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error when no revisions are kept")
+	}
+}
+
+func TestLoad_StorageBackend(t *testing.T) {
+	os.Setenv("STORAGE_BACKEND", "s3")
+	defer os.Unsetenv("STORAGE_BACKEND")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for s3 without an endpoint and bucket")
+	}
+
+	os.Setenv("S3_ENDPOINT", "minio:9000")
+	defer os.Unsetenv("S3_ENDPOINT")
+	os.Setenv("S3_BUCKET", "notes")
+	defer os.Unsetenv("S3_BUCKET")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Storage.Backend != "s3" || !cfg.Storage.S3UseSSL {
+		t.Errorf("expected s3 backend with SSL, got %+v", cfg.Storage)
+	}
+
+	os.Setenv("STORAGE_BACKEND", "ftp")
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for an unknown backend")
+	}
+}
+
+func TestLoad_InvalidAttachmentLimits(t *testing.T) {
+	os.Setenv("NOTE_ATTACHMENT_MAX_MB", "100")
+	defer os.Unsetenv("NOTE_ATTACHMENT_MAX_MB")
+	os.Setenv("NOTE_ATTACHMENT_QUOTA_MB", "50")
+	defer os.Unsetenv("NOTE_ATTACHMENT_QUOTA_MB")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error when one file may exceed the quota")
+	}
+}
+
+func TestLoad_InvalidImportMaxSize(t *testing.T) {
+	os.Setenv("NOTE_IMPORT_MAX_MB", "0")
+	defer os.Unsetenv("NOTE_IMPORT_MAX_MB")
+
+	if _, err := Load(); err == nil {
+		t.Fatal("expected error for NOTE_IMPORT_MAX_MB=0")
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+// multipartOverhead is room for the multipart boundaries and headers around
+// an upload of the largest allowed size.
+const multipartOverhead = 64 << 10
+
+// attachmentTransferTimeout replaces the server's read and write timeouts for
+// uploads and downloads, which are sized for JSON requests.
+const attachmentTransferTimeout = 10 * time.Minute
+
+type AttachmentHandler struct {
+	attachmentService services.AttachmentServiceInterface
+	maxSize           int64
+}
+
+// NewAttachmentHandler creates an attachment handler. maxSize should match
+// the service's per-file limit; larger request bodies are cut off early.
+func NewAttachmentHandler(attachmentService services.AttachmentServiceInterface, maxSize int64) *AttachmentHandler {
+	return &AttachmentHandler{attachmentService: attachmentService, maxSize: maxSize}
+}
+
+// Upload attaches the file in the "file" field of a multipart/form-data body
+// to a note. The stored content type is sniffed from the first 512 bytes and
+// the client's claim is ignored.
+func (h *AttachmentHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(attachmentTransferTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(attachmentTransferTimeout))
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+multipartOverhead)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.writeTooLarge(w)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Expected a multipart/form-data body")
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	defer file.Close()
+	if header.Size > h.maxSize {
+		h.writeTooLarge(w)
+		return
+	}
+
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		log.Printf("Error reading upload: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Error rewinding upload: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	attachment, err := h.attachmentService.Create(r.Context(), models.CreateAttachmentParams{
+		UserID:      user.ID,
+		NoteID:      noteID,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: http.DetectContentType(sniff[:n]),
+		Size:        header.Size,
+		Body:        file,
+	})
+	if err != nil {
+		switch err {
+		case services.ErrAttachmentTooLarge:
+			h.writeTooLarge(w)
+		case services.ErrAttachmentQuotaExceeded:
+			writeError(w, http.StatusRequestEntityTooLarge, "Attachment storage quota exceeded")
+		case services.ErrNoteNotFound:
+			writeError(w, http.StatusNotFound, "Note not found")
+		case services.ErrNoteForbidden:
+			writeError(w, http.StatusForbidden, "Only editors can change a shared note's attachments")
+		default:
+			log.Printf("Error creating attachment: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"attachment": attachment})
+}
+
+func (h *AttachmentHandler) writeTooLarge(w http.ResponseWriter) {
+	writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Attachments must be at most %d MB", h.maxSize>>20))
+}
+
+// List returns the attachments of a note the caller can read.
+func (h *AttachmentHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	attachments, err := h.attachmentService.ListByNote(r.Context(), user.ID, noteID)
+	if err != nil {
+		if err == services.ErrNoteNotFound {
+			writeError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error listing attachments: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"attachments": attachments})
+}
+
+// Download sends an attachment's contents. It is always served as a download
+// so uploaded HTML or SVG never runs on this origin, and supports Range and
+// If-Range for resumed downloads.
+func (h *AttachmentHandler) Download(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	attachmentID, err := uuid.Parse(r.PathValue("attachment_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid attachment id")
+		return
+	}
+
+	attachment, blob, err := h.attachmentService.Open(r.Context(), user.ID, noteID, attachmentID)
+	if err != nil {
+		if err == services.ErrAttachmentNotFound {
+			writeError(w, http.StatusNotFound, "Attachment not found")
+			return
+		}
+		log.Printf("Error opening attachment: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	defer blob.Close()
+
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(attachmentTransferTimeout))
+
+	// Attachments never change, so the id is a strong validator
+	w.Header().Set("ETag", `"`+attachment.ID.String()+`"`)
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	http.ServeContent(w, r, "", attachment.CreatedAt, blob)
+}
+
+// Delete removes an attachment from a note the caller can edit.
+func (h *AttachmentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	attachmentID, err := uuid.Parse(r.PathValue("attachment_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid attachment id")
+		return
+	}
+
+	if err := h.attachmentService.Delete(r.Context(), user.ID, noteID, attachmentID); err != nil {
+		switch err {
+		case services.ErrNoteNotFound:
+			writeError(w, http.StatusNotFound, "Note not found")
+		case services.ErrAttachmentNotFound:
+			writeError(w, http.StatusNotFound, "Attachment not found")
+		case services.ErrNoteForbidden:
+			writeError(w, http.StatusForbidden, "Only editors can change a shared note's attachments")
+		default:
+			log.Printf("Error deleting attachment: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Attachment deleted"})
+}
+
+// cleanFilename keeps the last path element of a client-supplied name, drops
+// control characters and limits it to 255 bytes.
+func cleanFilename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	for len(name) > 255 {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." {
+		return "attachment"
+	}
+	return name
+}
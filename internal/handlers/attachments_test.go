@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockAttachmentService struct {
+	create     func(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error)
+	listByNote func(ctx context.Context, userID, noteID uuid.UUID) ([]*models.Attachment, error)
+	open       func(ctx context.Context, userID, noteID, attachmentID uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error)
+	delete     func(ctx context.Context, userID, noteID, attachmentID uuid.UUID) error
+}
+
+func (m *mockAttachmentService) Create(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error) {
+	return m.create(ctx, params)
+}
+
+func (m *mockAttachmentService) ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.Attachment, error) {
+	return m.listByNote(ctx, userID, noteID)
+}
+
+func (m *mockAttachmentService) Open(ctx context.Context, userID, noteID, attachmentID uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error) {
+	return m.open(ctx, userID, noteID, attachmentID)
+}
+
+func (m *mockAttachmentService) Delete(ctx context.Context, userID, noteID, attachmentID uuid.UUID) error {
+	return m.delete(ctx, userID, noteID, attachmentID)
+}
+
+type nopSeekCloser struct{ io.ReadSeeker }
+
+func (nopSeekCloser) Close() error { return nil }
+
+func uploadRequest(t *testing.T, noteID uuid.UUID, filename, contentType string, content []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	header := make(map[string][]string)
+	header["Content-Disposition"] = []string{`form-data; name="file"; filename="` + filename + `"`}
+	header["Content-Type"] = []string{contentType}
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		t.Fatalf("CreatePart: %v", err)
+	}
+	_, _ = part.Write(content)
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/attachments", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.SetPathValue("id", noteID.String())
+	return req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+}
+
+func TestAttachmentHandler_Upload_SniffsContentType(t *testing.T) {
+	noteID := uuid.New()
+	var got models.CreateAttachmentParams
+	h := NewAttachmentHandler(&mockAttachmentService{
+		create: func(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error) {
+			got = params
+			body, _ := io.ReadAll(params.Body)
+			if int64(len(body)) != params.Size {
+				t.Fatalf("expected %d bytes from the start of the file, got %d", params.Size, len(body))
+			}
+			return &models.Attachment{ID: uuid.New(), NoteID: params.NoteID, Filename: params.Filename}, nil
+		},
+	}, 1<<20)
+
+	content := []byte("<html><body><script>alert(1)</script></body></html>")
+	rr := httptest.NewRecorder()
+	h.Upload(rr, uploadRequest(t, noteID, `C:\Users\me\report.html`, "image/png", content))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if got.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("expected the sniffed content type, got %q", got.ContentType)
+	}
+	if got.Filename != "report.html" {
+		t.Errorf("expected the base file name, got %q", got.Filename)
+	}
+	if got.NoteID != noteID {
+		t.Errorf("expected note %s, got %s", noteID, got.NoteID)
+	}
+}
+
+func TestAttachmentHandler_Upload_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		err     error
+		want    int
+	}{
+		{"too large", bytes.Repeat([]byte("x"), 2<<10), nil, http.StatusRequestEntityTooLarge},
+		{"over quota", []byte("x"), services.ErrAttachmentQuotaExceeded, http.StatusRequestEntityTooLarge},
+		{"viewer", []byte("x"), services.ErrNoteForbidden, http.StatusForbidden},
+		{"no note", []byte("x"), services.ErrNoteNotFound, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		h := NewAttachmentHandler(&mockAttachmentService{
+			create: func(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error) {
+				if tt.err == nil {
+					t.Fatalf("%s: unexpected create", tt.name)
+				}
+				return nil, tt.err
+			},
+		}, 1<<10)
+
+		rr := httptest.NewRecorder()
+		h.Upload(rr, uploadRequest(t, uuid.New(), "a.txt", "text/plain", tt.content))
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestAttachmentHandler_Upload_MissingFile(t *testing.T) {
+	h := NewAttachmentHandler(&mockAttachmentService{}, 1<<20)
+
+	noteID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/attachments", strings.NewReader(`{"file":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Upload(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestAttachmentHandler_Download_Range(t *testing.T) {
+	noteID := uuid.New()
+	attachmentID := uuid.New()
+	h := NewAttachmentHandler(&mockAttachmentService{
+		open: func(ctx context.Context, userID, id, aid uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error) {
+			if id != noteID || aid != attachmentID {
+				t.Fatalf("unexpected open %s %s", id, aid)
+			}
+			a := &models.Attachment{ID: aid, NoteID: id, Filename: "résumé \"final\".pdf", ContentType: "application/pdf", Size: 10, CreatedAt: time.Now()}
+			return a, nopSeekCloser{strings.NewReader("0123456789")}, nil
+		},
+	}, 1<<20)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/"+noteID.String()+"/attachments/"+attachmentID.String(), nil)
+	req.SetPathValue("id", noteID.String())
+	req.SetPathValue("attachment_id", attachmentID.String())
+	req.Header.Set("Range", "bytes=4-")
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Download(rr, req)
+
+	if rr.Code != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d", http.StatusPartialContent, rr.Code)
+	}
+	if rr.Body.String() != "456789" {
+		t.Errorf("unexpected body %q", rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Range"); got != "bytes 4-9/10" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment; filename*=utf-8''r%C3%A9sum%C3%A9") {
+		t.Errorf("unexpected Content-Disposition %q", got)
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+}
+
+func TestAttachmentHandler_Download_NotFound(t *testing.T) {
+	h := NewAttachmentHandler(&mockAttachmentService{
+		open: func(ctx context.Context, userID, id, aid uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error) {
+			return nil, nil, services.ErrAttachmentNotFound
+		},
+	}, 1<<20)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("id", uuid.NewString())
+	req.SetPathValue("attachment_id", uuid.NewString())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Download(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestCleanFilename(t *testing.T) {
+	tests := map[string]string{
+		"notes.txt":              "notes.txt",
+		"../../etc/passwd":       "passwd",
+		`C:\temp\a.pdf`:          "a.pdf",
+		"bad\r\nname.txt":        "badname.txt",
+		"   ":                    "attachment",
+		"..":                     "attachment",
+		strings.Repeat("é", 200): strings.Repeat("é", 127),
+	}
+	for in, want := range tests {
+		if got := cleanFilename(in); got != want {
+			t.Errorf("cleanFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -2,15 +2,20 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"net/mail"
 	"strings"
+	"sync"
 	"time"
 	"unicode"
 
+	"github.com/example/notes-template/internal/config"
 	"github.com/example/notes-template/internal/models"
 	"github.com/example/notes-template/internal/services"
 )
@@ -18,38 +23,91 @@ import (
 const (
 	sessionCookieName = "session_token"
 	cookieMaxAge      = 30 * 24 * 60 * 60 // 30 days in seconds
+
+	// backgroundEmailTimeout bounds email work that outlives the request
+	backgroundEmailTimeout = 30 * time.Second
+
+	registerPendingMessage = "Check your email to finish signing up"
 )
 
 type AuthHandler struct {
-	userService  services.UserServiceInterface
-	authService  services.AuthServiceInterface
-	emailService services.EmailServiceInterface
-	secure       bool // Use secure cookies (HTTPS only)
+	userService     services.UserServiceInterface
+	authService     services.AuthServiceInterface
+	emailService    services.EmailServiceInterface
+	registration    *services.RegistrationPolicy
+	magicLinkSignup bool          // Create accounts for unknown emails via magic link
+	reauthWindow    time.Duration // How long a re-authentication stays valid
+	baseURL         string        // Application base URL for validating redirects
+	secure          bool          // Use secure cookies (HTTPS only)
+
+	// Register answers 202 for new and existing emails alike and emails the
+	// account owner instead of returning 409
+	enumerationSafeRegister bool
+
+	pending sync.WaitGroup // Background email tasks
 }
 
-func NewAuthHandler(userService services.UserServiceInterface, authService services.AuthServiceInterface, emailService services.EmailServiceInterface, secure bool) *AuthHandler {
+func NewAuthHandler(userService services.UserServiceInterface, authService services.AuthServiceInterface, emailService services.EmailServiceInterface, registration *services.RegistrationPolicy, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		userService:  userService,
-		authService:  authService,
-		emailService: emailService,
-		secure:       secure,
+		userService:     userService,
+		authService:     authService,
+		emailService:    emailService,
+		registration:    registration,
+		magicLinkSignup: cfg.Auth.MagicLinkSignup,
+		reauthWindow:    cfg.Auth.ReauthWindow,
+		baseURL:         cfg.Email.BaseURL,
+		secure:          cfg.Server.Secure,
+
+		enumerationSafeRegister: cfg.Auth.EnumerationSafeRegister,
+	}
+}
+
+// Wait blocks until background email tasks have finished, for graceful
+// shutdown.
+func (h *AuthHandler) Wait() {
+	h.pending.Wait()
+}
+
+// sendInBackground runs an email task after the response is written. The
+// request context is canceled by then, so tasks get their own timeout.
+func (h *AuthHandler) sendInBackground(kind string, task func(ctx context.Context) error) {
+	h.pending.Add(1)
+	go func() {
+		defer h.pending.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), backgroundEmailTimeout)
+		defer cancel()
+		if err := task(ctx); err != nil {
+			log.Printf("Error sending %s email: %v", kind, err)
+		}
+	}()
+}
+
+func (h *AuthHandler) sendVerificationEmail(user *models.User) {
+	if h.emailService == nil {
+		return
 	}
+	h.sendInBackground("verification", func(ctx context.Context) error {
+		return h.emailService.SendVerificationEmail(ctx, user.ID, user.Email)
+	})
 }
 
 type RegisterRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Username string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Username   string `json:"username"`
+	InviteCode string `json:"invite_code"`
 }
 
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Next     string `json:"next"`
 }
 
 type AuthResponse struct {
 	User    *models.User `json:"user"`
 	Message string       `json:"message,omitempty"`
+	Next    string       `json:"next,omitempty"` // Validated post-login redirect target
 }
 
 type ErrorResponse struct {
@@ -78,7 +136,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 
 	// Validate username
 	req.Username = strings.TrimSpace(req.Username)
-	if len(req.Username) < 2 || len(req.Username) > 100 {
+	if !validUsername(req.Username) {
 		writeError(w, http.StatusBadRequest, "Username must be between 2 and 100 characters")
 		return
 	}
@@ -95,13 +153,33 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Enforce registration policy (claims the invite in invite-only mode)
+	admission, err := h.registration.Admit(r.Context(), req.Email, req.InviteCode)
+	if err != nil {
+		writeRegistrationError(w, err)
+		return
+	}
+
 	// Create user
 	user, err := h.userService.Create(r.Context(), models.CreateUserParams{
 		Email:        req.Email,
 		PasswordHash: passwordHash,
 		Username:     req.Username,
 	})
+	if err != nil {
+		admission.Cancel(r.Context())
+	} else {
+		admission.Complete(r.Context(), user.ID)
+	}
 	if errors.Is(err, services.ErrEmailAlreadyExists) {
+		if h.enumerationSafeRegister {
+			// Tell the account owner instead of the requester
+			h.sendInBackground("account exists", func(ctx context.Context) error {
+				return h.emailService.SendAccountExistsEmail(ctx, req.Email)
+			})
+			writeJSON(w, http.StatusAccepted, AuthResponse{Message: registerPendingMessage})
+			return
+		}
 		writeError(w, http.StatusConflict, "Email already registered")
 		return
 	}
@@ -115,6 +193,13 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.enumerationSafeRegister {
+		// Respond exactly as for an existing email; the user signs in afterwards
+		h.sendVerificationEmail(user)
+		writeJSON(w, http.StatusAccepted, AuthResponse{Message: registerPendingMessage})
+		return
+	}
+
 	// Create session
 	token, err := h.authService.CreateSession(r.Context(), user.ID)
 	if err != nil {
@@ -123,15 +208,8 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Send verification email (non-blocking, don't fail registration if email fails)
-	// Use context.Background() since the request context will be canceled when the response is sent
-	if h.emailService != nil {
-		go func() {
-			if err := h.emailService.SendVerificationEmail(context.Background(), user.ID, user.Email); err != nil {
-				log.Printf("Error sending verification email: %v", err)
-			}
-		}()
-	}
+	// Don't fail registration if the verification email fails
+	h.sendVerificationEmail(user)
 
 	h.setSessionCookie(w, token)
 	writeJSON(w, http.StatusCreated, AuthResponse{User: user})
@@ -148,16 +226,20 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	// Get user by email
 	user, err := h.userService.GetByEmail(r.Context(), req.Email)
-	if errors.Is(err, services.ErrUserNotFound) {
-		writeError(w, http.StatusUnauthorized, "Invalid email or password")
-		return
-	}
-	if err != nil {
+	if err != nil && !errors.Is(err, services.ErrUserNotFound) {
 		log.Printf("Error getting user: %v", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
+	// Unknown and passwordless accounts still pay for a bcrypt comparison so
+	// response times don't reveal which emails are registered
+	if user == nil || !user.HasPassword {
+		h.authService.VerifyDummyPassword(req.Password)
+		writeError(w, http.StatusUnauthorized, "Invalid email or password")
+		return
+	}
+
 	// Verify password
 	if !h.authService.VerifyPassword(user.PasswordHash, req.Password) {
 		writeError(w, http.StatusUnauthorized, "Invalid email or password")
@@ -173,7 +255,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.setSessionCookie(w, token)
-	writeJSON(w, http.StatusOK, AuthResponse{User: user})
+	writeJSON(w, http.StatusOK, AuthResponse{User: user, Next: safeNext(req.Next, h.baseURL)})
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -212,8 +294,9 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify current password
-	if !h.authService.VerifyPassword(user.PasswordHash, req.CurrentPassword) {
+	// Verify current password; passwordless accounts set their initial one
+	setInitial := !user.HasPassword
+	if !setInitial && !h.authService.VerifyPassword(user.PasswordHash, req.CurrentPassword) {
 		writeError(w, http.StatusUnauthorized, "Current password is incorrect")
 		return
 	}
@@ -254,8 +337,73 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	message := "Password changed successfully"
+	if setInitial {
+		message = "Password set successfully"
+	}
+
 	h.setSessionCookie(w, token)
-	writeJSON(w, http.StatusOK, AuthResponse{Message: "Password changed successfully"})
+	writeJSON(w, http.StatusOK, AuthResponse{Message: message})
+}
+
+// ReauthenticateRequest confirms the session for sensitive operations with
+// either the account password or a magic link sent to the account email.
+type ReauthenticateRequest struct {
+	Method   string `json:"method"` // "password" (default) or "magic_link"
+	Password string `json:"password"`
+	Next     string `json:"next"`
+}
+
+// Reauthenticate re-confirms the current session for RequireRecentAuth.
+// A magic link signs in with a new, freshly authenticated session instead.
+func (h *AuthHandler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Not authenticated")
+		return
+	}
+
+	var req ReauthenticateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	switch req.Method {
+	case "magic_link":
+		params := models.MagicLinkParams{Email: user.Email, Next: safeNext(req.Next, h.baseURL)}
+		if err := h.emailService.SendMagicLinkEmail(r.Context(), params); err != nil {
+			log.Printf("Error sending magic link email: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Check your email for a sign-in link"})
+
+	case "", "password":
+		if !user.HasPassword || !h.authService.VerifyPassword(user.PasswordHash, req.Password) {
+			writeError(w, http.StatusUnauthorized, "Password is incorrect")
+			return
+		}
+
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			return
+		}
+		if err := h.authService.MarkSessionAuthenticated(r.Context(), cookie.Value); err != nil {
+			log.Printf("Error marking session authenticated: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"message":    "Reauthenticated",
+			"expires_at": time.Now().Add(h.reauthWindow),
+		})
+
+	default:
+		writeError(w, http.StatusBadRequest, "Method must be password or magic_link")
+	}
 }
 
 // VerifyEmail handles email verification via token
@@ -303,10 +451,13 @@ func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
 }
 
-// MagicLink sends a magic link for passwordless login
+// MagicLink sends a magic link for passwordless login, or sign-up when enabled
 func (h *AuthHandler) MagicLink(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email string `json:"email"`
+		Email      string `json:"email"`
+		Username   string `json:"username"`
+		InviteCode string `json:"invite_code"`
+		Next       string `json:"next"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
@@ -319,15 +470,42 @@ func (h *AuthHandler) MagicLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user exists - but always return success to prevent email enumeration
-	user, err := h.userService.GetByEmail(r.Context(), req.Email)
-	if err == nil && user != nil {
-		// User exists, send magic link
-		if err := h.emailService.SendMagicLinkEmail(r.Context(), req.Email); err != nil {
-			log.Printf("Error sending magic link email: %v", err)
-		}
+	// Username is optional; one is generated on sign-up when omitted
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username != "" && !validUsername(req.Username) {
+		writeError(w, http.StatusBadRequest, "Username must be between 2 and 100 characters")
+		return
+	}
+
+	params := models.MagicLinkParams{
+		Email:      req.Email,
+		Username:   req.Username,
+		InviteCode: strings.TrimSpace(req.InviteCode),
+		Next:       safeNext(req.Next, h.baseURL),
 	}
 
+	// Look up the account and send off the request path so the response
+	// doesn't reveal whether the email is registered
+	h.sendInBackground("magic link", func(ctx context.Context) error {
+		_, err := h.userService.GetByEmail(ctx, params.Email)
+		switch {
+		case err == nil:
+			// User exists, send a login link
+			return h.emailService.SendMagicLinkEmail(ctx, models.MagicLinkParams{Email: params.Email, Next: params.Next})
+		case errors.Is(err, services.ErrUserNotFound) && h.magicLinkSignup:
+			// Unknown email, send a link that creates the account on verification
+			// when the registration policy would admit it
+			if err := h.registration.Check(ctx, params.Email, params.InviteCode); err != nil {
+				return nil
+			}
+			return h.emailService.SendMagicLinkEmail(ctx, params)
+		case errors.Is(err, services.ErrUserNotFound):
+			return nil
+		default:
+			return fmt.Errorf("getting user: %w", err)
+		}
+	})
+
 	// Always return success to prevent email enumeration
 	writeJSON(w, http.StatusOK, map[string]string{"message": "If an account exists, a login link has been sent"})
 }
@@ -340,18 +518,30 @@ func (h *AuthHandler) MagicLinkVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	email, err := h.emailService.VerifyMagicLink(r.Context(), token)
+	link, err := h.emailService.VerifyMagicLink(r.Context(), token)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Get or create user
-	user, err := h.userService.GetByEmail(r.Context(), email)
-	if err != nil {
+	user, err := h.userService.GetByEmail(r.Context(), link.Email)
+	if errors.Is(err, services.ErrUserNotFound) && h.magicLinkSignup {
+		user, err = h.createPasswordlessUser(r.Context(), link, r.URL.Query().Get("invite"))
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
 		writeError(w, http.StatusBadRequest, "User not found")
 		return
 	}
+	if isRegistrationError(err) {
+		writeRegistrationError(w, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
 
 	// Mark email as verified since they clicked a link sent to their email
 	if !user.EmailVerified {
@@ -372,13 +562,14 @@ func (h *AuthHandler) MagicLinkVerify(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.setSessionCookie(w, sessionToken)
-	writeJSON(w, http.StatusOK, AuthResponse{User: user})
+	writeJSON(w, http.StatusOK, AuthResponse{User: user, Next: safeNext(r.URL.Query().Get("next"), h.baseURL)})
 }
 
 // ForgotPassword sends a password reset email
 func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Email string `json:"email"`
+		Next  string `json:"next"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
@@ -391,14 +582,19 @@ func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if user exists - but always return success to prevent email enumeration
-	user, err := h.userService.GetByEmail(r.Context(), req.Email)
-	if err == nil && user != nil {
-		// User exists, send reset email
-		if err := h.emailService.SendPasswordResetEmail(r.Context(), user.ID, user.Email); err != nil {
-			log.Printf("Error sending password reset email: %v", err)
+	// Look up the account and send off the request path so the response
+	// doesn't reveal whether the email is registered
+	email, next := req.Email, safeNext(req.Next, h.baseURL)
+	h.sendInBackground("password reset", func(ctx context.Context) error {
+		user, err := h.userService.GetByEmail(ctx, email)
+		if errors.Is(err, services.ErrUserNotFound) {
+			return nil
 		}
-	}
+		if err != nil {
+			return fmt.Errorf("getting user: %w", err)
+		}
+		return h.emailService.SendPasswordResetEmail(ctx, user.ID, user.Email, next)
+	})
 
 	// Always return success to prevent email enumeration
 	writeJSON(w, http.StatusOK, map[string]string{"message": "If an account exists, reset instructions have been sent"})
@@ -409,6 +605,7 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Token    string `json:"token"`
 		Password string `json:"password"`
+		Next     string `json:"next"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "Invalid request body")
@@ -482,9 +679,56 @@ func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	h.setSessionCookie(w, sessionToken)
-	writeJSON(w, http.StatusOK, AuthResponse{User: user, Message: "Password reset successfully"})
+	writeJSON(w, http.StatusOK, AuthResponse{User: user, Message: "Password reset successfully", Next: safeNext(req.Next, h.baseURL)})
 }
 
+// createPasswordlessUser creates a verified account without a password for a
+// magic link sign-up, subject to the registration policy. The requested
+// username is used when still available, otherwise one is generated from the
+// email address.
+func (h *AuthHandler) createPasswordlessUser(ctx context.Context, link *models.MagicLink, inviteCode string) (*models.User, error) {
+	admission, err := h.registration.Admit(ctx, link.Email, inviteCode)
+	if err != nil {
+		return nil, err
+	}
+	completed := false
+	defer func() {
+		if !completed {
+			admission.Cancel(ctx)
+		}
+	}()
+
+	username := link.Username
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		if username == "" || attempt > 0 {
+			generated, err := generateUsername(link.Email)
+			if err != nil {
+				return nil, err
+			}
+			username = generated
+		}
+
+		user, err := h.userService.Create(ctx, models.CreateUserParams{
+			Email:         link.Email,
+			Username:      username,
+			EmailVerified: true,
+		})
+		if errors.Is(err, services.ErrUsernameAlreadyExists) {
+			continue
+		}
+		if errors.Is(err, services.ErrEmailAlreadyExists) {
+			// Another request created the account first; the invite stays unused
+			return h.userService.GetByEmail(ctx, link.Email)
+		}
+		if err != nil {
+			return nil, err
+		}
+		completed = true
+		admission.Complete(ctx, user.ID)
+		return user, nil
+	}
+	return nil, errors.New("could not generate a unique username")
+}
 
 func (h *AuthHandler) setSessionCookie(w http.ResponseWriter, token string) {
 	http.SetCookie(w, &http.Cookie{
@@ -511,6 +755,60 @@ func (h *AuthHandler) clearSessionCookie(w http.ResponseWriter) {
 	})
 }
 
+const maxUsernameAttempts = 5
+
+func isRegistrationError(err error) bool {
+	return errors.Is(err, services.ErrInviteRequired) ||
+		errors.Is(err, services.ErrInviteInvalid) ||
+		errors.Is(err, services.ErrEmailDomainNotAllowed)
+}
+
+// writeRegistrationError maps registration policy errors to responses.
+func writeRegistrationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInviteRequired):
+		writeError(w, http.StatusForbidden, "An invite code is required to register")
+	case errors.Is(err, services.ErrInviteInvalid):
+		writeError(w, http.StatusForbidden, "Invite code is invalid or has already been used")
+	case errors.Is(err, services.ErrEmailDomainNotAllowed):
+		writeError(w, http.StatusForbidden, "Registration is not open to this email domain")
+	default:
+		log.Printf("Error checking registration policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func validUsername(username string) bool {
+	return len(username) >= 2 && len(username) <= 100
+}
+
+// generateUsername derives a username from the local part of an email address
+// with a random suffix, e.g. "jane.doe-3f9a1c".
+func generateUsername(email string) (string, error) {
+	base := email
+	if idx := strings.Index(base, "@"); idx != -1 {
+		base = base[:idx]
+	}
+	base = strings.Map(func(c rune) rune {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) || c == '.' || c == '_' || c == '-' {
+			return c
+		}
+		return -1
+	}, base)
+	if len(base) > 80 {
+		base = base[:80]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return base + "-" + hex.EncodeToString(suffix), nil
+}
+
 func validatePassword(password string) error {
 	if len(password) < 8 {
 		return errors.New("password must be at least 8 characters")
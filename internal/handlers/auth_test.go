@@ -0,0 +1,743 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/config"
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+var openRegistration = services.NewRegistrationPolicy(services.RegistrationOpen, nil, nil)
+
+type mockUserService struct {
+	create            func(ctx context.Context, params models.CreateUserParams) (*models.User, error)
+	getByID           func(ctx context.Context, id uuid.UUID) (*models.User, error)
+	getByEmail        func(ctx context.Context, email string) (*models.User, error)
+	updatePassword    func(ctx context.Context, userID uuid.UUID, newPasswordHash string) error
+	markEmailVerified func(ctx context.Context, userID uuid.UUID) error
+}
+
+func (m *mockUserService) Create(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+	return m.create(ctx, params)
+}
+
+func (m *mockUserService) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return m.getByID(ctx, id)
+}
+
+func (m *mockUserService) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return m.getByEmail(ctx, email)
+}
+
+func (m *mockUserService) UpdatePassword(ctx context.Context, userID uuid.UUID, newPasswordHash string) error {
+	return m.updatePassword(ctx, userID, newPasswordHash)
+}
+
+func (m *mockUserService) MarkEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	return m.markEmailVerified(ctx, userID)
+}
+
+type mockAuthService struct {
+	verifyPassword    func(hash, password string) bool
+	markAuthenticated func(ctx context.Context, token string) error
+	dummyCompares     int
+}
+
+func (m *mockAuthService) HashPassword(password string) (string, error) {
+	return "hashed:" + password, nil
+}
+
+func (m *mockAuthService) VerifyPassword(hash, password string) bool {
+	if m.verifyPassword != nil {
+		return m.verifyPassword(hash, password)
+	}
+	return hash == "hashed:"+password
+}
+
+func (m *mockAuthService) VerifyDummyPassword(password string) {
+	m.dummyCompares++
+}
+
+func (m *mockAuthService) GenerateSessionToken() (string, string, error) {
+	return "token", "hash", nil
+}
+
+func (m *mockAuthService) CreateSession(ctx context.Context, userID uuid.UUID) (string, error) {
+	return "session-token", nil
+}
+
+func (m *mockAuthService) ValidateSession(ctx context.Context, token string) (*models.User, error) {
+	return nil, services.ErrSessionNotFound
+}
+
+func (m *mockAuthService) MarkSessionAuthenticated(ctx context.Context, token string) error {
+	if m.markAuthenticated != nil {
+		return m.markAuthenticated(ctx, token)
+	}
+	return nil
+}
+
+func (m *mockAuthService) SessionAuthenticatedAt(ctx context.Context, token string) (time.Time, error) {
+	return time.Time{}, services.ErrSessionNotFound
+}
+
+func (m *mockAuthService) DeleteSession(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *mockAuthService) DeleteAllUserSessions(ctx context.Context, userID uuid.UUID) error {
+	return nil
+}
+
+type mockEmailService struct {
+	sendMagicLink     func(ctx context.Context, params models.MagicLinkParams) error
+	verifyMagicLink   func(ctx context.Context, token string) (*models.MagicLink, error)
+	sendAccountExists func(ctx context.Context, email string) error
+}
+
+func (m *mockEmailService) SendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	return nil
+}
+
+func (m *mockEmailService) VerifyEmail(ctx context.Context, token string) error {
+	return nil
+}
+
+func (m *mockEmailService) SendMagicLinkEmail(ctx context.Context, params models.MagicLinkParams) error {
+	return m.sendMagicLink(ctx, params)
+}
+
+func (m *mockEmailService) VerifyMagicLink(ctx context.Context, token string) (*models.MagicLink, error) {
+	return m.verifyMagicLink(ctx, token)
+}
+
+func (m *mockEmailService) SendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email, next string) error {
+	return nil
+}
+
+func (m *mockEmailService) SendAccountExistsEmail(ctx context.Context, email string) error {
+	if m.sendAccountExists != nil {
+		return m.sendAccountExists(ctx, email)
+	}
+	return nil
+}
+
+func (m *mockEmailService) VerifyPasswordResetToken(ctx context.Context, token string) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+
+func (m *mockEmailService) MarkPasswordResetUsed(ctx context.Context, token string) error {
+	return nil
+}
+
+type mockInviteService struct {
+	claim    func(ctx context.Context, code string) (uuid.UUID, error)
+	released []uuid.UUID
+	used     map[uuid.UUID]uuid.UUID
+}
+
+func (m *mockInviteService) Create(ctx context.Context, createdBy *uuid.UUID, ttl time.Duration) (string, *models.Invite, error) {
+	return "code", &models.Invite{ID: uuid.New(), CreatedBy: createdBy}, nil
+}
+
+func (m *mockInviteService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Invite, error) {
+	return nil, nil
+}
+
+func (m *mockInviteService) Validate(ctx context.Context, code string) error {
+	_, err := m.claim(ctx, code)
+	return err
+}
+
+func (m *mockInviteService) Claim(ctx context.Context, code string) (uuid.UUID, error) {
+	return m.claim(ctx, code)
+}
+
+func (m *mockInviteService) Release(ctx context.Context, id uuid.UUID) error {
+	m.released = append(m.released, id)
+	return nil
+}
+
+func (m *mockInviteService) MarkUsedBy(ctx context.Context, inviteID, userID uuid.UUID) error {
+	if m.used == nil {
+		m.used = make(map[uuid.UUID]uuid.UUID)
+	}
+	m.used[inviteID] = userID
+	return nil
+}
+
+func TestAuthHandler_MagicLink_SignupSendsToUnknownEmail(t *testing.T) {
+	var sent *models.MagicLinkParams
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+	emails := &mockEmailService{
+		sendMagicLink: func(ctx context.Context, params models.MagicLinkParams) error {
+			sent = &params
+			return nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{Auth: config.AuthConfig{MagicLinkSignup: true}})
+	body := strings.NewReader(`{"email":"New@Example.com","username":"newbie"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/magic-link", body)
+	rr := httptest.NewRecorder()
+
+	h.MagicLink(rr, req)
+	h.Wait()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if sent == nil {
+		t.Fatal("expected magic link to be sent")
+	}
+	if sent.Email != "new@example.com" || sent.Username != "newbie" {
+		t.Fatalf("unexpected params: %+v", sent)
+	}
+}
+
+func TestAuthHandler_MagicLink_SignupDisabled(t *testing.T) {
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+	emails := &mockEmailService{
+		sendMagicLink: func(ctx context.Context, params models.MagicLinkParams) error {
+			t.Fatal("magic link should not be sent")
+			return nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/magic-link", strings.NewReader(`{"email":"new@example.com"}`))
+	rr := httptest.NewRecorder()
+
+	h.MagicLink(rr, req)
+	h.Wait()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+}
+
+func TestAuthHandler_MagicLinkVerify_CreatesUser(t *testing.T) {
+	var created models.CreateUserParams
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			created = params
+			return &models.User{ID: uuid.New(), Email: params.Email, Username: params.Username, EmailVerified: params.EmailVerified}, nil
+		},
+	}
+	emails := &mockEmailService{
+		verifyMagicLink: func(ctx context.Context, token string) (*models.MagicLink, error) {
+			return &models.MagicLink{Email: "jane.doe+notes@example.com"}, nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{Auth: config.AuthConfig{MagicLinkSignup: true}})
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/magic-link/verify?token=abc", nil)
+	rr := httptest.NewRecorder()
+
+	h.MagicLinkVerify(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if created.PasswordHash != "" {
+		t.Fatalf("expected passwordless user, got hash %q", created.PasswordHash)
+	}
+	if !created.EmailVerified {
+		t.Fatal("expected email to be marked verified")
+	}
+	if !strings.HasPrefix(created.Username, "jane.doenotes-") {
+		t.Fatalf("unexpected generated username %q", created.Username)
+	}
+	if len(rr.Result().Cookies()) == 0 {
+		t.Fatal("expected session cookie")
+	}
+}
+
+func TestAuthHandler_MagicLinkVerify_FallsBackWhenUsernameTaken(t *testing.T) {
+	var attempts []string
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			attempts = append(attempts, params.Username)
+			if params.Username == "taken" {
+				return nil, services.ErrUsernameAlreadyExists
+			}
+			return &models.User{ID: uuid.New(), Email: params.Email, Username: params.Username, EmailVerified: params.EmailVerified}, nil
+		},
+	}
+	emails := &mockEmailService{
+		verifyMagicLink: func(ctx context.Context, token string) (*models.MagicLink, error) {
+			return &models.MagicLink{Email: "sam@example.com", Username: "taken"}, nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{Auth: config.AuthConfig{MagicLinkSignup: true}})
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/magic-link/verify?token=abc", nil)
+	rr := httptest.NewRecorder()
+
+	h.MagicLinkVerify(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if len(attempts) != 2 || attempts[0] != "taken" || !strings.HasPrefix(attempts[1], "sam-") {
+		t.Fatalf("unexpected username attempts: %v", attempts)
+	}
+}
+
+func TestAuthHandler_MagicLinkVerify_UnknownUserWithoutSignup(t *testing.T) {
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+	emails := &mockEmailService{
+		verifyMagicLink: func(ctx context.Context, token string) (*models.MagicLink, error) {
+			return &models.MagicLink{Email: "sam@example.com"}, nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{})
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/magic-link/verify?token=abc", nil)
+	rr := httptest.NewRecorder()
+
+	h.MagicLinkVerify(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestAuthHandler_ChangePassword_SetsInitialPassword(t *testing.T) {
+	user := &models.User{ID: uuid.New(), HasPassword: false}
+	var stored string
+	users := &mockUserService{
+		updatePassword: func(ctx context.Context, userID uuid.UUID, newPasswordHash string) error {
+			stored = newPasswordHash
+			return nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, openRegistration, &config.Config{})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/password", strings.NewReader(`{"new_password":"Sup3rSecret"}`))
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.ChangePassword(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if stored != "hashed:Sup3rSecret" {
+		t.Fatalf("expected password to be stored, got %q", stored)
+	}
+	var payload AuthResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if payload.Message != "Password set successfully" {
+		t.Fatalf("unexpected message %q", payload.Message)
+	}
+}
+
+func TestAuthHandler_ChangePassword_RequiresCurrentPassword(t *testing.T) {
+	user := &models.User{ID: uuid.New(), PasswordHash: "hashed:OldPassw0rd", HasPassword: true}
+	users := &mockUserService{
+		updatePassword: func(ctx context.Context, userID uuid.UUID, newPasswordHash string) error {
+			t.Fatal("password should not be updated")
+			return nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, openRegistration, &config.Config{})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/password", strings.NewReader(`{"new_password":"Sup3rSecret"}`))
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.ChangePassword(rr, req)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, got %d", rr.Code)
+	}
+}
+
+func TestAuthHandler_Login_ReturnsSafeNext(t *testing.T) {
+	user := &models.User{ID: uuid.New(), Email: "sam@example.com", PasswordHash: "hashed:Passw0rd1", HasPassword: true}
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return user, nil
+		},
+	}
+	cfg := &config.Config{Email: config.EmailConfig{BaseURL: "https://notes.example.com"}}
+
+	tests := []struct {
+		next     string
+		expected string
+	}{
+		{next: "#app?note=1", expected: "#app?note=1"},
+		{next: "https://notes.example.com/#app", expected: "/#app"},
+		{next: "https://evil.com/#app", expected: ""},
+	}
+
+	for _, tt := range tests {
+		h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, openRegistration, cfg)
+		body, _ := json.Marshal(LoginRequest{Email: user.Email, Password: "Passw0rd1", Next: tt.next})
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(string(body)))
+		rr := httptest.NewRecorder()
+
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rr.Code)
+		}
+		var payload AuthResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("invalid json: %v", err)
+		}
+		if payload.Next != tt.expected {
+			t.Errorf("next %q: expected %q, got %q", tt.next, tt.expected, payload.Next)
+		}
+	}
+}
+
+func TestAuthHandler_MagicLink_CarriesSafeNext(t *testing.T) {
+	var sent models.MagicLinkParams
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return &models.User{ID: uuid.New(), Email: email}, nil
+		},
+	}
+	emails := &mockEmailService{
+		sendMagicLink: func(ctx context.Context, params models.MagicLinkParams) error {
+			sent = params
+			return nil
+		},
+	}
+
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, &config.Config{})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/magic-link", strings.NewReader(`{"email":"sam@example.com","next":"//evil.com"}`))
+	rr := httptest.NewRecorder()
+
+	h.MagicLink(rr, req)
+	h.Wait()
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if sent.Next != "" {
+		t.Fatalf("expected unsafe next to be dropped, got %q", sent.Next)
+	}
+}
+
+func TestAuthHandler_Register_InviteRequired(t *testing.T) {
+	invites := &mockInviteService{}
+	policy := services.NewRegistrationPolicy(services.RegistrationInvite, nil, invites)
+	h := NewAuthHandler(&mockUserService{}, &mockAuthService{}, &mockEmailService{}, policy, &config.Config{})
+
+	body := `{"email":"a@example.com","password":"Password123","username":"alice"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.Register(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestAuthHandler_Register_ClaimsInvite(t *testing.T) {
+	inviteID := uuid.New()
+	userID := uuid.New()
+	invites := &mockInviteService{
+		claim: func(ctx context.Context, code string) (uuid.UUID, error) {
+			if code != "good-code" {
+				return uuid.Nil, services.ErrInviteInvalid
+			}
+			return inviteID, nil
+		},
+	}
+	users := &mockUserService{
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			return &models.User{ID: userID, Email: params.Email, Username: params.Username}, nil
+		},
+	}
+	policy := services.NewRegistrationPolicy(services.RegistrationInvite, nil, invites)
+	h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, policy, &config.Config{})
+
+	body := `{"email":"a@example.com","password":"Password123","username":"alice","invite_code":"bad-code"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.Register(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+
+	body = `{"email":"a@example.com","password":"Password123","username":"alice","invite_code":"good-code"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	h.Register(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+	if invites.used[inviteID] != userID {
+		t.Fatalf("expected invite to be marked used by the new user")
+	}
+}
+
+func TestAuthHandler_Register_ReleasesInviteOnFailure(t *testing.T) {
+	inviteID := uuid.New()
+	invites := &mockInviteService{
+		claim: func(ctx context.Context, code string) (uuid.UUID, error) {
+			return inviteID, nil
+		},
+	}
+	users := &mockUserService{
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			return nil, services.ErrUsernameAlreadyExists
+		},
+	}
+	policy := services.NewRegistrationPolicy(services.RegistrationInvite, nil, invites)
+	h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, policy, &config.Config{})
+
+	body := `{"email":"a@example.com","password":"Password123","username":"alice","invite_code":"code"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.Register(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+	if len(invites.released) != 1 || invites.released[0] != inviteID {
+		t.Fatalf("expected invite to be released, got %v", invites.released)
+	}
+}
+
+func TestAuthHandler_MagicLink_SignupRespectsDomains(t *testing.T) {
+	sent := 0
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+	emails := &mockEmailService{
+		sendMagicLink: func(ctx context.Context, params models.MagicLinkParams) error {
+			sent++
+			return nil
+		},
+	}
+	policy := services.NewRegistrationPolicy(services.RegistrationDomains, []string{"example.com"}, nil)
+	h := NewAuthHandler(users, &mockAuthService{}, emails, policy, &config.Config{Auth: config.AuthConfig{MagicLinkSignup: true}})
+
+	for _, email := range []string{"a@other.org", "b@example.com"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/magic-link", strings.NewReader(`{"email":"`+email+`"}`))
+		rr := httptest.NewRecorder()
+		h.MagicLink(rr, req)
+		h.Wait()
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+	}
+
+	if sent != 1 {
+		t.Fatalf("expected 1 sign-up link for the allowed domain, got %d", sent)
+	}
+}
+
+func TestAuthHandler_MagicLinkVerify_SignupRejectsDisallowedDomain(t *testing.T) {
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			return nil, services.ErrUserNotFound
+		},
+	}
+	emails := &mockEmailService{
+		verifyMagicLink: func(ctx context.Context, token string) (*models.MagicLink, error) {
+			return &models.MagicLink{Email: "a@other.org"}, nil
+		},
+	}
+	policy := services.NewRegistrationPolicy(services.RegistrationDomains, []string{"example.com"}, nil)
+	h := NewAuthHandler(users, &mockAuthService{}, emails, policy, &config.Config{Auth: config.AuthConfig{MagicLinkSignup: true}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/magic-link/verify?token=abc", nil)
+	rr := httptest.NewRecorder()
+	h.MagicLinkVerify(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestAuthHandler_Reauthenticate_Password(t *testing.T) {
+	var marked string
+	auth := &mockAuthService{
+		markAuthenticated: func(ctx context.Context, token string) error {
+			marked = token
+			return nil
+		},
+	}
+	h := NewAuthHandler(&mockUserService{}, auth, &mockEmailService{}, openRegistration, &config.Config{})
+	user := &models.User{ID: uuid.New(), PasswordHash: "hashed:Password123", HasPassword: true}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantMarked string
+	}{
+		{"wrong password", `{"password":"nope"}`, http.StatusUnauthorized, ""},
+		{"unknown method", `{"method":"sms"}`, http.StatusBadRequest, ""},
+		{"correct password", `{"password":"Password123"}`, http.StatusOK, "session-abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marked = ""
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/reauthenticate", strings.NewReader(tt.body))
+			req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "session-abc"})
+			req = req.WithContext(SetUserInContext(req.Context(), user))
+			rr := httptest.NewRecorder()
+			h.Reauthenticate(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if marked != tt.wantMarked {
+				t.Fatalf("expected marked session %q, got %q", tt.wantMarked, marked)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_Reauthenticate_MagicLink(t *testing.T) {
+	var sent *models.MagicLinkParams
+	emails := &mockEmailService{
+		sendMagicLink: func(ctx context.Context, params models.MagicLinkParams) error {
+			sent = &params
+			return nil
+		},
+	}
+	h := NewAuthHandler(&mockUserService{}, &mockAuthService{}, emails, openRegistration, &config.Config{})
+	user := &models.User{ID: uuid.New(), Email: "a@example.com"}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/reauthenticate", strings.NewReader(`{"method":"magic_link","next":"#settings"}`))
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+	h.Reauthenticate(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if sent == nil || sent.Email != user.Email || sent.Next != "#settings" {
+		t.Fatalf("expected magic link to the account email, got %+v", sent)
+	}
+}
+
+func TestAuthHandler_Login_ConstantWork(t *testing.T) {
+	users := &mockUserService{
+		getByEmail: func(ctx context.Context, email string) (*models.User, error) {
+			if email == "passwordless@example.com" {
+				return &models.User{ID: uuid.New(), Email: email}, nil
+			}
+			return nil, services.ErrUserNotFound
+		},
+	}
+
+	for _, email := range []string{"unknown@example.com", "passwordless@example.com"} {
+		auth := &mockAuthService{}
+		h := NewAuthHandler(users, auth, &mockEmailService{}, openRegistration, &config.Config{})
+
+		body := `{"email":"` + email + `","password":"Password123"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.Login(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Fatalf("%s: expected status %d, got %d", email, http.StatusUnauthorized, rr.Code)
+		}
+		if auth.dummyCompares != 1 {
+			t.Fatalf("%s: expected a dummy password comparison, got %d", email, auth.dummyCompares)
+		}
+	}
+}
+
+func TestAuthHandler_Register_EnumerationSafe(t *testing.T) {
+	var existsSentTo string
+	users := &mockUserService{
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			if params.Email == "taken@example.com" {
+				return nil, services.ErrEmailAlreadyExists
+			}
+			return &models.User{ID: uuid.New(), Email: params.Email, Username: params.Username}, nil
+		},
+	}
+	emails := &mockEmailService{
+		sendAccountExists: func(ctx context.Context, email string) error {
+			existsSentTo = email
+			return nil
+		},
+	}
+	cfg := &config.Config{Auth: config.AuthConfig{EnumerationSafeRegister: true}}
+	h := NewAuthHandler(users, &mockAuthService{}, emails, openRegistration, cfg)
+
+	var bodies []string
+	for _, email := range []string{"new@example.com", "taken@example.com"} {
+		body := `{"email":"` + email + `","password":"Password123","username":"alice"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		h.Register(rr, req)
+		h.Wait()
+
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("%s: expected status %d, got %d", email, http.StatusAccepted, rr.Code)
+		}
+		if len(rr.Result().Cookies()) != 0 {
+			t.Fatalf("%s: expected no session cookie", email)
+		}
+		bodies = append(bodies, rr.Body.String())
+	}
+
+	if bodies[0] != bodies[1] {
+		t.Fatalf("expected identical responses, got %q and %q", bodies[0], bodies[1])
+	}
+	if existsSentTo != "taken@example.com" {
+		t.Fatalf("expected account exists email to taken@example.com, got %q", existsSentTo)
+	}
+}
+
+func TestAuthHandler_Register_ConflictByDefault(t *testing.T) {
+	users := &mockUserService{
+		create: func(ctx context.Context, params models.CreateUserParams) (*models.User, error) {
+			return nil, services.ErrEmailAlreadyExists
+		},
+	}
+	h := NewAuthHandler(users, &mockAuthService{}, &mockEmailService{}, openRegistration, &config.Config{})
+
+	body := `{"email":"taken@example.com","password":"Password123","username":"alice"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.Register(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+type ChallengeHandler struct {
+	challengeService services.ChallengeServiceInterface
+}
+
+func NewChallengeHandler(challengeService services.ChallengeServiceInterface) *ChallengeHandler {
+	return &ChallengeHandler{challengeService: challengeService}
+}
+
+// Issue returns a new proof-of-work challenge for register, magic link and
+// forgot password requests.
+func (h *ChallengeHandler) Issue(w http.ResponseWriter, r *http.Request) {
+	challenge, err := h.challengeService.Issue()
+	if err != nil {
+		log.Printf("Error issuing challenge: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"challenge": challenge})
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/noteimport"
+	"github.com/example/notes-template/internal/services"
+)
+
+// importInflation bounds how much an import may unpack to, as a multiple of
+// the upload limit, so a small zip cannot expand without limit.
+const importInflation = 4
+
+type ImportHandler struct {
+	importService services.ImportServiceInterface
+	maxSize       int64
+}
+
+// NewImportHandler creates an import handler. maxSize bounds the total size
+// of the files in one upload.
+func NewImportHandler(importService services.ImportServiceInterface, maxSize int64) *ImportHandler {
+	return &ImportHandler{importService: importService, maxSize: maxSize}
+}
+
+// Import creates notes from the files in the "file" fields of a
+// multipart/form-data body: Markdown or text files, zips of them, or Evernote
+// .enex exports. Each note is held to the same limits as one created through
+// POST /api/notes; notes that fail them are reported and the rest imported.
+// Small imports answer 201 when done; larger ones answer 202 while still
+// running, with the status URL in Location.
+func (h *ImportHandler) Import(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Now().Add(attachmentTransferTimeout))
+	_ = rc.SetWriteDeadline(time.Now().Add(attachmentTransferTimeout))
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxSize+multipartOverhead)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.writeTooLarge(w)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Expected a multipart/form-data body")
+		return
+	}
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		writeError(w, http.StatusBadRequest, "file is required")
+		return
+	}
+	var size int64
+	for _, header := range files {
+		size += header.Size
+	}
+	if size > h.maxSize {
+		h.writeTooLarge(w)
+		return
+	}
+
+	reader := noteimport.NewReader(noteimport.Limits{
+		MaxNotes: services.MaxImportNotes,
+		MaxBytes: h.maxSize * importInflation,
+	})
+	for _, header := range files {
+		name := cleanFilename(header.Filename)
+		file, err := header.Open()
+		if err != nil {
+			log.Printf("Error opening import file: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		err = reader.Add(name, file, header.Size)
+		file.Close()
+		switch {
+		case err == nil:
+		case errors.Is(err, noteimport.ErrUnsupported):
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s: only .md, .txt, .zip and .enex files can be imported", name))
+			return
+		case errors.Is(err, noteimport.ErrTooManyNotes):
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("At most %d notes can be imported at once", services.MaxImportNotes))
+			return
+		case errors.Is(err, noteimport.ErrTooLarge):
+			h.writeTooLarge(w)
+			return
+		default:
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var notes []models.ImportNoteParams
+	var rejected []models.NoteImportError
+	for _, n := range reader.Notes() {
+		params, err := importNoteParams(n)
+		if err != nil {
+			rejected = append(rejected, models.NoteImportError{Source: n.Source, Error: importErrorMessage(err)})
+			continue
+		}
+		notes = append(notes, params)
+	}
+	if len(notes)+len(rejected) == 0 {
+		writeError(w, http.StatusBadRequest, "No notes found in the uploaded files")
+		return
+	}
+
+	job, err := h.importService.Start(r.Context(), user.ID, notes, rejected)
+	if err != nil {
+		if err == services.ErrImportInProgress {
+			writeError(w, http.StatusConflict, "Another import is still running")
+			return
+		}
+		log.Printf("Error starting import: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	status := http.StatusCreated
+	if job.Status == models.NoteImportRunning {
+		status = http.StatusAccepted
+		w.Header().Set("Location", "/api/imports/"+job.ID.String())
+	}
+	writeJSON(w, status, map[string]interface{}{"import": job})
+}
+
+func (h *ImportHandler) writeTooLarge(w http.ResponseWriter) {
+	writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Imports must be at most %d MB", h.maxSize>>20))
+}
+
+// Get reports the progress of one of the caller's imports.
+func (h *ImportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	importID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid import id")
+		return
+	}
+
+	job, err := h.importService.Get(r.Context(), user.ID, importID)
+	if err != nil {
+		if err == services.ErrImportNotFound {
+			writeError(w, http.StatusNotFound, "Import not found")
+			return
+		}
+		log.Printf("Error getting import: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"import": job})
+}
+
+// importNoteParams validates an imported note like a note created through
+// the API.
+func importNoteParams(n noteimport.Note) (models.ImportNoteParams, error) {
+	title, err := validateNoteTitle(n.Title)
+	if err != nil {
+		return models.ImportNoteParams{}, err
+	}
+	body, err := validateNoteBody(n.Body)
+	if err != nil {
+		return models.ImportNoteParams{}, err
+	}
+	tags, err := services.NormalizeTags(n.Tags)
+	if err != nil {
+		return models.ImportNoteParams{}, err
+	}
+	return models.ImportNoteParams{
+		Source:    n.Source,
+		Title:     title,
+		Body:      body,
+		Tags:      tags,
+		CreatedAt: n.CreatedAt,
+		UpdatedAt: n.UpdatedAt,
+	}, nil
+}
+
+// importErrorMessage is the client-facing reason a note failed validation.
+func importErrorMessage(err error) string {
+	if err == services.ErrInvalidTag || err == services.ErrTooManyTags {
+		return tagErrorMessage(err)
+	}
+	return err.Error()
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockImportService struct {
+	start func(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error)
+	get   func(ctx context.Context, userID, importID uuid.UUID) (*models.NoteImport, error)
+}
+
+func (m *mockImportService) Start(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error) {
+	return m.start(ctx, userID, notes, rejected)
+}
+
+func (m *mockImportService) Get(ctx context.Context, userID, importID uuid.UUID) (*models.NoteImport, error) {
+	return m.get(ctx, userID, importID)
+}
+
+// importRequest uploads files, keyed by file name, to POST /api/notes/import.
+func importRequest(t *testing.T, files map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, content := range files {
+		part, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatalf("CreateFormFile: %v", err)
+		}
+		_, _ = part.Write([]byte(content))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+}
+
+func TestImportHandler_Import(t *testing.T) {
+	var gotNotes []models.ImportNoteParams
+	var gotRejected []models.NoteImportError
+	h := NewImportHandler(&mockImportService{
+		start: func(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error) {
+			gotNotes, gotRejected = notes, rejected
+			return &models.NoteImport{ID: uuid.New(), Status: models.NoteImportCompleted, Total: 2, Imported: 1, Failed: 1}, nil
+		},
+	}, 1<<20)
+
+	rr := httptest.NewRecorder()
+	h.Import(rr, importRequest(t, map[string]string{
+		"plan.md":  "---\ntags: [Work, ideas]\n---\n# Plan\n\nShip it.\n",
+		"empty.md": "# Empty\n",
+	}))
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if len(gotNotes) != 1 || gotNotes[0].Title != "Plan" || gotNotes[0].Body != "Ship it." {
+		t.Fatalf("unexpected notes %+v", gotNotes)
+	}
+	if strings.Join(gotNotes[0].Tags, ",") != "ideas,work" {
+		t.Errorf("expected normalized tags, got %v", gotNotes[0].Tags)
+	}
+	if len(gotRejected) != 1 || gotRejected[0].Source != "empty.md" {
+		t.Fatalf("expected the empty note to be rejected, got %+v", gotRejected)
+	}
+
+	var resp struct {
+		Import models.NoteImport `json:"import"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Import.Imported != 1 || resp.Import.Failed != 1 {
+		t.Errorf("unexpected import %+v", resp.Import)
+	}
+}
+
+func TestImportHandler_Import_Running(t *testing.T) {
+	importID := uuid.New()
+	h := NewImportHandler(&mockImportService{
+		start: func(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error) {
+			return &models.NoteImport{ID: importID, Status: models.NoteImportRunning, Total: len(notes)}, nil
+		},
+	}, 1<<20)
+
+	rr := httptest.NewRecorder()
+	h.Import(rr, importRequest(t, map[string]string{"a.txt": "Some text"}))
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, rr.Code)
+	}
+	if got := rr.Header().Get("Location"); got != "/api/imports/"+importID.String() {
+		t.Errorf("expected the status URL in Location, got %q", got)
+	}
+}
+
+func TestImportHandler_Import_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   error
+		want  int
+	}{
+		{"unsupported", map[string]string{"a.pdf": "%PDF"}, nil, http.StatusBadRequest},
+		{"bad zip", map[string]string{"a.zip": "not a zip"}, nil, http.StatusBadRequest},
+		{"too large", map[string]string{"a.md": strings.Repeat("x", 2<<10)}, nil, http.StatusRequestEntityTooLarge},
+		{"no notes", map[string]string{"a.enex": "<en-export></en-export>"}, nil, http.StatusBadRequest},
+		{"running", map[string]string{"a.md": "x"}, services.ErrImportInProgress, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		h := NewImportHandler(&mockImportService{
+			start: func(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error) {
+				if tt.err == nil {
+					t.Fatalf("%s: unexpected start", tt.name)
+				}
+				return nil, tt.err
+			},
+		}, 1<<10)
+
+		rr := httptest.NewRecorder()
+		h.Import(rr, importRequest(t, tt.files))
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestImportHandler_Get_NotFound(t *testing.T) {
+	h := NewImportHandler(&mockImportService{
+		get: func(ctx context.Context, userID, importID uuid.UUID) (*models.NoteImport, error) {
+			return nil, services.ErrImportNotFound
+		},
+	}, 1<<20)
+
+	importID := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/imports/"+importID.String(), nil)
+	req.SetPathValue("id", importID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Get(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type InviteHandler struct {
+	inviteService services.InviteServiceInterface
+	enabled       bool          // Invites are only issued in invite-only mode
+	ttl           time.Duration // Lifetime of new invite codes
+}
+
+func NewInviteHandler(inviteService services.InviteServiceInterface, mode string, ttl time.Duration) *InviteHandler {
+	return &InviteHandler{
+		inviteService: inviteService,
+		enabled:       mode == services.RegistrationInvite,
+		ttl:           ttl,
+	}
+}
+
+// InviteResponse includes the plaintext code, which is only available once.
+type InviteResponse struct {
+	Code   string         `json:"code"`
+	Invite *models.Invite `json:"invite"`
+}
+
+func (h *InviteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	if !h.enabled {
+		writeError(w, http.StatusForbidden, "Invites are not enabled")
+		return
+	}
+
+	code, invite, err := h.inviteService.Create(r.Context(), &user.ID, h.ttl)
+	if err != nil {
+		log.Printf("Error creating invite: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, InviteResponse{Code: code, Invite: invite})
+}
+
+func (h *InviteHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	invites, err := h.inviteService.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing invites: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"invites": invites})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+func TestInviteHandler_Create_DisabledOutsideInviteMode(t *testing.T) {
+	h := NewInviteHandler(&mockInviteService{}, services.RegistrationOpen, time.Hour)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/invites", nil)
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+	h.Create(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
+
+func TestInviteHandler_Create(t *testing.T) {
+	h := NewInviteHandler(&mockInviteService{}, services.RegistrationInvite, time.Hour)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/invites", nil)
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+	h.Create(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rr.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/markdown"
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type NoteLinkHandler struct {
+	linkService services.NoteLinkServiceInterface
+	pages       *PageHandler
+	baseURL     string
+}
+
+func NewNoteLinkHandler(linkService services.NoteLinkServiceInterface, pages *PageHandler, baseURL string) *NoteLinkHandler {
+	return &NoteLinkHandler{
+		linkService: linkService,
+		pages:       pages,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+type NoteLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"` // Omit for a link that does not expire
+	Password  string     `json:"password"`   // Omit for a link without a password
+}
+
+// Create makes a public link to the caller's note. The response is the only
+// time the link's URL is shown.
+func (h *NoteLinkHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	var req NoteLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	link, token, err := h.linkService.Create(r.Context(), user.ID, noteID, models.CreateNoteLinkParams{
+		ExpiresAt: req.ExpiresAt,
+		Password:  req.Password,
+	})
+	if err != nil {
+		switch err {
+		case services.ErrPasswordTooLong:
+			writeError(w, http.StatusBadRequest, "Password must be at most 72 bytes")
+		case services.ErrTooManyNoteLinks:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("A note can have at most %d active links", services.MaxNoteLinksPerNote))
+		case services.ErrNoteNotFound:
+			writeError(w, http.StatusNotFound, "Note not found")
+		default:
+			log.Printf("Error creating note link: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	link.URL = h.baseURL + "/s/" + token
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"link": link})
+}
+
+// List returns the links to the caller's note.
+func (h *NoteLinkHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	links, err := h.linkService.ListByNote(r.Context(), user.ID, noteID)
+	if err != nil {
+		if err == services.ErrNoteNotFound {
+			writeError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error listing note links: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"links": links})
+}
+
+// Revoke deletes a link so its URL stops working.
+func (h *NoteLinkHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	linkID, err := uuid.Parse(r.PathValue("link_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid link id")
+		return
+	}
+
+	if err := h.linkService.Revoke(r.Context(), user.ID, noteID, linkID); err != nil {
+		if err == services.ErrNoteLinkNotFound {
+			writeError(w, http.StatusNotFound, "Link not found")
+			return
+		}
+		log.Printf("Error revoking note link: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Link revoked"})
+}
+
+// View renders the public page for a link token. It serves GET /s/{token},
+// and POST with a password form field for password-protected links. No
+// session is needed; the link service limits wrong passwords per link.
+func (h *NoteLinkHandler) View(w http.ResponseWriter, r *http.Request) {
+	password := ""
+	if r.Method == http.MethodPost {
+		r.Body = http.MaxBytesReader(w, r.Body, 4<<10)
+		password = r.PostFormValue("password")
+	}
+
+	note, err := h.linkService.Resolve(r.Context(), r.PathValue("token"), password)
+	switch err {
+	case nil:
+	case services.ErrNoteLinkPasswordRequired:
+		h.pages.SharedNote(w, http.StatusOK, SharedNotePageData{NeedsPassword: true})
+		return
+	case services.ErrNoteLinkPasswordInvalid:
+		h.pages.SharedNote(w, http.StatusUnauthorized, SharedNotePageData{NeedsPassword: true, PasswordError: "Wrong password."})
+		return
+	case services.ErrTooManyAttempts:
+		h.pages.SharedNote(w, http.StatusTooManyRequests, SharedNotePageData{NeedsPassword: true, PasswordError: "Too many wrong passwords. Try again later."})
+		return
+	case services.ErrNoteLinkNotFound:
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+		h.pages.NotFound(w, r)
+		return
+	default:
+		log.Printf("Error resolving note link: %v", err)
+		h.pages.InternalError(w, r)
+		return
+	}
+
+	body, err := markdown.Render(note.Body)
+	if err != nil {
+		log.Printf("Error rendering shared note: %v", err)
+		h.pages.InternalError(w, r)
+		return
+	}
+	h.pages.SharedNote(w, http.StatusOK, SharedNotePageData{
+		Title:     note.Title,
+		Body:      template.HTML(body), // Sanitized by markdown.Render
+		UpdatedAt: note.UpdatedAt,
+	})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockNoteLinkService struct {
+	create     func(ctx context.Context, ownerID, noteID uuid.UUID, params models.CreateNoteLinkParams) (*models.NoteLink, string, error)
+	listByNote func(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteLink, error)
+	revoke     func(ctx context.Context, ownerID, noteID, linkID uuid.UUID) error
+	resolve    func(ctx context.Context, token, password string) (*models.Note, error)
+}
+
+func (m *mockNoteLinkService) Create(ctx context.Context, ownerID, noteID uuid.UUID, params models.CreateNoteLinkParams) (*models.NoteLink, string, error) {
+	return m.create(ctx, ownerID, noteID, params)
+}
+
+func (m *mockNoteLinkService) ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteLink, error) {
+	return m.listByNote(ctx, ownerID, noteID)
+}
+
+func (m *mockNoteLinkService) Revoke(ctx context.Context, ownerID, noteID, linkID uuid.UUID) error {
+	return m.revoke(ctx, ownerID, noteID, linkID)
+}
+
+func (m *mockNoteLinkService) Resolve(ctx context.Context, token, password string) (*models.Note, error) {
+	return m.resolve(ctx, token, password)
+}
+
+func newTestNoteLinkHandler(t *testing.T, service *mockNoteLinkService) *NoteLinkHandler {
+	pages, err := NewPageHandler("../../web/templates")
+	if err != nil {
+		t.Fatalf("failed to create page handler: %v", err)
+	}
+	return NewNoteLinkHandler(service, pages, "https://notes.example.com/")
+}
+
+func TestNoteLinkHandler_Create(t *testing.T) {
+	noteID := uuid.New()
+	h := newTestNoteLinkHandler(t, &mockNoteLinkService{
+		create: func(ctx context.Context, ownerID, id uuid.UUID, params models.CreateNoteLinkParams) (*models.NoteLink, string, error) {
+			if params.Password != "pw" || params.ExpiresAt == nil {
+				t.Fatalf("unexpected params %+v", params)
+			}
+			return &models.NoteLink{ID: uuid.New(), NoteID: id, HasPassword: true, ExpiresAt: params.ExpiresAt}, "tok", nil
+		},
+	})
+
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/links", strings.NewReader(`{"password":"pw","expires_at":"`+expires+`"}`))
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Create(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"url":"https://notes.example.com/s/tok"`) {
+		t.Fatalf("expected the link URL, got %s", rr.Body.String())
+	}
+}
+
+func TestNoteLinkHandler_Create_PastExpiry(t *testing.T) {
+	noteID := uuid.New()
+	h := newTestNoteLinkHandler(t, &mockNoteLinkService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/links", strings.NewReader(`{"expires_at":"2000-01-01T00:00:00Z"}`))
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Create(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rr.Code)
+	}
+}
+
+func TestNoteLinkHandler_View(t *testing.T) {
+	h := newTestNoteLinkHandler(t, &mockNoteLinkService{
+		resolve: func(ctx context.Context, token, password string) (*models.Note, error) {
+			switch {
+			case token == "locked":
+				return nil, services.ErrTooManyAttempts
+			case token != "tok":
+				return nil, services.ErrNoteLinkNotFound
+			case password == "":
+				return nil, services.ErrNoteLinkPasswordRequired
+			case password != "secret":
+				return nil, services.ErrNoteLinkPasswordInvalid
+			}
+			return &models.Note{Title: "Trip <plan>", Body: "# Day 1\n<script>x</script>", UpdatedAt: time.Now()}, nil
+		},
+	})
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		want     int
+		contains string
+	}{
+		{"unknown", "nope", "", http.StatusNotFound, "Page not found"},
+		{"needs password", "tok", "", http.StatusOK, `type="password"`},
+		{"wrong password", "tok", "guess", http.StatusUnauthorized, "Wrong password."},
+		{"unlocked", "tok", "secret", http.StatusOK, "<h1>Day 1</h1>"},
+		{"locked out", "locked", "secret", http.StatusTooManyRequests, "Too many wrong passwords."},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/s/"+tt.token, nil)
+		if tt.password != "" {
+			form := url.Values{"password": {tt.password}}
+			req = httptest.NewRequest(http.MethodPost, "/s/"+tt.token, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.SetPathValue("token", tt.token)
+		rr := httptest.NewRecorder()
+
+		h.View(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+		body := rr.Body.String()
+		if !strings.Contains(body, tt.contains) {
+			t.Fatalf("%s: expected %q in %s", tt.name, tt.contains, body)
+		}
+		if rr.Header().Get("X-Robots-Tag") != "noindex, nofollow" {
+			t.Fatalf("%s: expected noindex, got %q", tt.name, rr.Header().Get("X-Robots-Tag"))
+		}
+		if strings.Contains(body, "<script>") || strings.Contains(body, "<plan>") {
+			t.Fatalf("%s: unescaped note content in %s", tt.name, body)
+		}
+	}
+}
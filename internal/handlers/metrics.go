@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// MetricsCollector writes metrics in the Prometheus text exposition format.
+type MetricsCollector interface {
+	WriteMetrics(w io.Writer)
+}
+
+type MetricsHandler struct {
+	token      string
+	collectors []MetricsCollector
+}
+
+// NewMetricsHandler serves metrics from collectors to callers presenting token
+// as a bearer token.
+func NewMetricsHandler(token string, collectors ...MetricsCollector) *MetricsHandler {
+	return &MetricsHandler{
+		token:      token,
+		collectors: collectors,
+	}
+}
+
+func (h *MetricsHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	for _, c := range h.collectors {
+		c.WriteMetrics(w)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type staticCollector string
+
+func (c staticCollector) WriteMetrics(w io.Writer) {
+	_, _ = io.WriteString(w, string(c))
+}
+
+func TestMetricsHandler_RequiresToken(t *testing.T) {
+	h := NewMetricsHandler("secret", staticCollector("janitor_runs_total 1\n"))
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rr := httptest.NewRecorder()
+
+		h.Metrics(rr, req)
+
+		if rr.Code != http.StatusUnauthorized {
+			t.Errorf("authorization %q: expected status 401, got %d", header, rr.Code)
+		}
+	}
+}
+
+func TestMetricsHandler_WritesCollectors(t *testing.T) {
+	h := NewMetricsHandler("secret", staticCollector("a_total 1\n"), staticCollector("b_total 2\n"))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+
+	h.Metrics(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("unexpected content type %q", rr.Header().Get("Content-Type"))
+	}
+	if rr.Body.String() != "a_total 1\nb_total 2\n" {
+		t.Errorf("unexpected body %q", rr.Body.String())
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type NotebookHandler struct {
+	notebookService services.NotebookServiceInterface
+}
+
+func NewNotebookHandler(notebookService services.NotebookServiceInterface) *NotebookHandler {
+	return &NotebookHandler{notebookService: notebookService}
+}
+
+type NotebookRequest struct {
+	Name     string     `json:"name"`
+	ParentID *uuid.UUID `json:"parent_id"` // Only read on create
+}
+
+type MoveNotebookRequest struct {
+	ParentID *uuid.UUID `json:"parent_id"` // Null moves to the top level
+	Position int        `json:"position"`
+}
+
+// validNotebookName trims name and reports whether it is 1-100 characters.
+func validNotebookName(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	return name, name != "" && utf8.RuneCountInString(name) <= 100
+}
+
+// writeNotebookError maps notebook service errors to responses.
+func writeNotebookError(w http.ResponseWriter, err error, action string) {
+	switch err {
+	case services.ErrNotebookNotFound:
+		writeError(w, http.StatusNotFound, "Notebook not found")
+	case services.ErrNotebookParentNotFound:
+		writeError(w, http.StatusBadRequest, "Parent notebook not found")
+	case services.ErrNotebookCycle:
+		writeError(w, http.StatusBadRequest, "A notebook cannot be moved into itself or one of its sub-notebooks")
+	default:
+		log.Printf("Error %s notebook: %v", action, err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func (h *NotebookHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notebooks, err := h.notebookService.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing notebooks: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notebooks": notebooks})
+}
+
+func (h *NotebookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req NotebookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	name, ok := validNotebookName(req.Name)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return
+	}
+
+	notebook, err := h.notebookService.Create(r.Context(), models.CreateNotebookParams{
+		UserID:   user.ID,
+		ParentID: req.ParentID,
+		Name:     name,
+	})
+	if err != nil {
+		writeNotebookError(w, err, "creating")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"notebook": notebook})
+}
+
+func (h *NotebookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notebookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
+		return
+	}
+
+	notebook, err := h.notebookService.GetByID(r.Context(), user.ID, notebookID)
+	if err != nil {
+		writeNotebookError(w, err, "getting")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notebook": notebook})
+}
+
+func (h *NotebookHandler) Update(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notebookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
+		return
+	}
+
+	var req NotebookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	name, ok := validNotebookName(req.Name)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Name must be between 1 and 100 characters")
+		return
+	}
+
+	notebook, err := h.notebookService.Rename(r.Context(), user.ID, notebookID, name)
+	if err != nil {
+		writeNotebookError(w, err, "updating")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notebook": notebook})
+}
+
+// Move re-parents and/or reorders a notebook.
+func (h *NotebookHandler) Move(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notebookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
+		return
+	}
+
+	var req MoveNotebookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Position < 0 {
+		writeError(w, http.StatusBadRequest, "Position must not be negative")
+		return
+	}
+
+	notebook, err := h.notebookService.Move(r.Context(), user.ID, notebookID, req.ParentID, req.Position)
+	if err != nil {
+		writeNotebookError(w, err, "moving")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notebook": notebook})
+}
+
+// Delete removes a notebook and its sub-notebooks. Their notes move to the
+// root unless ?delete_notes=true.
+func (h *NotebookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notebookID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
+		return
+	}
+
+	deleteNotes := false
+	switch r.URL.Query().Get("delete_notes") {
+	case "", "false":
+	case "true":
+		deleteNotes = true
+	default:
+		writeError(w, http.StatusBadRequest, "delete_notes must be true or false")
+		return
+	}
+
+	if err := h.notebookService.Delete(r.Context(), user.ID, notebookID, deleteNotes); err != nil {
+		writeNotebookError(w, err, "deleting")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Notebook deleted"})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockNotebookService struct {
+	list   func(ctx context.Context, userID uuid.UUID) ([]*models.Notebook, error)
+	create func(ctx context.Context, params models.CreateNotebookParams) (*models.Notebook, error)
+	get    func(ctx context.Context, userID, notebookID uuid.UUID) (*models.Notebook, error)
+	rename func(ctx context.Context, userID, notebookID uuid.UUID, name string) (*models.Notebook, error)
+	move   func(ctx context.Context, userID, notebookID uuid.UUID, parentID *uuid.UUID, position int) (*models.Notebook, error)
+	delete func(ctx context.Context, userID, notebookID uuid.UUID, deleteNotes bool) error
+}
+
+func (m *mockNotebookService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Notebook, error) {
+	return m.list(ctx, userID)
+}
+
+func (m *mockNotebookService) Create(ctx context.Context, params models.CreateNotebookParams) (*models.Notebook, error) {
+	return m.create(ctx, params)
+}
+
+func (m *mockNotebookService) GetByID(ctx context.Context, userID, notebookID uuid.UUID) (*models.Notebook, error) {
+	return m.get(ctx, userID, notebookID)
+}
+
+func (m *mockNotebookService) Rename(ctx context.Context, userID, notebookID uuid.UUID, name string) (*models.Notebook, error) {
+	return m.rename(ctx, userID, notebookID, name)
+}
+
+func (m *mockNotebookService) Move(ctx context.Context, userID, notebookID uuid.UUID, parentID *uuid.UUID, position int) (*models.Notebook, error) {
+	return m.move(ctx, userID, notebookID, parentID, position)
+}
+
+func (m *mockNotebookService) Delete(ctx context.Context, userID, notebookID uuid.UUID, deleteNotes bool) error {
+	return m.delete(ctx, userID, notebookID, deleteNotes)
+}
+
+func TestNotebookHandler_Create_InvalidName(t *testing.T) {
+	h := NewNotebookHandler(&mockNotebookService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/notebooks", strings.NewReader(`{"name":"   "}`))
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Create(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestNotebookHandler_Move_Cycle(t *testing.T) {
+	notebookID := uuid.New()
+	childID := uuid.New()
+	h := NewNotebookHandler(&mockNotebookService{
+		move: func(ctx context.Context, userID, id uuid.UUID, parentID *uuid.UUID, position int) (*models.Notebook, error) {
+			if parentID == nil || *parentID != childID || position != 1 {
+				t.Fatalf("unexpected move to %v at %d", parentID, position)
+			}
+			return nil, services.ErrNotebookCycle
+		},
+	})
+
+	body := strings.NewReader(`{"parent_id":"` + childID.String() + `","position":1}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/notebooks/"+notebookID.String()+"/move", body)
+	req.SetPathValue("id", notebookID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Move(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestNotebookHandler_Delete(t *testing.T) {
+	notebookID := uuid.New()
+	tests := []struct {
+		query       string
+		status      int
+		deleteNotes bool
+	}{
+		{"", http.StatusOK, false},
+		{"?delete_notes=true", http.StatusOK, true},
+		{"?delete_notes=maybe", http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		var got *bool
+		h := NewNotebookHandler(&mockNotebookService{
+			delete: func(ctx context.Context, userID, id uuid.UUID, deleteNotes bool) error {
+				got = &deleteNotes
+				return nil
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodDelete, "/api/notebooks/"+notebookID.String()+tt.query, nil)
+		req.SetPathValue("id", notebookID.String())
+		req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+		rr := httptest.NewRecorder()
+
+		h.Delete(rr, req)
+
+		if rr.Code != tt.status {
+			t.Fatalf("%q: expected status %d, got %d", tt.query, tt.status, rr.Code)
+		}
+		if tt.status == http.StatusOK && (got == nil || *got != tt.deleteNotes) {
+			t.Fatalf("%q: expected deleteNotes=%v, got %v", tt.query, tt.deleteNotes, got)
+		}
+	}
+}
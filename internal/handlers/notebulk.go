@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+// Modes accepted by NoteBulkRequest.Mode.
+const (
+	bulkModeAtomic     = "atomic"
+	bulkModeBestEffort = "best_effort"
+)
+
+type NoteBulkRequest struct {
+	// Mode is atomic (the default), where one failing note leaves every note
+	// unchanged, or best_effort, where the other notes are still changed.
+	Mode       string                     `json:"mode"`
+	Operations []NoteBulkOperationRequest `json:"operations"`
+}
+
+type NoteBulkOperationRequest struct {
+	Op      string      `json:"op"`
+	NoteIDs []uuid.UUID `json:"note_ids"`
+	// NotebookID is where a move puts the notes: a notebook id, or null for
+	// the root. It is required for moves.
+	NotebookID json.RawMessage `json:"notebook_id"`
+	Tags       []string        `json:"tags"` // Added by tag, removed by untag
+}
+
+// NoteBulkItem is the outcome for one note. Status is what the matching
+// single-note request would have answered.
+type NoteBulkItem struct {
+	Op     int       `json:"op"`
+	NoteID uuid.UUID `json:"note_id"`
+	Status int       `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Bulk applies delete, move, tag, untag, archive and unarchive operations to
+// many notes in one request. In atomic mode a failing note fails the whole
+// request with that note's status; in best_effort mode the answer is 200
+// with a result per note.
+func (h *NoteHandler) Bulk(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req NoteBulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	switch req.Mode {
+	case "", bulkModeAtomic, bulkModeBestEffort:
+	default:
+		writeError(w, http.StatusBadRequest, "mode must be atomic or best_effort")
+		return
+	}
+
+	ops, err := parseNoteBulkOperations(req.Operations)
+	if err != nil {
+		writeNoteFieldError(w, err)
+		return
+	}
+
+	results, err := h.noteService.Bulk(r.Context(), user.ID, ops, req.Mode != bulkModeBestEffort)
+	if err == services.ErrNoteBulkAborted && len(results) > 0 {
+		failed := noteBulkItem(results[len(results)-1])
+		writeJSON(w, failed.Status, map[string]interface{}{
+			"error":  fmt.Sprintf("No notes were changed: %s", failed.Error),
+			"failed": failed,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying bulk note changes: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	items := make([]NoteBulkItem, len(results))
+	failures := 0
+	for i, result := range results {
+		items[i] = noteBulkItem(result)
+		if result.Err != nil {
+			failures++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"results": items,
+		"applied": len(items) - failures,
+		"failed":  failures,
+	})
+}
+
+// parseNoteBulkOperations validates a bulk request's operations and
+// normalizes their tags.
+func parseNoteBulkOperations(reqs []NoteBulkOperationRequest) ([]models.NoteBulkOperation, error) {
+	if len(reqs) == 0 {
+		return nil, noteFieldError("operations must not be empty")
+	}
+
+	ops := make([]models.NoteBulkOperation, len(reqs))
+	items := 0
+	for i, req := range reqs {
+		if len(req.NoteIDs) == 0 {
+			return nil, noteFieldError(fmt.Sprintf("operations[%d].note_ids must not be empty", i))
+		}
+		items += len(req.NoteIDs)
+		if items > services.MaxNoteBulkItems {
+			return nil, noteFieldError(fmt.Sprintf("At most %d notes can be changed in one request", services.MaxNoteBulkItems))
+		}
+		op := models.NoteBulkOperation{Op: req.Op, NoteIDs: req.NoteIDs}
+
+		switch req.Op {
+		case models.NoteBulkDelete, models.NoteBulkArchive, models.NoteBulkUnarchive:
+		case models.NoteBulkMove:
+			notebookID, set, err := NoteRequest{NotebookID: req.NotebookID}.notebookID()
+			if err != nil || !set {
+				return nil, noteFieldError(fmt.Sprintf("operations[%d].notebook_id must be a notebook id or null", i))
+			}
+			op.NotebookID = notebookID
+		case models.NoteBulkTag, models.NoteBulkUntag:
+			tags, err := services.NormalizeTags(req.Tags)
+			if err != nil {
+				return nil, err
+			}
+			if len(tags) == 0 {
+				return nil, noteFieldError(fmt.Sprintf("operations[%d].tags must not be empty", i))
+			}
+			op.Tags = tags
+		default:
+			return nil, noteFieldError(fmt.Sprintf("operations[%d].op must be one of delete, move, tag, untag, archive or unarchive", i))
+		}
+		ops[i] = op
+	}
+	return ops, nil
+}
+
+// noteBulkItem turns a service result into its client-facing form.
+func noteBulkItem(result models.NoteBulkResult) NoteBulkItem {
+	item := NoteBulkItem{Op: result.Op, NoteID: result.NoteID, Status: http.StatusOK}
+	switch result.Err {
+	case nil:
+	case services.ErrNoteNotFound:
+		item.Status, item.Error = http.StatusNotFound, "Note not found"
+	case services.ErrNoteForbidden:
+		item.Status, item.Error = http.StatusForbidden, "Only editors can trash a shared note, and only its owner can change anything else"
+	case services.ErrNotebookNotFound:
+		item.Status, item.Error = http.StatusNotFound, "Notebook not found"
+	case services.ErrTooManyTags:
+		item.Status, item.Error = http.StatusBadRequest, tagErrorMessage(result.Err)
+	default:
+		item.Status, item.Error = http.StatusInternalServerError, "Internal server error"
+	}
+	return item
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+func bulkRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/bulk", strings.NewReader(body))
+	return req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+}
+
+func TestNoteHandler_Bulk_BestEffort(t *testing.T) {
+	ok, missing := uuid.New(), uuid.New()
+	var gotOps []models.NoteBulkOperation
+	var gotAtomic bool
+	h := NewNoteHandler(&mockNoteService{
+		bulk: func(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error) {
+			gotOps, gotAtomic = ops, atomic
+			return []models.NoteBulkResult{
+				{Op: 0, NoteID: ok},
+				{Op: 1, NoteID: missing, Err: services.ErrNoteNotFound},
+			}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Bulk(rr, bulkRequest(`{"mode":"best_effort","operations":[
+		{"op":"tag","note_ids":["`+ok.String()+`"],"tags":["#Work"]},
+		{"op":"move","note_ids":["`+missing.String()+`"],"notebook_id":null}]}`))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if gotAtomic || len(gotOps) != 2 || gotOps[0].Tags[0] != "work" || gotOps[1].NotebookID != nil {
+		t.Fatalf("unexpected operations %+v (atomic %v)", gotOps, gotAtomic)
+	}
+
+	var resp struct {
+		Results []NoteBulkItem `json:"results"`
+		Applied int            `json:"applied"`
+		Failed  int            `json:"failed"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if resp.Applied != 1 || resp.Failed != 1 || resp.Results[1].Status != http.StatusNotFound || resp.Results[1].Error == "" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestNoteHandler_Bulk_AtomicFailure(t *testing.T) {
+	noteID := uuid.New()
+	h := NewNoteHandler(&mockNoteService{
+		bulk: func(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error) {
+			if !atomic {
+				t.Fatal("expected atomic to be the default")
+			}
+			return []models.NoteBulkResult{{NoteID: noteID, Err: services.ErrNoteForbidden}}, services.ErrNoteBulkAborted
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Bulk(rr, bulkRequest(`{"operations":[{"op":"archive","note_ids":["`+noteID.String()+`"]}]}`))
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status 403, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), noteID.String()) {
+		t.Fatalf("expected the failing note in the response, got %s", rr.Body.String())
+	}
+}
+
+func TestNoteHandler_Bulk_InvalidRequests(t *testing.T) {
+	h := NewNoteHandler(&mockNoteService{
+		bulk: func(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error) {
+			t.Fatalf("unexpected bulk %+v", ops)
+			return nil, nil
+		},
+	})
+
+	id := `"` + uuid.NewString() + `"`
+	tooMany := strings.TrimSuffix(strings.Repeat(id+",", services.MaxNoteBulkItems+1), ",")
+	for _, body := range []string{
+		`{"operations":[]}`,
+		`{"mode":"sometimes","operations":[{"op":"delete","note_ids":[` + id + `]}]}`,
+		`{"operations":[{"op":"publish","note_ids":[` + id + `]}]}`,
+		`{"operations":[{"op":"delete","note_ids":[]}]}`,
+		`{"operations":[{"op":"delete","note_ids":["nope"]}]}`,
+		`{"operations":[{"op":"move","note_ids":[` + id + `]}]}`,
+		`{"operations":[{"op":"tag","note_ids":[` + id + `]}]}`,
+		`{"operations":[{"op":"delete","note_ids":[` + tooMany + `]}]}`,
+	} {
+		rr := httptest.NewRecorder()
+		h.Bulk(rr, bulkRequest(body))
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", body, rr.Code)
+		}
+	}
+}
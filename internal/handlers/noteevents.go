@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+const (
+	// noteEventsHeartbeat is how often an idle stream sends a comment, so
+	// proxies keep it open and a dead client is noticed.
+	noteEventsHeartbeat = 15 * time.Second
+
+	// noteEventsWriteTimeout is how long one write to the stream may take.
+	// It replaces the server's WriteTimeout, which would end every stream.
+	noteEventsWriteTimeout = 10 * time.Second
+
+	// noteEventsRetry is how long EventSource waits before reconnecting.
+	noteEventsRetry = 5 * time.Second
+)
+
+// Names of the events that tell a client to reload its notes: ready on a
+// fresh connection, reset when a reconnect cannot replay what it missed.
+const (
+	noteEventReady = "ready"
+	noteEventReset = "reset"
+)
+
+type NoteEventHandler struct {
+	events    services.NoteEventsInterface
+	heartbeat time.Duration
+}
+
+func NewNoteEventHandler(events services.NoteEventsInterface) *NoteEventHandler {
+	return &NoteEventHandler{events: events, heartbeat: noteEventsHeartbeat}
+}
+
+// Stream sends the caller's note changes as server-sent events named created,
+// updated and deleted, with the event as JSON data. A fresh connection starts
+// with a ready event. A reconnect with Last-Event-ID replays what it missed,
+// or sends reset if that is no longer possible; after ready or reset the
+// client should reload its notes.
+func (h *NoteEventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	sub, err := h.events.Subscribe(r.Context(), user.ID, lastEventID)
+	if err != nil {
+		log.Printf("Error subscribing to note events: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	stream := &eventStream{w: w, rc: http.NewResponseController(w)}
+	if err := stream.send(fmt.Sprintf("retry: %d\n\n", noteEventsRetry.Milliseconds())); err != nil {
+		return
+	}
+
+	last := sub.Cursor
+	if sub.Reset {
+		name := noteEventReset
+		if lastEventID == "" {
+			name = noteEventReady
+		}
+		if err := stream.event(last, name, map[string]string{"type": name}); err != nil {
+			return
+		}
+	}
+	for _, event := range sub.Replay {
+		if err := stream.event(event.ID, event.Type, event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Shutting down, or too far behind; the client reconnects
+				return
+			}
+			// Skip events the replay already sent
+			if !event.After(last) {
+				continue
+			}
+			last = event.ID
+			if err := stream.event(event.ID, event.Type, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := stream.send(": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// eventStream writes server-sent events, flushing each through any
+// compression so it reaches the client at once.
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *eventStream) event(id, name string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.send(fmt.Sprintf("id: %s\nevent: %s\ndata: %s\n\n", id, name, payload))
+}
+
+func (s *eventStream) send(message string) error {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(noteEventsWriteTimeout))
+	if _, err := fmt.Fprint(s.w, message); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockNoteEvents struct {
+	subscribe func(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error)
+}
+
+func (m *mockNoteEvents) Subscribe(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error) {
+	return m.subscribe(ctx, userID, lastEventID)
+}
+
+func eventsRequest(ctx context.Context, lastEventID string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	return req.WithContext(SetUserInContext(ctx, &models.User{ID: uuid.New()}))
+}
+
+func TestNoteEventHandler_Stream(t *testing.T) {
+	noteID := uuid.New()
+	events := make(chan models.NoteEvent, 2)
+	// The first event was already covered by the reload
+	events <- models.NoteEvent{ID: "7-0", Type: models.NoteEventCreated, NoteID: uuid.New()}
+	events <- models.NoteEvent{ID: "8-0", Type: models.NoteEventUpdated, NoteID: noteID, Version: 2}
+	close(events)
+
+	closed := false
+	h := NewNoteEventHandler(&mockNoteEvents{
+		subscribe: func(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error) {
+			return &services.NoteEventSubscription{Reset: true, Cursor: "7-0", Events: events, Close: func() { closed = true }}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Stream(rr, eventsRequest(context.Background(), ""))
+
+	if got := rr.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %q", got)
+	}
+	body := rr.Body.String()
+	if !strings.HasPrefix(body, "retry: 5000\n\nid: 7-0\nevent: ready\n") {
+		t.Errorf("expected a ready event at the reload position, got %q", body)
+	}
+	if strings.Count(body, "id: 7-0") != 1 {
+		t.Errorf("expected events up to the reload position to be skipped, got %q", body)
+	}
+	if !strings.Contains(body, "id: 8-0\nevent: updated\ndata: {\"type\":\"updated\",\"note_id\":\""+noteID.String()+"\",\"version\":2,") {
+		t.Errorf("expected the update, got %q", body)
+	}
+	if !closed {
+		t.Error("expected the subscription to be closed")
+	}
+}
+
+func TestNoteEventHandler_Stream_Resume(t *testing.T) {
+	var gotLastEventID string
+	h := NewNoteEventHandler(&mockNoteEvents{
+		subscribe: func(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error) {
+			gotLastEventID = lastEventID
+			return &services.NoteEventSubscription{
+				Replay: []models.NoteEvent{{ID: "5-0", Type: models.NoteEventDeleted, NoteID: uuid.New()}},
+				Cursor: "5-0",
+				Events: make(chan models.NoteEvent),
+				Close:  func() {},
+			}, nil
+		},
+	})
+	h.heartbeat = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	rr := httptest.NewRecorder()
+	h.Stream(rr, eventsRequest(ctx, "4-0"))
+
+	body := rr.Body.String()
+	if gotLastEventID != "4-0" {
+		t.Errorf("expected Last-Event-ID to be passed on, got %q", gotLastEventID)
+	}
+	if strings.Contains(body, "event: ready") || !strings.Contains(body, "id: 5-0\nevent: deleted\n") {
+		t.Errorf("expected the missed event replayed, got %q", body)
+	}
+	if !strings.Contains(body, ": heartbeat\n\n") {
+		t.Errorf("expected heartbeats while idle, got %q", body)
+	}
+}
+
+func TestNoteEventHandler_Stream_Reset(t *testing.T) {
+	h := NewNoteEventHandler(&mockNoteEvents{
+		subscribe: func(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error) {
+			events := make(chan models.NoteEvent)
+			close(events)
+			return &services.NoteEventSubscription{Reset: true, Cursor: "9-0", Events: events, Close: func() {}}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Stream(rr, eventsRequest(context.Background(), "1-0"))
+
+	if !strings.Contains(rr.Body.String(), "id: 9-0\nevent: reset\ndata: {\"type\":\"reset\"}\n\n") {
+		t.Errorf("expected a reset event, got %q", rr.Body.String())
+	}
+}
+
+func TestNoteEventHandler_Stream_Error(t *testing.T) {
+	h := NewNoteEventHandler(&mockNoteEvents{
+		subscribe: func(ctx context.Context, userID uuid.UUID, lastEventID string) (*services.NoteEventSubscription, error) {
+			return nil, errors.New("redis down")
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Stream(rr, eventsRequest(context.Background(), ""))
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+// Formats accepted by GET /api/notes/export.
+const (
+	exportFormatZip  = "zip"
+	exportFormatJSON = "json"
+)
+
+// exportSchemaVersion is the "version" of JSON exports. It changes only when
+// a field is removed or changes meaning; new fields may be added within one
+// version.
+const exportSchemaVersion = 1
+
+// exportWriteTimeout is how long each note of an export may take to reach the
+// client. The deadline moves with every note, so large exports are not cut
+// off by the server's WriteTimeout but a stalled client still is.
+const exportWriteTimeout = time.Minute
+
+// maxExportNameLength caps the part of a zip entry name taken from the title.
+const maxExportNameLength = 60
+
+// NoteExport is one note in a JSON export. It is kept separate from
+// models.Note so the export schema only changes on purpose.
+type NoteExport struct {
+	ID         uuid.UUID  `json:"id"`
+	NotebookID *uuid.UUID `json:"notebook_id"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	Tags       []string   `json:"tags"`
+	Pinned     bool       `json:"pinned"`
+	Archived   bool       `json:"archived"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// exportFrontMatter opens each Markdown file of a zip export. title, tags,
+// created and updated are read back by the importer.
+type exportFrontMatter struct {
+	ID         string    `yaml:"id"`
+	NotebookID string    `yaml:"notebook_id,omitempty"`
+	Title      string    `yaml:"title"`
+	Tags       []string  `yaml:"tags,flow"`
+	Created    time.Time `yaml:"created"`
+	Updated    time.Time `yaml:"updated"`
+	Pinned     bool      `yaml:"pinned,omitempty"`
+	Archived   bool      `yaml:"archived,omitempty"`
+}
+
+// noteExporter writes notes in one export format. Nothing reaches the client
+// before the first add, so an export that fails before its first note can
+// still answer with an error.
+type noteExporter interface {
+	add(note *models.Note) error
+	close() error
+}
+
+// Export streams the caller's notes, except those in the trash, as a download:
+// a zip of Markdown files with YAML front matter (?format=zip, the default)
+// or one JSON document (?format=json). A failure partway through aborts the
+// connection, so a client never mistakes a truncated export for a whole one.
+func (h *NoteHandler) Export(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = exportFormatZip
+	}
+	var exporter noteExporter
+	switch format {
+	case exportFormatZip:
+		w.Header().Set("Content-Type", "application/zip")
+		exporter = &zipNoteExporter{zw: zip.NewWriter(w)}
+	case exportFormatJSON:
+		w.Header().Set("Content-Type", "application/json")
+		exporter = &jsonNoteExporter{w: w, exportedAt: time.Now().UTC()}
+	default:
+		writeError(w, http.StatusBadRequest, "format must be zip or json")
+		return
+	}
+	filename := fmt.Sprintf("notes-%s.%s", time.Now().UTC().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Cache-Control", "no-store")
+
+	rc := http.NewResponseController(w)
+	sent := 0
+	err := h.noteService.Export(r.Context(), user.ID, func(note *models.Note) error {
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		sent++
+		return exporter.add(note)
+	})
+	if err == nil {
+		_ = rc.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+		err = exporter.close()
+	}
+	if err == nil {
+		return
+	}
+
+	log.Printf("Error exporting notes: %v", err)
+	if sent == 0 {
+		w.Header().Del("Content-Disposition")
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+type zipNoteExporter struct {
+	zw *zip.Writer
+}
+
+func (e *zipNoteExporter) add(note *models.Note) error {
+	fm := exportFrontMatter{
+		ID:       note.ID.String(),
+		Title:    note.Title,
+		Tags:     note.Tags,
+		Created:  note.CreatedAt.UTC(),
+		Updated:  note.UpdatedAt.UTC(),
+		Pinned:   note.Pinned,
+		Archived: note.Archived,
+	}
+	if note.NotebookID != nil {
+		fm.NotebookID = note.NotebookID.String()
+	}
+	block, err := yaml.Marshal(fm)
+	if err != nil {
+		return fmt.Errorf("encoding front matter: %w", err)
+	}
+
+	f, err := e.zw.CreateHeader(&zip.FileHeader{
+		Name:     exportFilename(note),
+		Method:   zip.Deflate,
+		Modified: note.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "---\n%s---\n\n%s\n", block, note.Body)
+	return err
+}
+
+func (e *zipNoteExporter) close() error {
+	return e.zw.Close()
+}
+
+// exportFilename names a note's file in a zip export after its title, made
+// safe for common file systems, and its id, which keeps names unique.
+func exportFilename(note *models.Note) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '-'
+		}
+		return r
+	}, note.Title)
+	if runes := []rune(name); len(runes) > maxExportNameLength {
+		name = string(runes[:maxExportNameLength])
+	}
+	name = strings.Trim(name, " .")
+	if name == "" {
+		name = "Note"
+	}
+	return fmt.Sprintf("%s-%s.md", name, note.ID)
+}
+
+// jsonNoteExporter writes {"version", "exported_at", "notes": [...]} one note
+// at a time.
+type jsonNoteExporter struct {
+	w          io.Writer
+	exportedAt time.Time
+	count      int
+}
+
+func (e *jsonNoteExporter) add(note *models.Note) error {
+	if err := e.open(); err != nil {
+		return err
+	}
+	tags := note.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	data, err := json.Marshal(NoteExport{
+		ID:         note.ID,
+		NotebookID: note.NotebookID,
+		Title:      note.Title,
+		Body:       note.Body,
+		Tags:       tags,
+		Pinned:     note.Pinned,
+		Archived:   note.Archived,
+		CreatedAt:  note.CreatedAt.UTC(),
+		UpdatedAt:  note.UpdatedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding note: %w", err)
+	}
+	if e.count > 0 {
+		data = append([]byte(","), data...)
+	}
+	e.count++
+	_, err = e.w.Write(data)
+	return err
+}
+
+// open writes the document's opening before its first note.
+func (e *jsonNoteExporter) open() error {
+	if e.count > 0 {
+		return nil
+	}
+	exportedAt, err := json.Marshal(e.exportedAt)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(e.w, `{"version":%d,"exported_at":%s,"notes":[`, exportSchemaVersion, exportedAt)
+	return err
+}
+
+func (e *jsonNoteExporter) close() error {
+	if err := e.open(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, "]}\n")
+	return err
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/noteimport"
+)
+
+func exportNotes() []*models.Note {
+	created := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	notebookID := uuid.New()
+	return []*models.Note{
+		{ID: uuid.New(), Title: "Plan: Q2/Q3", Body: "# Goals\n\n- Ship it", Tags: []string{"work", "ideas"},
+			CreatedAt: created, UpdatedAt: created.Add(time.Hour), Pinned: true},
+		{ID: uuid.New(), NotebookID: &notebookID, Title: "yes: no", Body: "Old", Tags: []string{},
+			CreatedAt: created, UpdatedAt: created, Archived: true},
+	}
+}
+
+func exportRequest(format string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/export?format="+format, nil)
+	return req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+}
+
+func exportingService(notes []*models.Note, err error) *mockNoteService {
+	return &mockNoteService{
+		export: func(ctx context.Context, userID uuid.UUID, fn func(*models.Note) error) error {
+			for _, note := range notes {
+				if err := fn(note); err != nil {
+					return err
+				}
+			}
+			return err
+		},
+	}
+}
+
+func TestNoteHandler_Export_Zip(t *testing.T) {
+	notes := exportNotes()
+	h := NewNoteHandler(exportingService(notes, nil))
+
+	rr := httptest.NewRecorder()
+	h.Export(rr, exportRequest(""))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Type"); got != "application/zip" {
+		t.Errorf("expected a zip by default, got %q", got)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.HasPrefix(got, "attachment; filename=notes-") {
+		t.Errorf("expected a download, got %q", got)
+	}
+
+	// The importer reads the export back as the same notes
+	reader := noteimport.NewReader(noteimport.Limits{MaxNotes: 10, MaxBytes: 1 << 20})
+	data := rr.Body.Bytes()
+	if err := reader.Add("export.zip", bytes.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	imported := reader.Notes()
+	if len(imported) != len(notes) {
+		t.Fatalf("expected %d notes, got %d", len(notes), len(imported))
+	}
+	for i, got := range imported {
+		want := notes[i]
+		if got.Title != want.Title || got.Body != want.Body || strings.Join(got.Tags, ",") != strings.Join(want.Tags, ",") {
+			t.Errorf("note %d: expected %q/%q/%v, got %q/%q/%v", i, want.Title, want.Body, want.Tags, got.Title, got.Body, got.Tags)
+		}
+		if got.CreatedAt == nil || !got.CreatedAt.Equal(want.CreatedAt) || got.UpdatedAt == nil || !got.UpdatedAt.Equal(want.UpdatedAt) {
+			t.Errorf("note %d: expected the timestamps to round-trip, got %v/%v", i, got.CreatedAt, got.UpdatedAt)
+		}
+	}
+	if !strings.Contains(imported[0].Source, "Plan- Q2-Q3-"+notes[0].ID.String()+".md") {
+		t.Errorf("expected a file name from the title and id, got %q", imported[0].Source)
+	}
+}
+
+func TestNoteHandler_Export_JSON(t *testing.T) {
+	notes := exportNotes()
+	h := NewNoteHandler(exportingService(notes, nil))
+
+	rr := httptest.NewRecorder()
+	h.Export(rr, exportRequest("json"))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var doc struct {
+		Version    int          `json:"version"`
+		ExportedAt time.Time    `json:"exported_at"`
+		Notes      []NoteExport `json:"notes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode: %v: %s", err, rr.Body.String())
+	}
+	if doc.Version != exportSchemaVersion || doc.ExportedAt.IsZero() || len(doc.Notes) != 2 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	if doc.Notes[0].ID != notes[0].ID || !doc.Notes[0].Pinned || doc.Notes[1].NotebookID == nil || !doc.Notes[1].Archived {
+		t.Errorf("unexpected notes %+v", doc.Notes)
+	}
+
+	rr = httptest.NewRecorder()
+	NewNoteHandler(exportingService(nil, nil)).Export(rr, exportRequest("json"))
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil || doc.Notes == nil || len(doc.Notes) != 0 {
+		t.Fatalf("expected an empty notes list, got %s", rr.Body.String())
+	}
+}
+
+func TestNoteHandler_Export_Errors(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewNoteHandler(&mockNoteService{}).Export(rr, exportRequest("csv"))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an unknown format, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	// Before the first note the client still gets an error response
+	rr = httptest.NewRecorder()
+	NewNoteHandler(exportingService(nil, errors.New("db down"))).Export(rr, exportRequest("zip"))
+	if rr.Code != http.StatusInternalServerError || rr.Header().Get("Content-Disposition") != "" {
+		t.Fatalf("expected a plain error, got %d with %q", rr.Code, rr.Header().Get("Content-Disposition"))
+	}
+
+	// After it the connection is aborted
+	defer func() {
+		if r := recover(); r != http.ErrAbortHandler {
+			t.Fatalf("expected the handler to abort, got %v", r)
+		}
+	}()
+	NewNoteHandler(exportingService(exportNotes(), errors.New("db down"))).Export(httptest.NewRecorder(), exportRequest("json"))
+}
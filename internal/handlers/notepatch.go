@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+// Media types accepted by PATCH /api/notes/{id}. Plain application/json is
+// read as a merge patch.
+const (
+	mergePatchMediaType = "application/merge-patch+json"
+	jsonPatchMediaType  = "application/json-patch+json"
+	acceptPatch         = mergePatchMediaType + ", " + jsonPatchMediaType
+)
+
+// errPatchTestFailed is a JSON Patch "test" operation that did not match. It
+// is reported as a conflict rather than a bad request.
+var errPatchTestFailed = errors.New("patch test operation failed")
+
+// noteFieldError is a client-facing validation message for note input.
+type noteFieldError string
+
+func (e noteFieldError) Error() string { return string(e) }
+
+// validateNoteTitle trims a title and checks its length. Create, Update and
+// Patch share it.
+func validateNoteTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" || len(title) > 200 {
+		return "", noteFieldError("Title must be between 1 and 200 characters")
+	}
+	return title, nil
+}
+
+// validateNoteBody trims a body and checks its length.
+func validateNoteBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || len(body) > 5000 {
+		return "", noteFieldError("Body must be between 1 and 5000 characters")
+	}
+	return body, nil
+}
+
+// noteDocument is the JSON document patches apply to: the note's writable
+// fields as encoding/json would decode them. New writable fields are added
+// here and in notePatchFromDocument.
+func noteDocument(note *models.Note) map[string]any {
+	tags := make([]any, len(note.Tags))
+	for i, tag := range note.Tags {
+		tags[i] = tag
+	}
+	var notebookID any
+	if note.NotebookID != nil {
+		notebookID = note.NotebookID.String()
+	}
+	return map[string]any{
+		"title":       note.Title,
+		"body":        note.Body,
+		"tags":        tags,
+		"notebook_id": notebookID,
+		"pinned":      note.Pinned,
+		"archived":    note.Archived,
+	}
+}
+
+// notePatchFromDocument validates a patched document and returns the changes
+// from note. changed is false when the patch left every field as it was.
+func notePatchFromDocument(note *models.Note, doc map[string]any) (patch models.NotePatch, changed bool, err error) {
+	for field := range doc {
+		switch field {
+		case "title", "body", "tags", "notebook_id", "pinned", "archived":
+		default:
+			return patch, false, noteFieldError(fmt.Sprintf("%s cannot be patched", field))
+		}
+	}
+
+	title, _ := doc["title"].(string)
+	if title, err = validateNoteTitle(title); err != nil {
+		return patch, false, err
+	}
+	if title != note.Title {
+		patch.Title = &title
+	}
+
+	body, _ := doc["body"].(string)
+	if body, err = validateNoteBody(body); err != nil {
+		return patch, false, err
+	}
+	if body != note.Body {
+		patch.Body = &body
+	}
+
+	// A removed or null tag list clears the tags.
+	names := []string{}
+	if raw, ok := doc["tags"].([]any); ok {
+		for _, v := range raw {
+			name, ok := v.(string)
+			if !ok {
+				return patch, false, services.ErrInvalidTag
+			}
+			names = append(names, name)
+		}
+	} else if doc["tags"] != nil {
+		return patch, false, noteFieldError("tags must be a list of strings")
+	}
+	tags, err := services.NormalizeTags(names)
+	if err != nil {
+		return patch, false, err
+	}
+	if !slices.Equal(tags, note.Tags) {
+		patch.Tags = tags
+	}
+
+	var notebookID *uuid.UUID
+	switch v := doc["notebook_id"].(type) {
+	case nil:
+	case string:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return patch, false, noteFieldError("Invalid notebook id")
+		}
+		notebookID = &id
+	default:
+		return patch, false, noteFieldError("Invalid notebook id")
+	}
+	if !reflect.DeepEqual(notebookID, note.NotebookID) {
+		patch.SetNotebook = true
+		patch.NotebookID = notebookID
+	}
+
+	pinned, err := documentFlag(doc, "pinned")
+	if err != nil {
+		return patch, false, err
+	}
+	if pinned != note.Pinned {
+		patch.Pinned = &pinned
+	}
+
+	archived, err := documentFlag(doc, "archived")
+	if err != nil {
+		return patch, false, err
+	}
+	if archived != note.Archived {
+		patch.Archived = &archived
+	}
+
+	changed = patch.Title != nil || patch.Body != nil || patch.Tags != nil || patch.SetNotebook ||
+		patch.Pinned != nil || patch.Archived != nil
+	return patch, changed, nil
+}
+
+// documentFlag reads a boolean field of a patched document. A removed or
+// null flag is false.
+func documentFlag(doc map[string]any, field string) (bool, error) {
+	switch v := doc[field].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	default:
+		return false, noteFieldError(field + " must be true or false")
+	}
+}
+
+// decodeJSON decodes data into a generic value, keeping numbers exact.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+// applyMergePatch applies an RFC 7396 JSON Merge Patch to doc.
+func applyMergePatch(doc map[string]any, data []byte) (map[string]any, error) {
+	patch, err := decodeJSON(data)
+	if err != nil {
+		return nil, noteFieldError("Invalid merge patch")
+	}
+	obj, ok := patch.(map[string]any)
+	if !ok {
+		return nil, noteFieldError("A merge patch must be a JSON object")
+	}
+	return mergePatch(doc, obj).(map[string]any), nil
+}
+
+func mergePatch(target, patch any) any {
+	obj, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	result, ok := target.(map[string]any)
+	if !ok {
+		result = map[string]any{}
+	}
+	for key, value := range obj {
+		if value == nil {
+			delete(result, key)
+		} else {
+			result[key] = mergePatch(result[key], value)
+		}
+	}
+	return result
+}
+
+// jsonPatchOp is one RFC 6902 operation. Value is nil when the member is
+// absent, which add, replace and test reject.
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// applyJSONPatch applies an RFC 6902 JSON Patch to doc. Operations apply in
+// order and any failure rejects the whole patch.
+func applyJSONPatch(doc map[string]any, data []byte) (map[string]any, error) {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, noteFieldError("A JSON patch must be an array of operations")
+	}
+
+	var root any = doc
+	for i, op := range ops {
+		var err error
+		root, err = applyJSONPatchOp(root, op)
+		if errors.Is(err, errPatchTestFailed) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, noteFieldError(fmt.Sprintf("Patch operation %d (%s %s): %v", i, op.Op, op.Path, err))
+		}
+	}
+	result, ok := root.(map[string]any)
+	if !ok {
+		return nil, noteFieldError("A JSON patch must leave an object")
+	}
+	return result, nil
+}
+
+func applyJSONPatchOp(root any, op jsonPatchOp) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		if value, err = decodeJSON(op.Value); err != nil {
+			return nil, errors.New("invalid value")
+		}
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = pointerGet(root, from); err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if slices.Equal(from, path[:min(len(from), len(path))]) && len(path) > len(from) {
+				return nil, errors.New("cannot move a value into itself")
+			}
+			if root, err = pointerRemove(root, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopy(value)
+		}
+	case "remove":
+	default:
+		return nil, fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	switch op.Op {
+	case "remove":
+		return pointerRemove(root, path)
+	case "replace":
+		if root, err = pointerRemove(root, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(root, path, value)
+	case "test":
+		current, err := pointerGet(root, path)
+		if err != nil || !jsonEqual(current, value) {
+			return nil, errPatchTestFailed
+		}
+		return root, nil
+	default: // add, move, copy
+		return pointerAdd(root, path, value)
+	}
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// parsePointer splits an RFC 6901 JSON Pointer into reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// arrayIndex resolves an array reference token. "-" (one past the end) is
+// only allowed when appending.
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if token == "-" && appending {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	limit := length - 1
+	if appending {
+		limit = length
+	}
+	if err != nil || i < 0 || i > limit {
+		return 0, fmt.Errorf("array index %q out of range", token)
+	}
+	return i, nil
+}
+
+func pointerGet(node any, path []string) (any, error) {
+	for _, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			node = child
+		case []any:
+			i, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+	}
+	return node, nil
+}
+
+// pointerUpdate replaces the container at path[:len(path)-1] with the result
+// of fn and returns the new root. Slices may be reallocated, so every level
+// stores the updated child back into its parent.
+func pointerUpdate(node any, path []string, fn func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("%q does not exist", path[0])
+		}
+		updated, err := pointerUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[path[0]] = updated
+		return n, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerUpdate(n[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%q does not exist", path[0])
+	}
+}
+
+func pointerAdd(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(root, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			c[token] = value
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(c, i, value), nil
+		default:
+			return nil, fmt.Errorf("cannot add %q to a scalar", token)
+		}
+	})
+}
+
+func pointerRemove(root any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("cannot remove the whole document")
+	}
+	return pointerUpdate(root, path, func(container any, token string) (any, error) {
+		switch c := container.(type) {
+		case map[string]any:
+			if _, ok := c[token]; !ok {
+				return nil, fmt.Errorf("%q does not exist", token)
+			}
+			delete(c, token)
+			return c, nil
+		case []any:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Delete(c, i, i+1), nil
+		default:
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+	})
+}
+
+func deepCopy(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(t))
+		for k, v := range t {
+			c[k] = deepCopy(v)
+		}
+		return c
+	case []any:
+		c := make([]any, len(t))
+		for i, v := range t {
+			c[i] = deepCopy(v)
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+// jsonEqual compares two decoded JSON values, treating numbers by value.
+func jsonEqual(a, b any) bool {
+	if an, ok := a.(json.Number); ok {
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		af, aerr := an.Float64()
+		bf, berr := bn.Float64()
+		return aerr == nil && berr == nil && af == bf
+	}
+	switch at := a.(type) {
+	case map[string]any:
+		bt, ok := b.(map[string]any)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, v := range at {
+			bv, ok := bt[k]
+			if !ok || !jsonEqual(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		bt, ok := b.([]any)
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !jsonEqual(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+		err   bool
+	}{
+		{"replace", `[{"op":"replace","path":"/title","value":"New"}]`, `{"body":"B","tags":["a","b"],"title":"New"}`, false},
+		{"append tag", `[{"op":"add","path":"/tags/-","value":"c"}]`, `{"body":"B","tags":["a","b","c"],"title":"T"}`, false},
+		{"insert tag", `[{"op":"add","path":"/tags/0","value":"z"}]`, `{"body":"B","tags":["z","a","b"],"title":"T"}`, false},
+		{"remove tag", `[{"op":"remove","path":"/tags/0"}]`, `{"body":"B","tags":["b"],"title":"T"}`, false},
+		{"copy", `[{"op":"copy","from":"/title","path":"/body"}]`, `{"body":"T","tags":["a","b"],"title":"T"}`, false},
+		{"move", `[{"op":"move","from":"/tags/1","path":"/tags/0"}]`, `{"body":"B","tags":["b","a"],"title":"T"}`, false},
+		{"escaped pointer", `[{"op":"add","path":"/a~1b~0c","value":1}]`, `{"a/b~c":1,"body":"B","tags":["a","b"],"title":"T"}`, false},
+		{"test passes", `[{"op":"test","path":"/tags","value":["a","b"]},{"op":"remove","path":"/body"}]`, `{"tags":["a","b"],"title":"T"}`, false},
+		{"index out of range", `[{"op":"remove","path":"/tags/2"}]`, "", true},
+		{"leading zero index", `[{"op":"add","path":"/tags/01","value":"x"}]`, "", true},
+		{"missing value", `[{"op":"add","path":"/title"}]`, "", true},
+		{"replace missing", `[{"op":"replace","path":"/nope","value":1}]`, "", true},
+		{"unknown op", `[{"op":"frob","path":"/title"}]`, "", true},
+		{"move into child", `[{"op":"move","from":"/tags","path":"/tags/0"}]`, "", true},
+		{"not an array", `{"op":"add"}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := map[string]any{"title": "T", "body": "B", "tags": []any{"a", "b"}}
+			got, err := applyJSONPatch(doc, []byte(tt.patch))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if data, _ := json.Marshal(got); string(data) != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, data)
+			}
+		})
+	}
+}
+
+func TestApplyJSONPatch_TestFailed(t *testing.T) {
+	doc := map[string]any{"title": "T"}
+	_, err := applyJSONPatch(doc, []byte(`[{"op":"test","path":"/title","value":"Other"}]`))
+	if !errors.Is(err, errPatchTestFailed) {
+		t.Fatalf("expected errPatchTestFailed, got %v", err)
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	doc := map[string]any{"title": "T", "body": "B", "tags": []any{"a"}, "notebook_id": "x"}
+	got, err := applyMergePatch(doc, []byte(`{"title":"New","tags":null,"notebook_id":null}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := json.Marshal(got); string(data) != `{"body":"B","title":"New"}` {
+		t.Fatalf("unexpected result %s", data)
+	}
+
+	if _, err := applyMergePatch(doc, []byte(`["title"]`)); err == nil {
+		t.Fatal("expected an error for a non-object merge patch")
+	}
+}
+
+func patchRequest(noteID uuid.UUID, contentType, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPatch, "/api/notes/"+noteID.String(), strings.NewReader(body))
+	req.SetPathValue("id", noteID.String())
+	req.Header.Set("Content-Type", contentType)
+	return req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+}
+
+func TestNoteHandler_Patch_MergePatchWritesChangedFields(t *testing.T) {
+	notebookID := uuid.New()
+	note := &models.Note{ID: uuid.New(), Title: "Title", Body: "Body", Tags: []string{"work"}, NotebookID: &notebookID, Version: 2}
+	var got models.NotePatch
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+		patch: func(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+			got = patch
+			return &models.Note{ID: noteID, Title: "Title", Body: "New body", Version: 3}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Patch(rr, patchRequest(note.ID, "application/merge-patch+json", `{"body":"  New body ","title":"Title"}`))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got.Title != nil || got.Body == nil || *got.Body != "New body" || got.Tags != nil || got.SetNotebook {
+		t.Fatalf("expected only the body to change, got %+v", got)
+	}
+	if got.Version != 2 {
+		t.Fatalf("expected the write to be conditional on the version read, got %d", got.Version)
+	}
+	if rr.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected ETag \"3\", got %q", rr.Header().Get("ETag"))
+	}
+}
+
+func TestNoteHandler_Patch_JSONPatchTags(t *testing.T) {
+	note := &models.Note{ID: uuid.New(), Title: "Title", Body: "Body", Tags: []string{"work"}, Version: 1}
+	var got models.NotePatch
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+		patch: func(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+			got = patch
+			return note, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Patch(rr, patchRequest(note.ID, "application/json-patch+json", `[{"op":"add","path":"/tags/-","value":"#Ideas"}]`))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "ideas" || got.Title != nil || got.Body != nil {
+		t.Fatalf("expected only normalized tags to change, got %+v", got)
+	}
+}
+
+func TestNoteHandler_Patch_Rejections(t *testing.T) {
+	note := &models.Note{ID: uuid.New(), Title: "Title", Body: "Body", Tags: []string{}, Version: 1}
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+		patch: func(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+			t.Fatalf("unexpected write %+v", patch)
+			return nil, nil
+		},
+	})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"unsupported media type", "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{"read-only field", "application/merge-patch+json", `{"version":9}`, http.StatusBadRequest},
+		{"title removed", "application/merge-patch+json", `{"title":null}`, http.StatusBadRequest},
+		{"body too long", "application/merge-patch+json", `{"body":"` + strings.Repeat("x", 5001) + `"}`, http.StatusBadRequest},
+		{"bad tag", "application/merge-patch+json", `{"tags":[1]}`, http.StatusBadRequest},
+		{"bad notebook", "application/merge-patch+json", `{"notebook_id":"nope"}`, http.StatusBadRequest},
+		{"bad pinned", "application/merge-patch+json", `{"pinned":"yes"}`, http.StatusBadRequest},
+		{"failed test", "application/json-patch+json", `[{"op":"test","path":"/title","value":"Other"}]`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		h.Patch(rr, patchRequest(note.ID, tt.contentType, tt.body))
+		if rr.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d: %s", tt.name, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestNoteHandler_Patch_NoChange(t *testing.T) {
+	note := &models.Note{ID: uuid.New(), Title: "Title", Body: "Body", Tags: []string{}, Version: 5}
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Patch(rr, patchRequest(note.ID, "application/json", `{"title":"Title"}`))
+
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"5"` {
+		t.Fatalf("expected the unchanged note, got %d %q", rr.Code, rr.Header().Get("ETag"))
+	}
+}
+
+func TestNoteHandler_Patch_RetriesConcurrentWrite(t *testing.T) {
+	version := 1
+	writes := 0
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return &models.Note{ID: noteID, Title: "Title", Body: "Body", Tags: []string{}, Version: version}, nil
+		},
+		patch: func(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+			writes++
+			if writes == 1 {
+				version = 2 // Another client saved in between
+				return nil, services.ErrNoteVersionMismatch
+			}
+			if patch.Version != 2 {
+				t.Fatalf("expected the retry to target version 2, got %d", patch.Version)
+			}
+			return &models.Note{ID: noteID, Version: 3}, nil
+		},
+	})
+
+	rr := httptest.NewRecorder()
+	h.Patch(rr, patchRequest(uuid.New(), "application/merge-patch+json", `{"title":"New"}`))
+
+	if rr.Code != http.StatusOK || writes != 2 {
+		t.Fatalf("expected a successful retry, got %d after %d writes", rr.Code, writes)
+	}
+}
+
+func TestNoteHandler_Patch_IfMatchStale(t *testing.T) {
+	note := &models.Note{ID: uuid.New(), Title: "Title", Body: "Body", Version: 4}
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+	})
+
+	req := patchRequest(note.ID, "application/merge-patch+json", `{"title":"New"}`)
+	req.Header.Set("If-Match", `"3"`)
+	rr := httptest.NewRecorder()
+	h.Patch(rr, req)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status 412, got %d", rr.Code)
+	}
+}
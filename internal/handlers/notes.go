@@ -2,12 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/example/notes-template/internal/markdown"
 	"github.com/example/notes-template/internal/models"
 	"github.com/example/notes-template/internal/services"
 )
@@ -21,8 +28,126 @@ func NewNoteHandler(noteService services.NoteServiceInterface) *NoteHandler {
 }
 
 type NoteRequest struct {
-	Title string `json:"title"`
-	Body  string `json:"body"`
+	Title string   `json:"title"`
+	Body  string   `json:"body"`
+	Tags  []string `json:"tags"` // Omit on update to keep the note's current tags
+	// NotebookID is a notebook id or null for the root. Omit on update to
+	// leave the note where it is.
+	NotebookID json.RawMessage `json:"notebook_id"`
+}
+
+// notebookID parses NotebookID. set reports whether the field was sent.
+func (req NoteRequest) notebookID() (id *uuid.UUID, set bool, err error) {
+	if len(req.NotebookID) == 0 {
+		return nil, false, nil
+	}
+	if err := json.Unmarshal(req.NotebookID, &id); err != nil {
+		return nil, true, err
+	}
+	return id, true, nil
+}
+
+// writeNoteFieldError answers note validation failures, including tag errors,
+// with 400.
+func writeNoteFieldError(w http.ResponseWriter, err error) {
+	if err == services.ErrInvalidTag || err == services.ErrTooManyTags {
+		writeTagError(w, err)
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// wantRenderedHTML reports whether the request asked for ?format=html. It
+// returns a client-facing message for any other format.
+func wantRenderedHTML(q url.Values) (bool, string) {
+	switch q.Get("format") {
+	case "", "json":
+		return false, ""
+	case "html":
+		return true, ""
+	default:
+		return false, "format must be json or html"
+	}
+}
+
+// renderNotes fills in RenderedHTML from each note's Markdown body.
+func renderNotes(notes ...*models.Note) error {
+	for _, note := range notes {
+		html, err := markdown.Render(note.Body)
+		if err != nil {
+			return err
+		}
+		note.RenderedHTML = html
+	}
+	return nil
+}
+
+// writeNoteForbidden answers a write a note share does not allow.
+func writeNoteForbidden(w http.ResponseWriter) {
+	writeError(w, http.StatusForbidden, "Only editors can change a shared note, and only its owner can change its tags, notebook, pin or archive state")
+}
+
+// noteETag is the entity tag of a note's current version.
+func noteETag(note *models.Note) string {
+	return `"` + strconv.Itoa(note.Version) + `"`
+}
+
+// writeNote writes a {"note": ...} response with the note's ETag.
+func writeNote(w http.ResponseWriter, status int, note *models.Note) {
+	w.Header().Set("ETag", noteETag(note))
+	writeJSON(w, status, map[string]interface{}{"note": note})
+}
+
+// ifMatchVersion reads the note version a write expects from If-Match. It
+// returns 0 (unconditional) when the header is absent or "*", and -1, which
+// no note has, for weak, malformed or multiple tags.
+func ifMatchVersion(r *http.Request) int {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" || v == "*" {
+		return 0
+	}
+	if len(v) < 3 || v[0] != '"' || v[len(v)-1] != '"' {
+		return -1
+	}
+	version, err := strconv.Atoi(v[1 : len(v)-1])
+	if err != nil || version < 1 {
+		return -1
+	}
+	return version
+}
+
+// etagMatches reports whether an If-None-Match header matches etag using
+// weak comparison.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeNoteConflict answers a failed If-Match with 412 and the note as it now
+// stands, so the client can show what changed and retry.
+func (h *NoteHandler) writeNoteConflict(w http.ResponseWriter, r *http.Request, userID, noteID uuid.UUID) {
+	note, err := h.noteService.GetByID(r.Context(), userID, noteID)
+	if err != nil {
+		if err == services.ErrNoteNotFound {
+			writeError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error getting note after version mismatch: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("ETag", noteETag(note))
+	writeJSON(w, http.StatusPreconditionFailed, map[string]interface{}{
+		"error": "Note was changed since it was loaded",
+		"code":  "version_mismatch",
+		"note":  note,
+	})
 }
 
 func (h *NoteHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -32,14 +157,157 @@ func (h *NoteHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	notes, err := h.noteService.ListByUser(r.Context(), user.ID)
+	opts, msg := parseNoteListOptions(r.URL.Query())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	rendered, msg := wantRenderedHTML(r.URL.Query())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	page, err := h.noteService.ListByUser(r.Context(), user.ID, opts)
 	if err != nil {
+		if err == services.ErrInvalidCursor {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
 		log.Printf("Error listing notes: %v", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
+	if rendered {
+		if err := renderNotes(page.Notes...); err != nil {
+			log.Printf("Error rendering notes: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{"notes": notes})
+	writeJSON(w, http.StatusOK, page)
+}
+
+// parseNoteListOptions reads limit, sort, order, cursor, archived and the
+// created/updated range filters from the query string. It returns a client-facing message when
+// a parameter is invalid.
+func parseNoteListOptions(q url.Values) (models.NoteListOptions, string) {
+	opts := models.NoteListOptions{Cursor: q.Get("cursor")}
+
+	switch v := q.Get("notebook_id"); v {
+	case "":
+	case "root":
+		opts.Unfiled = true
+	default:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return opts, "notebook_id must be a notebook id or root"
+		}
+		opts.NotebookID = &id
+	}
+
+	if v := q.Get("tag"); v != "" {
+		tag, err := services.NormalizeTagName(v)
+		if err != nil {
+			return opts, "Invalid tag"
+		}
+		opts.Tag = tag
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > services.MaxNoteListLimit {
+			return opts, fmt.Sprintf("limit must be between 1 and %d", services.MaxNoteListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	switch opts.Sort = q.Get("sort"); opts.Sort {
+	case "", models.NoteSortUpdated, models.NoteSortCreated:
+	case models.NoteSortTitle:
+		// Titles read naturally A to Z; dates newest first.
+		opts.Ascending = true
+	default:
+		return opts, "sort must be one of created, updated or title"
+	}
+
+	switch q.Get("archived") {
+	case "", "false":
+	case "true":
+		opts.Archived = true
+	default:
+		return opts, "archived must be true or false"
+	}
+
+	switch q.Get("order") {
+	case "":
+	case "asc":
+		opts.Ascending = true
+	case "desc":
+		opts.Ascending = false
+	default:
+		return opts, "order must be asc or desc"
+	}
+
+	for _, f := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_after", &opts.CreatedAfter},
+		{"created_before", &opts.CreatedBefore},
+		{"updated_after", &opts.UpdatedAfter},
+		{"updated_before", &opts.UpdatedBefore},
+	} {
+		v := q.Get(f.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, f.name + " must be an RFC 3339 timestamp"
+		}
+		*f.dest = &t
+	}
+
+	return opts, ""
+}
+
+func (h *NoteHandler) Search(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || len(query) > 200 {
+		writeError(w, http.StatusBadRequest, "Search query must be between 1 and 200 characters")
+		return
+	}
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > services.MaxNoteListLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", services.MaxNoteListLimit))
+			return
+		}
+		limit = n
+	}
+
+	results, err := h.noteService.Search(r.Context(), user.ID, query, limit)
+	if err != nil {
+		if err == services.ErrEmptySearch {
+			writeError(w, http.StatusBadRequest, "Search query must contain a word")
+			return
+		}
+		log.Printf("Error searching notes: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results})
 }
 
 func (h *NoteHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -55,29 +323,45 @@ func (h *NoteHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req.Title = strings.TrimSpace(req.Title)
-	req.Body = strings.TrimSpace(req.Body)
-	if req.Title == "" || len(req.Title) > 200 {
-		writeError(w, http.StatusBadRequest, "Title must be between 1 and 200 characters")
+	title, err := validateNoteTitle(req.Title)
+	if err != nil {
+		writeNoteFieldError(w, err)
+		return
+	}
+	body, err := validateNoteBody(req.Body)
+	if err != nil {
+		writeNoteFieldError(w, err)
+		return
+	}
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		writeNoteFieldError(w, err)
 		return
 	}
-	if req.Body == "" || len(req.Body) > 5000 {
-		writeError(w, http.StatusBadRequest, "Body must be between 1 and 5000 characters")
+	notebookID, _, err := req.notebookID()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
 		return
 	}
 
 	note, err := h.noteService.Create(r.Context(), models.CreateNoteParams{
-		UserID: user.ID,
-		Title:  req.Title,
-		Body:   req.Body,
+		UserID:     user.ID,
+		NotebookID: notebookID,
+		Title:      title,
+		Body:       body,
+		Tags:       tags,
 	})
 	if err != nil {
+		if err == services.ErrNotebookNotFound {
+			writeError(w, http.StatusNotFound, "Notebook not found")
+			return
+		}
 		log.Printf("Error creating note: %v", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, map[string]interface{}{"note": note})
+	writeNote(w, http.StatusCreated, note)
 }
 
 func (h *NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
@@ -99,32 +383,156 @@ func (h *NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	req.Title = strings.TrimSpace(req.Title)
-	req.Body = strings.TrimSpace(req.Body)
-	if req.Title == "" || len(req.Title) > 200 {
-		writeError(w, http.StatusBadRequest, "Title must be between 1 and 200 characters")
+	title, err := validateNoteTitle(req.Title)
+	if err != nil {
+		writeNoteFieldError(w, err)
 		return
 	}
-	if req.Body == "" || len(req.Body) > 5000 {
-		writeError(w, http.StatusBadRequest, "Body must be between 1 and 5000 characters")
+	body, err := validateNoteBody(req.Body)
+	if err != nil {
+		writeNoteFieldError(w, err)
+		return
+	}
+	tags, err := services.NormalizeTags(req.Tags)
+	if err != nil {
+		writeNoteFieldError(w, err)
+		return
+	}
+	notebookID, setNotebook, err := req.notebookID()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid notebook id")
 		return
 	}
 
 	note, err := h.noteService.Update(r.Context(), user.ID, noteID, models.UpdateNoteParams{
-		Title: req.Title,
-		Body:  req.Body,
+		Title:       title,
+		Body:        body,
+		Tags:        tags,
+		SetNotebook: setNotebook,
+		NotebookID:  notebookID,
+		Version:     ifMatchVersion(r),
 	})
 	if err != nil {
 		if err == services.ErrNoteNotFound {
 			writeError(w, http.StatusNotFound, "Note not found")
 			return
 		}
+		if err == services.ErrNoteForbidden {
+			writeNoteForbidden(w)
+			return
+		}
+		if err == services.ErrNoteVersionMismatch {
+			h.writeNoteConflict(w, r, user.ID, noteID)
+			return
+		}
+		if err == services.ErrNotebookNotFound {
+			writeError(w, http.StatusNotFound, "Notebook not found")
+			return
+		}
 		log.Printf("Error updating note: %v", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{"note": note})
+	writeNote(w, http.StatusOK, note)
+}
+
+// Patch applies a JSON Merge Patch (RFC 7396) or JSON Patch (RFC 6902) to a
+// note's writable fields and writes only the fields that changed. Without
+// If-Match, a concurrent write between reading and saving the note makes
+// Patch re-apply the patch to the newer version.
+func (h *NoteHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	var apply func(doc map[string]any, data []byte) (map[string]any, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mergePatchMediaType, "application/json":
+		apply = applyMergePatch
+	case jsonPatchMediaType:
+		apply = applyJSONPatch
+	default:
+		w.Header().Set("Accept-Patch", acceptPatch)
+		writeError(w, http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchMediaType+" or "+jsonPatchMediaType)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 64<<10))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	ifMatch := ifMatchVersion(r)
+	for attempt := 1; ; attempt++ {
+		note, err := h.noteService.GetByID(r.Context(), user.ID, noteID)
+		if err != nil {
+			if err == services.ErrNoteNotFound {
+				writeError(w, http.StatusNotFound, "Note not found")
+				return
+			}
+			log.Printf("Error getting note for patch: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		if ifMatch != 0 && ifMatch != note.Version {
+			h.writeNoteConflict(w, r, user.ID, noteID)
+			return
+		}
+
+		doc, err := apply(noteDocument(note), data)
+		if err == errPatchTestFailed {
+			writeError(w, http.StatusConflict, "Patch test operation failed")
+			return
+		}
+		if err != nil {
+			writeNoteFieldError(w, err)
+			return
+		}
+		patch, changed, err := notePatchFromDocument(note, doc)
+		if err != nil {
+			writeNoteFieldError(w, err)
+			return
+		}
+		if !changed {
+			writeNote(w, http.StatusOK, note)
+			return
+		}
+
+		patch.Version = note.Version
+		updated, err := h.noteService.Patch(r.Context(), user.ID, noteID, patch)
+		if err == services.ErrNoteVersionMismatch && ifMatch == 0 && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			switch err {
+			case services.ErrNoteNotFound:
+				writeError(w, http.StatusNotFound, "Note not found")
+			case services.ErrNotebookNotFound:
+				writeError(w, http.StatusNotFound, "Notebook not found")
+			case services.ErrNoteForbidden:
+				writeNoteForbidden(w)
+			case services.ErrNoteVersionMismatch:
+				h.writeNoteConflict(w, r, user.ID, noteID)
+			default:
+				log.Printf("Error patching note: %v", err)
+				writeError(w, http.StatusInternalServerError, "Internal server error")
+			}
+			return
+		}
+
+		writeNote(w, http.StatusOK, updated)
+		return
+	}
 }
 
 func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
@@ -140,17 +548,141 @@ func (h *NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.noteService.Delete(r.Context(), user.ID, noteID); err != nil {
+	if err := h.noteService.Delete(r.Context(), user.ID, noteID, ifMatchVersion(r)); err != nil {
 		if err == services.ErrNoteNotFound {
 			writeError(w, http.StatusNotFound, "Note not found")
 			return
 		}
+		if err == services.ErrNoteForbidden {
+			writeNoteForbidden(w)
+			return
+		}
+		if err == services.ErrNoteVersionMismatch {
+			h.writeNoteConflict(w, r, user.ID, noteID)
+			return
+		}
 		log.Printf("Error deleting note: %v", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"message": "Note deleted"})
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Note moved to trash"})
+}
+
+// Pin, Unpin, Archive and Unarchive set one of the owner's list flags on a
+// note. They answer with the note and honor If-Match like the other writes.
+func (h *NoteHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	pinned := true
+	h.setNoteFlags(w, r, models.NotePatch{Pinned: &pinned})
+}
+
+func (h *NoteHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	pinned := false
+	h.setNoteFlags(w, r, models.NotePatch{Pinned: &pinned})
+}
+
+func (h *NoteHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	archived := true
+	h.setNoteFlags(w, r, models.NotePatch{Archived: &archived})
+}
+
+func (h *NoteHandler) Unarchive(w http.ResponseWriter, r *http.Request) {
+	archived := false
+	h.setNoteFlags(w, r, models.NotePatch{Archived: &archived})
+}
+
+func (h *NoteHandler) setNoteFlags(w http.ResponseWriter, r *http.Request, patch models.NotePatch) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	patch.Version = ifMatchVersion(r)
+	note, err := h.noteService.Patch(r.Context(), user.ID, noteID, patch)
+	if err != nil {
+		switch err {
+		case services.ErrNoteNotFound:
+			writeError(w, http.StatusNotFound, "Note not found")
+		case services.ErrNoteForbidden:
+			writeNoteForbidden(w)
+		case services.ErrNoteVersionMismatch:
+			h.writeNoteConflict(w, r, user.ID, noteID)
+		default:
+			log.Printf("Error updating note: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeNote(w, http.StatusOK, note)
+}
+
+func (h *NoteHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notes, err := h.noteService.ListTrash(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing trash: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notes": notes})
+}
+
+func (h *NoteHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	note, err := h.noteService.Restore(r.Context(), user.ID, noteID)
+	if err != nil {
+		if err == services.ErrNoteNotInTrash {
+			writeError(w, http.StatusNotFound, "Note not found in trash")
+			return
+		}
+		log.Printf("Error restoring note: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeNote(w, http.StatusOK, note)
+}
+
+func (h *NoteHandler) EmptyTrash(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	deleted, err := h.noteService.EmptyTrash(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error emptying trash: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"deleted": deleted})
 }
 
 func (h *NoteHandler) Get(w http.ResponseWriter, r *http.Request) {
@@ -166,6 +698,12 @@ func (h *NoteHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rendered, msg := wantRenderedHTML(r.URL.Query())
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
 	note, err := h.noteService.GetByID(r.Context(), user.ID, noteID)
 	if err != nil {
 		if err == services.ErrNoteNotFound {
@@ -177,5 +715,18 @@ func (h *NoteHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]interface{}{"note": note})
+	etag := noteETag(note)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if rendered {
+		if err := renderNotes(note); err != nil {
+			log.Printf("Error rendering note: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
+	writeNote(w, http.StatusOK, note)
 }
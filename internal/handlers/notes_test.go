@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -17,18 +18,30 @@ import (
 
 type mockNoteService struct {
 	create func(ctx context.Context, params models.CreateNoteParams) (*models.Note, error)
-	list   func(ctx context.Context, userID uuid.UUID) ([]*models.Note, error)
+	list   func(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error)
+	search func(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error)
 	get    func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error)
 	update func(ctx context.Context, userID, noteID uuid.UUID, params models.UpdateNoteParams) (*models.Note, error)
-	delete func(ctx context.Context, userID, noteID uuid.UUID) error
+	patch  func(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error)
+	delete func(ctx context.Context, userID, noteID uuid.UUID, version int) error
+
+	listTrash  func(ctx context.Context, userID uuid.UUID) ([]*models.Note, error)
+	restore    func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error)
+	emptyTrash func(ctx context.Context, userID uuid.UUID) (int64, error)
+	bulk       func(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error)
+	export     func(ctx context.Context, userID uuid.UUID, fn func(*models.Note) error) error
 }
 
 func (m *mockNoteService) Create(ctx context.Context, params models.CreateNoteParams) (*models.Note, error) {
 	return m.create(ctx, params)
 }
 
-func (m *mockNoteService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Note, error) {
-	return m.list(ctx, userID)
+func (m *mockNoteService) ListByUser(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error) {
+	return m.list(ctx, userID, opts)
+}
+
+func (m *mockNoteService) Search(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error) {
+	return m.search(ctx, userID, query, limit)
 }
 
 func (m *mockNoteService) GetByID(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
@@ -39,8 +52,32 @@ func (m *mockNoteService) Update(ctx context.Context, userID, noteID uuid.UUID,
 	return m.update(ctx, userID, noteID, params)
 }
 
-func (m *mockNoteService) Delete(ctx context.Context, userID, noteID uuid.UUID) error {
-	return m.delete(ctx, userID, noteID)
+func (m *mockNoteService) Patch(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+	return m.patch(ctx, userID, noteID, patch)
+}
+
+func (m *mockNoteService) Delete(ctx context.Context, userID, noteID uuid.UUID, version int) error {
+	return m.delete(ctx, userID, noteID, version)
+}
+
+func (m *mockNoteService) ListTrash(ctx context.Context, userID uuid.UUID) ([]*models.Note, error) {
+	return m.listTrash(ctx, userID)
+}
+
+func (m *mockNoteService) Restore(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+	return m.restore(ctx, userID, noteID)
+}
+
+func (m *mockNoteService) EmptyTrash(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.emptyTrash(ctx, userID)
+}
+
+func (m *mockNoteService) Bulk(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error) {
+	return m.bulk(ctx, userID, ops, atomic)
+}
+
+func (m *mockNoteService) Export(ctx context.Context, userID uuid.UUID, fn func(*models.Note) error) error {
+	return m.export(ctx, userID, fn)
 }
 
 func TestNoteHandler_List(t *testing.T) {
@@ -48,8 +85,8 @@ func TestNoteHandler_List(t *testing.T) {
 	note := &models.Note{ID: uuid.New(), UserID: user.ID, Title: "Title", Body: "Body", CreatedAt: time.Now(), UpdatedAt: time.Now()}
 
 	service := &mockNoteService{
-		list: func(ctx context.Context, userID uuid.UUID) ([]*models.Note, error) {
-			return []*models.Note{note}, nil
+		list: func(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error) {
+			return &models.NotePage{Notes: []*models.Note{note}}, nil
 		},
 	}
 
@@ -74,6 +111,202 @@ func TestNoteHandler_List(t *testing.T) {
 	}
 }
 
+func TestNoteHandler_List_Options(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	var got models.NoteListOptions
+	service := &mockNoteService{
+		list: func(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error) {
+			got = opts
+			return &models.NotePage{Notes: []*models.Note{}, NextCursor: "next"}, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	req := httptest.NewRequest(http.MethodGet, "/api/notes?limit=10&sort=title&cursor=abc&created_after=2024-01-01T00:00:00Z&tag=%23Work&archived=true", nil)
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.List(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if got.Limit != 10 || got.Sort != models.NoteSortTitle || !got.Ascending || got.Cursor != "abc" {
+		t.Fatalf("unexpected options: %+v", got)
+	}
+	if got.Tag != "work" {
+		t.Fatalf("expected normalized tag filter, got %q", got.Tag)
+	}
+	if !got.Archived {
+		t.Fatal("expected the archived filter")
+	}
+	if got.CreatedAfter == nil || got.CreatedAfter.Year() != 2024 {
+		t.Fatalf("expected created_after to be parsed, got %v", got.CreatedAfter)
+	}
+
+	var payload models.NotePage
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if payload.NextCursor != "next" {
+		t.Fatalf("expected next_cursor, got %q", payload.NextCursor)
+	}
+}
+
+func TestNoteHandler_List_InvalidParams(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	service := &mockNoteService{
+		list: func(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error) {
+			if opts.Cursor == "bad" {
+				return nil, services.ErrInvalidCursor
+			}
+			t.Fatal("list should not be called")
+			return nil, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	for _, query := range []string{"limit=0", "limit=1000", "sort=body", "order=up", "updated_before=yesterday", "notebook_id=inbox", "archived=yes", "cursor=bad"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/notes?"+query, nil)
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.List(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestNoteHandler_PinAndArchive(t *testing.T) {
+	noteID := uuid.New()
+	var got models.NotePatch
+	h := NewNoteHandler(&mockNoteService{
+		patch: func(ctx context.Context, userID, id uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+			got = patch
+			return &models.Note{ID: id, Version: 4}, nil
+		},
+	})
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		pinned   *bool
+		archived *bool
+	}{
+		{"pin", h.Pin, boolPtr(true), nil},
+		{"unpin", h.Unpin, boolPtr(false), nil},
+		{"archive", h.Archive, nil, boolPtr(true)},
+		{"unarchive", h.Unarchive, nil, boolPtr(false)},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		req.SetPathValue("id", noteID.String())
+		req.Header.Set("If-Match", `"3"`)
+		req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+		rr := httptest.NewRecorder()
+
+		tt.handler(rr, req)
+
+		if rr.Code != http.StatusOK || rr.Header().Get("ETag") != `"4"` {
+			t.Fatalf("%s: expected the note with its new ETag, got %d %q", tt.name, rr.Code, rr.Header().Get("ETag"))
+		}
+		if !reflect.DeepEqual(got, models.NotePatch{Pinned: tt.pinned, Archived: tt.archived, Version: 3}) {
+			t.Fatalf("%s: unexpected patch %+v", tt.name, got)
+		}
+	}
+}
+
+func TestNoteHandler_Pin_Errors(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{services.ErrNoteNotFound, http.StatusNotFound},
+		{services.ErrNoteForbidden, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		h := NewNoteHandler(&mockNoteService{
+			patch: func(ctx context.Context, userID, id uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+				return nil, tt.err
+			},
+		})
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		req.SetPathValue("id", uuid.NewString())
+		req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+		rr := httptest.NewRecorder()
+
+		h.Pin(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%v: expected status %d, got %d", tt.err, tt.want, rr.Code)
+		}
+	}
+}
+
+func boolPtr(v bool) *bool { return &v }
+
+func TestNoteHandler_Search(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	note := &models.Note{ID: uuid.New(), UserID: user.ID, Title: "Title", Body: "Body"}
+	var gotQuery string
+	service := &mockNoteService{
+		search: func(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error) {
+			if userID != user.ID {
+				t.Fatalf("expected search scoped to %s, got %s", user.ID, userID)
+			}
+			gotQuery = query
+			return []*models.NoteSearchResult{{Note: note, Rank: 0.5, Snippet: "<mark>Body</mark>"}}, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/search?q=%22exact+phrase%22+pre*", nil)
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.Search(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	if gotQuery != `"exact phrase" pre*` {
+		t.Fatalf("unexpected query %q", gotQuery)
+	}
+	var payload struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if len(payload.Results) != 1 || payload.Results[0]["title"] != "Title" || payload.Results[0]["snippet"] != "<mark>Body</mark>" {
+		t.Fatalf("unexpected results: %v", payload.Results)
+	}
+}
+
+func TestNoteHandler_Search_Invalid(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	service := &mockNoteService{
+		search: func(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error) {
+			return nil, services.ErrEmptySearch
+		},
+	}
+
+	h := NewNoteHandler(service)
+	for _, query := range []string{"", "q=+++", "q=a&limit=0", "q=%2A%2A"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/notes/search?"+query, nil)
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.Search(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected status 400, got %d", query, rr.Code)
+		}
+	}
+}
+
 func TestNoteHandler_Create_Invalid(t *testing.T) {
 	user := &models.User{ID: uuid.New()}
 	service := &mockNoteService{
@@ -96,6 +329,88 @@ func TestNoteHandler_Create_Invalid(t *testing.T) {
 	}
 }
 
+func TestNoteHandler_Create_Tags(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	var got []string
+	service := &mockNoteService{
+		create: func(ctx context.Context, params models.CreateNoteParams) (*models.Note, error) {
+			got = params.Tags
+			return &models.Note{Tags: params.Tags}, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"title":"T","body":"B","tags":["#Work","work","Home"]}`, http.StatusCreated},
+		{`{"title":"T","body":"B","tags":["a,b"]}`, http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/notes", strings.NewReader(tc.body))
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.Create(rr, req)
+
+		if rr.Code != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.body, tc.status, rr.Code)
+		}
+	}
+	if strings.Join(got, "|") != "home|work" {
+		t.Fatalf("expected normalized tags, got %v", got)
+	}
+}
+
+func TestNoteHandler_Update_Notebook(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	noteID := uuid.New()
+	notebookID := uuid.New()
+
+	tests := []struct {
+		body    string
+		set     bool
+		want    *uuid.UUID
+		service error
+		status  int
+	}{
+		{`{"title":"T","body":"B"}`, false, nil, nil, http.StatusOK},
+		{`{"title":"T","body":"B","notebook_id":null}`, true, nil, nil, http.StatusOK},
+		{`{"title":"T","body":"B","notebook_id":"` + notebookID.String() + `"}`, true, &notebookID, nil, http.StatusOK},
+		{`{"title":"T","body":"B","notebook_id":"` + notebookID.String() + `"}`, true, &notebookID, services.ErrNotebookNotFound, http.StatusNotFound},
+		{`{"title":"T","body":"B","notebook_id":"nope"}`, false, nil, nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		service := &mockNoteService{
+			update: func(ctx context.Context, userID, id uuid.UUID, params models.UpdateNoteParams) (*models.Note, error) {
+				if params.SetNotebook != tt.set {
+					t.Fatalf("%s: expected SetNotebook=%v", tt.body, tt.set)
+				}
+				if (params.NotebookID == nil) != (tt.want == nil) || (tt.want != nil && *params.NotebookID != *tt.want) {
+					t.Fatalf("%s: unexpected notebook %v", tt.body, params.NotebookID)
+				}
+				if tt.service != nil {
+					return nil, tt.service
+				}
+				return &models.Note{ID: id}, nil
+			},
+		}
+
+		h := NewNoteHandler(service)
+		req := httptest.NewRequest(http.MethodPut, "/api/notes/"+noteID.String(), strings.NewReader(tt.body))
+		req.SetPathValue("id", noteID.String())
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.Update(rr, req)
+
+		if rr.Code != tt.status {
+			t.Fatalf("%s: expected status %d, got %d", tt.body, tt.status, rr.Code)
+		}
+	}
+}
+
 func TestNoteHandler_Update_NotFound(t *testing.T) {
 	user := &models.User{ID: uuid.New()}
 	noteID := uuid.New()
@@ -126,7 +441,7 @@ func TestNoteHandler_Delete_NotFound(t *testing.T) {
 	noteID := uuid.New()
 
 	service := &mockNoteService{
-		delete: func(ctx context.Context, userID, noteID uuid.UUID) error {
+		delete: func(ctx context.Context, userID, noteID uuid.UUID, version int) error {
 			return services.ErrNoteNotFound
 		},
 	}
@@ -144,3 +459,171 @@ func TestNoteHandler_Delete_NotFound(t *testing.T) {
 		t.Fatalf("expected status 404, got %d", rr.Code)
 	}
 }
+
+func TestNoteHandler_Restore_NotInTrash(t *testing.T) {
+	noteID := uuid.New()
+	service := &mockNoteService{
+		restore: func(ctx context.Context, userID, id uuid.UUID) (*models.Note, error) {
+			return nil, services.ErrNoteNotInTrash
+		},
+	}
+
+	h := NewNoteHandler(service)
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/restore", nil)
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Restore(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", rr.Code)
+	}
+}
+
+func TestNoteHandler_EmptyTrash(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	service := &mockNoteService{
+		emptyTrash: func(ctx context.Context, userID uuid.UUID) (int64, error) {
+			if userID != user.ID {
+				t.Fatalf("expected user %s, got %s", user.ID, userID)
+			}
+			return 3, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	req := httptest.NewRequest(http.MethodDelete, "/api/notes/trash", nil)
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.EmptyTrash(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"deleted":3`) {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"", 0},
+		{"*", 0},
+		{`"7"`, 7},
+		{` "7" `, 7},
+		{`W/"7"`, -1},
+		{`"7", "8"`, -1},
+		{`"abc"`, -1},
+		{`"0"`, -1},
+		{"7", -1},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/api/notes/x", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		if got := ifMatchVersion(req); got != tt.want {
+			t.Errorf("If-Match %q: expected %d, got %d", tt.header, tt.want, got)
+		}
+	}
+}
+
+func TestNoteHandler_Get_IfNoneMatch(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	note := &models.Note{ID: uuid.New(), UserID: user.ID, Title: "Title", Body: "Body", Version: 4}
+	service := &mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+	}
+	h := NewNoteHandler(service)
+
+	for header, want := range map[string]int{
+		"":           http.StatusOK,
+		`"3"`:        http.StatusOK,
+		`"4"`:        http.StatusNotModified,
+		`W/"4"`:      http.StatusNotModified,
+		`"1", W/"4"`: http.StatusNotModified,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/notes/"+note.ID.String(), nil)
+		req.SetPathValue("id", note.ID.String())
+		if header != "" {
+			req.Header.Set("If-None-Match", header)
+		}
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.Get(rr, req)
+
+		if rr.Code != want {
+			t.Fatalf("If-None-Match %q: expected status %d, got %d", header, want, rr.Code)
+		}
+		if rr.Header().Get("ETag") != `"4"` {
+			t.Fatalf("If-None-Match %q: expected ETag \"4\", got %q", header, rr.Header().Get("ETag"))
+		}
+		if want == http.StatusNotModified && rr.Body.Len() != 0 {
+			t.Fatalf("expected empty 304 body, got %q", rr.Body.String())
+		}
+	}
+}
+
+func TestNoteHandler_Get_FormatHTML(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	note := &models.Note{ID: uuid.New(), UserID: user.ID, Title: "Title", Body: "**Bold** <script>x</script>", Version: 1}
+	h := NewNoteHandler(&mockNoteService{
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return note, nil
+		},
+	})
+
+	for format, want := range map[string]int{"html": http.StatusOK, "pdf": http.StatusBadRequest} {
+		req := httptest.NewRequest(http.MethodGet, "/api/notes/"+note.ID.String()+"?format="+format, nil)
+		req.SetPathValue("id", note.ID.String())
+		req = req.WithContext(SetUserInContext(req.Context(), user))
+		rr := httptest.NewRecorder()
+
+		h.Get(rr, req)
+
+		if rr.Code != want {
+			t.Fatalf("format %s: expected status %d, got %d", format, want, rr.Code)
+		}
+	}
+
+	if note.RenderedHTML != "<p><strong>Bold</strong> x</p>\n" {
+		t.Fatalf("unexpected rendered HTML %q", note.RenderedHTML)
+	}
+}
+
+func TestNoteHandler_Update_VersionMismatch(t *testing.T) {
+	user := &models.User{ID: uuid.New()}
+	current := &models.Note{ID: uuid.New(), UserID: user.ID, Title: "Theirs", Body: "Body", Version: 6}
+	service := &mockNoteService{
+		update: func(ctx context.Context, userID, noteID uuid.UUID, params models.UpdateNoteParams) (*models.Note, error) {
+			if params.Version != 5 {
+				t.Fatalf("expected If-Match version 5, got %d", params.Version)
+			}
+			return nil, services.ErrNoteVersionMismatch
+		},
+		get: func(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+			return current, nil
+		},
+	}
+
+	h := NewNoteHandler(service)
+	req := httptest.NewRequest(http.MethodPut, "/api/notes/"+current.ID.String(), strings.NewReader(`{"title":"Mine","body":"Body"}`))
+	req.SetPathValue("id", current.ID.String())
+	req.Header.Set("If-Match", `"5"`)
+	req = req.WithContext(SetUserInContext(req.Context(), user))
+	rr := httptest.NewRecorder()
+
+	h.Update(rr, req)
+
+	if rr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status 412, got %d", rr.Code)
+	}
+	if rr.Header().Get("ETag") != `"6"` || !strings.Contains(rr.Body.String(), `"title":"Theirs"`) {
+		t.Fatalf("expected the current note in the 412, got %s %s", rr.Header().Get("ETag"), rr.Body.String())
+	}
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"html/template"
+	"log"
 	"net"
 	"net/http"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/example/notes-template/internal/assets"
 )
@@ -212,6 +214,31 @@ func isAlphaNum(b byte) bool {
 	return (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9')
 }
 
+// SharedNotePageData fills shared_note.html, the public page behind a note
+// link.
+type SharedNotePageData struct {
+	Title         string
+	Body          template.HTML // Sanitized HTML from markdown.Render
+	UpdatedAt     time.Time
+	NeedsPassword bool // Show the password form instead of the note
+	PasswordError string
+	CSSPath       string
+}
+
+// SharedNote renders a note opened through a public link. The page is kept
+// out of search indexes, and no-referrer stops the link token leaking to
+// sites the note links to.
+func (h *PageHandler) SharedNote(w http.ResponseWriter, status int, data SharedNotePageData) {
+	data.CSSPath = h.manifest.GetCSS()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	if err := h.templates.ExecuteTemplate(w, "shared_note.html", data); err != nil {
+		log.Printf("Error rendering shared note: %v", err)
+	}
+}
+
 // NotFound renders the 404 error page.
 func (h *PageHandler) NotFound(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+const maxNextLength = 2048
+
+// safeNext validates a post-login redirect target. Same-origin relative paths
+// ("/notes?x=1") and SPA hash routes ("#app") are accepted as-is. Absolute URLs
+// are accepted only when their origin matches baseURL, and are reduced to a
+// relative path. Anything else returns "".
+func safeNext(raw, baseURL string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > maxNextLength {
+		return ""
+	}
+	if strings.ContainsRune(raw, '\\') || strings.IndexFunc(raw, unicode.IsControl) != -1 {
+		return ""
+	}
+
+	// SPA hash routes never leave the current document
+	if strings.HasPrefix(raw, "#") {
+		return raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		// Reject protocol-relative ("//evil.com") and bare relative paths
+		if !strings.HasPrefix(raw, "/") || strings.HasPrefix(raw, "//") {
+			return ""
+		}
+		return raw
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil || base.Host == "" {
+		return ""
+	}
+	if u.User != nil || !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return ""
+	}
+
+	rel := &url.URL{Path: u.Path, RawPath: u.RawPath, RawQuery: u.RawQuery, Fragment: u.Fragment}
+	next := rel.String()
+	if !strings.HasPrefix(next, "/") {
+		next = "/" + next
+	}
+	if strings.HasPrefix(next, "//") {
+		return ""
+	}
+	return next
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import "testing"
+
+func TestSafeNext(t *testing.T) {
+	const baseURL = "https://notes.example.com"
+
+	tests := []struct {
+		name     string
+		raw      string
+		expected string
+	}{
+		{name: "empty", raw: "", expected: ""},
+		{name: "hash route", raw: "#app", expected: "#app"},
+		{name: "hash route with params", raw: "#app?note=123", expected: "#app?note=123"},
+		{name: "relative path", raw: "/settings?tab=security", expected: "/settings?tab=security"},
+		{name: "relative path with fragment", raw: "/#app", expected: "/#app"},
+		{name: "protocol relative", raw: "//evil.com/path", expected: ""},
+		{name: "backslash trick", raw: "/\\evil.com", expected: ""},
+		{name: "bare relative", raw: "app", expected: ""},
+		{name: "javascript scheme", raw: "javascript:alert(1)", expected: ""},
+		{name: "control character", raw: "/app\n", expected: "/app"},
+		{name: "embedded control character", raw: "/a\tpp", expected: ""},
+		{name: "same origin absolute", raw: "https://notes.example.com/#app", expected: "/#app"},
+		{name: "same origin without path", raw: "https://notes.example.com", expected: "/"},
+		{name: "same origin different case", raw: "HTTPS://Notes.Example.com/x", expected: "/x"},
+		{name: "other origin", raw: "https://evil.com/#app", expected: ""},
+		{name: "scheme downgrade", raw: "http://notes.example.com/#app", expected: ""},
+		{name: "different port", raw: "https://notes.example.com:8443/", expected: ""},
+		{name: "userinfo", raw: "https://user@notes.example.com/", expected: ""},
+		{name: "suffix host", raw: "https://notes.example.com.evil.com/", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeNext(tt.raw, baseURL); got != tt.expected {
+				t.Errorf("safeNext(%q) = %q, want %q", tt.raw, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSafeNext_InvalidBaseURL(t *testing.T) {
+	if got := safeNext("https://notes.example.com/", "__TEMPLATE_APP_BASE_URL__"); got != "" {
+		t.Errorf("expected absolute URL to be rejected, got %q", got)
+	}
+	if got := safeNext("#app", "__TEMPLATE_APP_BASE_URL__"); got != "#app" {
+		t.Errorf("expected hash route to be accepted, got %q", got)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+type RevisionHandler struct {
+	revisionService services.RevisionServiceInterface
+}
+
+func NewRevisionHandler(revisionService services.RevisionServiceInterface) *RevisionHandler {
+	return &RevisionHandler{revisionService: revisionService}
+}
+
+// parseRevision reads a positive revision number, treating "" as def.
+func parseRevision(v string, def int) (int, bool) {
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n >= 1
+}
+
+// writeRevisionError maps revision service errors to responses.
+func writeRevisionError(w http.ResponseWriter, err error, action string) {
+	switch err {
+	case services.ErrNoteNotFound:
+		writeError(w, http.StatusNotFound, "Note not found")
+	case services.ErrRevisionNotFound:
+		writeError(w, http.StatusNotFound, "Revision not found")
+	default:
+		log.Printf("Error %s revision: %v", action, err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+	}
+}
+
+func (h *RevisionHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	revisions, err := h.revisionService.ListByNote(r.Context(), user.ID, noteID)
+	if err != nil {
+		writeRevisionError(w, err, "listing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": revisions})
+}
+
+func (h *RevisionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	rev, ok := parseRevision(r.PathValue("rev"), 0)
+	if !ok || rev == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid revision")
+		return
+	}
+
+	revision, err := h.revisionService.Get(r.Context(), user.ID, noteID, rev)
+	if err != nil {
+		writeRevisionError(w, err, "getting")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revision": revision})
+}
+
+// Diff compares revision from with revision to, or with the latest revision
+// when to is omitted.
+func (h *RevisionHandler) Diff(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	from, ok := parseRevision(r.URL.Query().Get("from"), 0)
+	if !ok || from == 0 {
+		writeError(w, http.StatusBadRequest, "from must be a revision number")
+		return
+	}
+	to, ok := parseRevision(r.URL.Query().Get("to"), 0)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "to must be a revision number")
+		return
+	}
+
+	diff, err := h.revisionService.Diff(r.Context(), user.ID, noteID, from, to)
+	if err != nil {
+		writeRevisionError(w, err, "diffing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"diff": diff})
+}
+
+func (h *RevisionHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	rev, ok := parseRevision(r.PathValue("rev"), 0)
+	if !ok || rev == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid revision")
+		return
+	}
+
+	note, err := h.revisionService.Restore(r.Context(), user.ID, noteID, rev)
+	if err != nil {
+		writeRevisionError(w, err, "restoring")
+		return
+	}
+
+	writeNote(w, http.StatusOK, note)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockRevisionService struct {
+	list    func(ctx context.Context, userID, noteID uuid.UUID) ([]*models.NoteRevision, error)
+	get     func(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.NoteRevision, error)
+	diff    func(ctx context.Context, userID, noteID uuid.UUID, from, to int) (*models.RevisionDiff, error)
+	restore func(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.Note, error)
+}
+
+func (m *mockRevisionService) ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.NoteRevision, error) {
+	return m.list(ctx, userID, noteID)
+}
+
+func (m *mockRevisionService) Get(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.NoteRevision, error) {
+	return m.get(ctx, userID, noteID, revision)
+}
+
+func (m *mockRevisionService) Diff(ctx context.Context, userID, noteID uuid.UUID, from, to int) (*models.RevisionDiff, error) {
+	return m.diff(ctx, userID, noteID, from, to)
+}
+
+func (m *mockRevisionService) Restore(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.Note, error) {
+	return m.restore(ctx, userID, noteID, revision)
+}
+
+func TestRevisionHandler_Diff_DefaultsToLatest(t *testing.T) {
+	noteID := uuid.New()
+	h := NewRevisionHandler(&mockRevisionService{
+		diff: func(ctx context.Context, userID, id uuid.UUID, from, to int) (*models.RevisionDiff, error) {
+			if id != noteID || from != 2 || to != 0 {
+				t.Fatalf("unexpected diff %s %d..%d", id, from, to)
+			}
+			return &models.RevisionDiff{From: 2, To: 4}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/"+noteID.String()+"/revisions/diff?from=2", nil)
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Diff(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+}
+
+func TestRevisionHandler_Diff_InvalidFrom(t *testing.T) {
+	h := NewRevisionHandler(&mockRevisionService{})
+
+	for _, q := range []string{"", "?from=0", "?from=abc", "?from=1&to=-1"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/notes/x/revisions/diff"+q, nil)
+		req.SetPathValue("id", uuid.New().String())
+		req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+		rr := httptest.NewRecorder()
+
+		h.Diff(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Fatalf("%q: expected status %d, got %d", q, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+func TestRevisionHandler_Restore_NotFound(t *testing.T) {
+	h := NewRevisionHandler(&mockRevisionService{
+		restore: func(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.Note, error) {
+			return nil, services.ErrRevisionNotFound
+		},
+	})
+
+	noteID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/revisions/9/restore", nil)
+	req.SetPathValue("id", noteID.String())
+	req.SetPathValue("rev", "9")
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Restore(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+type ShareHandler struct {
+	shareService services.ShareServiceInterface
+}
+
+func NewShareHandler(shareService services.ShareServiceInterface) *ShareHandler {
+	return &ShareHandler{shareService: shareService}
+}
+
+type ShareRequest struct {
+	Recipient  string `json:"recipient"`  // Email address or username
+	Permission string `json:"permission"` // viewer or editor
+}
+
+// List returns who the caller's note is shared with.
+func (h *ShareHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	shares, err := h.shareService.ListByNote(r.Context(), user.ID, noteID)
+	if err != nil {
+		if err == services.ErrNoteNotFound {
+			writeError(w, http.StatusNotFound, "Note not found")
+			return
+		}
+		log.Printf("Error listing shares: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
+
+// Create shares the caller's note with another user, or changes the
+// permission of an existing share.
+func (h *ShareHandler) Create(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+
+	var req ShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Recipient == "" || len(req.Recipient) > 255 {
+		writeError(w, http.StatusBadRequest, "Recipient must be an email address or username")
+		return
+	}
+
+	share, err := h.shareService.Share(r.Context(), user.ID, noteID, req.Recipient, req.Permission)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidSharePermission:
+			writeError(w, http.StatusBadRequest, "Permission must be viewer or editor")
+		case services.ErrShareWithSelf:
+			writeError(w, http.StatusBadRequest, "You already own this note")
+		case services.ErrNoteNotFound:
+			writeError(w, http.StatusNotFound, "Note not found")
+		case services.ErrShareRecipientNotFound:
+			writeError(w, http.StatusNotFound, "No user with that email address or username")
+		case services.ErrTooManyAttempts:
+			writeError(w, http.StatusTooManyRequests, "Too many unknown recipients, try again later")
+		default:
+			log.Printf("Error sharing note: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"share": share})
+}
+
+// Revoke removes a share. Owners can revoke anyone's access and recipients
+// can remove themselves.
+func (h *ShareHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	noteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid note id")
+		return
+	}
+	shareUserID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid user id")
+		return
+	}
+
+	if err := h.shareService.Revoke(r.Context(), user.ID, noteID, shareUserID); err != nil {
+		if err == services.ErrShareNotFound {
+			writeError(w, http.StatusNotFound, "Share not found")
+			return
+		}
+		log.Printf("Error revoking share: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Share revoked"})
+}
+
+// SharedWithMe lists notes other users shared with the caller.
+func (h *ShareHandler) SharedWithMe(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	notes, err := h.shareService.ListSharedWithUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing shared notes: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"notes": notes})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockShareService struct {
+	share      func(ctx context.Context, ownerID, noteID uuid.UUID, recipient, permission string) (*models.NoteShare, error)
+	listByNote func(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteShare, error)
+	revoke     func(ctx context.Context, userID, noteID, shareUserID uuid.UUID) error
+	sharedWith func(ctx context.Context, userID uuid.UUID) ([]*models.SharedNote, error)
+}
+
+func (m *mockShareService) Share(ctx context.Context, ownerID, noteID uuid.UUID, recipient, permission string) (*models.NoteShare, error) {
+	return m.share(ctx, ownerID, noteID, recipient, permission)
+}
+
+func (m *mockShareService) ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteShare, error) {
+	return m.listByNote(ctx, ownerID, noteID)
+}
+
+func (m *mockShareService) Revoke(ctx context.Context, userID, noteID, shareUserID uuid.UUID) error {
+	return m.revoke(ctx, userID, noteID, shareUserID)
+}
+
+func (m *mockShareService) ListSharedWithUser(ctx context.Context, userID uuid.UUID) ([]*models.SharedNote, error) {
+	return m.sharedWith(ctx, userID)
+}
+
+func TestShareHandler_Create(t *testing.T) {
+	noteID := uuid.New()
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"shared", `{"recipient":"bob","permission":"editor"}`, nil, http.StatusOK},
+		{"no recipient", `{"permission":"viewer"}`, nil, http.StatusBadRequest},
+		{"bad permission", `{"recipient":"bob","permission":"owner"}`, services.ErrInvalidSharePermission, http.StatusBadRequest},
+		{"unknown user", `{"recipient":"nobody","permission":"viewer"}`, services.ErrShareRecipientNotFound, http.StatusNotFound},
+		{"not owner", `{"recipient":"bob","permission":"viewer"}`, services.ErrNoteNotFound, http.StatusNotFound},
+		{"limited", `{"recipient":"nobody","permission":"viewer"}`, services.ErrTooManyAttempts, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		h := NewShareHandler(&mockShareService{
+			share: func(ctx context.Context, ownerID, id uuid.UUID, recipient, permission string) (*models.NoteShare, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return &models.NoteShare{NoteID: id, UserID: uuid.New(), Username: recipient, Permission: permission}, nil
+			},
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/api/notes/"+noteID.String()+"/shares", strings.NewReader(tt.body))
+		req.SetPathValue("id", noteID.String())
+		req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+		rr := httptest.NewRecorder()
+
+		h.Create(rr, req)
+
+		if rr.Code != tt.want {
+			t.Fatalf("%s: expected status %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+}
+
+func TestShareHandler_Revoke_NotFound(t *testing.T) {
+	noteID := uuid.New()
+	shareUserID := uuid.New()
+	h := NewShareHandler(&mockShareService{
+		revoke: func(ctx context.Context, userID, id, shareUser uuid.UUID) error {
+			if id != noteID || shareUser != shareUserID {
+				t.Fatalf("unexpected revoke %s %s", id, shareUser)
+			}
+			return services.ErrShareNotFound
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/notes/"+noteID.String()+"/shares/"+shareUserID.String(), nil)
+	req.SetPathValue("id", noteID.String())
+	req.SetPathValue("user_id", shareUserID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Revoke(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestNoteHandler_Update_SharedViewerForbidden(t *testing.T) {
+	noteID := uuid.New()
+	h := NewNoteHandler(&mockNoteService{
+		update: func(ctx context.Context, userID, id uuid.UUID, params models.UpdateNoteParams) (*models.Note, error) {
+			return nil, services.ErrNoteForbidden
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/notes/"+noteID.String(), strings.NewReader(`{"title":"T","body":"B"}`))
+	req.SetPathValue("id", noteID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Update(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, rr.Code)
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+type TagHandler struct {
+	tagService services.TagServiceInterface
+}
+
+func NewTagHandler(tagService services.TagServiceInterface) *TagHandler {
+	return &TagHandler{tagService: tagService}
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagRequest struct {
+	Into string `json:"into"` // ID of the tag that survives
+}
+
+// writeTagError maps tag validation errors from the services package to 400s.
+func writeTagError(w http.ResponseWriter, err error) {
+	writeError(w, http.StatusBadRequest, tagErrorMessage(err))
+}
+
+// tagErrorMessage is the client-facing message for ErrTooManyTags and
+// ErrInvalidTag.
+func tagErrorMessage(err error) string {
+	if err == services.ErrTooManyTags {
+		return fmt.Sprintf("A note can have at most %d tags", services.MaxTagsPerNote)
+	}
+	return fmt.Sprintf("Tags must be between 1 and %d characters and cannot contain commas", services.MaxTagLength)
+}
+
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	tags, err := h.tagService.ListByUser(r.Context(), user.ID)
+	if err != nil {
+		log.Printf("Error listing tags: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tags": tags})
+}
+
+func (h *TagHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	tagID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag id")
+		return
+	}
+
+	var req RenameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tag, err := h.tagService.Rename(r.Context(), user.ID, tagID, req.Name)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidTag:
+			writeTagError(w, err)
+		case services.ErrTagNotFound:
+			writeError(w, http.StatusNotFound, "Tag not found")
+		case services.ErrTagExists:
+			writeError(w, http.StatusConflict, "A tag with that name already exists; merge the tags instead")
+		default:
+			log.Printf("Error renaming tag: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tag": tag})
+}
+
+func (h *TagHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	sourceID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag id")
+		return
+	}
+
+	var req MergeTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	targetID, err := uuid.Parse(req.Into)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid target tag id")
+		return
+	}
+
+	tag, err := h.tagService.Merge(r.Context(), user.ID, sourceID, targetID)
+	if err != nil {
+		switch err {
+		case services.ErrTagSelfMerge:
+			writeError(w, http.StatusBadRequest, "Cannot merge a tag into itself")
+		case services.ErrTagNotFound:
+			writeError(w, http.StatusNotFound, "Tag not found")
+		default:
+			log.Printf("Error merging tags: %v", err)
+			writeError(w, http.StatusInternalServerError, "Internal server error")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tag": tag})
+}
+
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	tagID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid tag id")
+		return
+	}
+
+	if err := h.tagService.Delete(r.Context(), user.ID, tagID); err != nil {
+		if err == services.ErrTagNotFound {
+			writeError(w, http.StatusNotFound, "Tag not found")
+			return
+		}
+		log.Printf("Error deleting tag: %v", err)
+		writeError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Tag deleted"})
+}
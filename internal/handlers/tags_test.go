@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/services"
+)
+
+type mockTagService struct {
+	list   func(ctx context.Context, userID uuid.UUID) ([]*models.Tag, error)
+	rename func(ctx context.Context, userID, tagID uuid.UUID, name string) (*models.Tag, error)
+	merge  func(ctx context.Context, userID, sourceID, targetID uuid.UUID) (*models.Tag, error)
+	delete func(ctx context.Context, userID, tagID uuid.UUID) error
+}
+
+func (m *mockTagService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Tag, error) {
+	return m.list(ctx, userID)
+}
+
+func (m *mockTagService) Rename(ctx context.Context, userID, tagID uuid.UUID, name string) (*models.Tag, error) {
+	return m.rename(ctx, userID, tagID, name)
+}
+
+func (m *mockTagService) Merge(ctx context.Context, userID, sourceID, targetID uuid.UUID) (*models.Tag, error) {
+	return m.merge(ctx, userID, sourceID, targetID)
+}
+
+func (m *mockTagService) Delete(ctx context.Context, userID, tagID uuid.UUID) error {
+	return m.delete(ctx, userID, tagID)
+}
+
+func TestTagHandler_Rename_Conflict(t *testing.T) {
+	tagID := uuid.New()
+	h := NewTagHandler(&mockTagService{
+		rename: func(ctx context.Context, userID, id uuid.UUID, name string) (*models.Tag, error) {
+			return nil, services.ErrTagExists
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tags/"+tagID.String(), strings.NewReader(`{"name":"taken"}`))
+	req.SetPathValue("id", tagID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Rename(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestTagHandler_Merge(t *testing.T) {
+	sourceID := uuid.New()
+	targetID := uuid.New()
+	h := NewTagHandler(&mockTagService{
+		merge: func(ctx context.Context, userID, source, target uuid.UUID) (*models.Tag, error) {
+			if source != sourceID || target != targetID {
+				t.Fatalf("unexpected merge %s -> %s", source, target)
+			}
+			return &models.Tag{ID: target, Name: "kept", NoteCount: 4}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tags/"+sourceID.String()+"/merge", strings.NewReader(`{"into":"`+targetID.String()+`"}`))
+	req.SetPathValue("id", sourceID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Merge(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestTagHandler_Merge_InvalidTarget(t *testing.T) {
+	sourceID := uuid.New()
+	h := NewTagHandler(&mockTagService{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/tags/"+sourceID.String()+"/merge", strings.NewReader(`{"into":"nope"}`))
+	req.SetPathValue("id", sourceID.String())
+	req = req.WithContext(SetUserInContext(req.Context(), &models.User{ID: uuid.New()}))
+	rr := httptest.NewRecorder()
+
+	h.Merge(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"emphasis", "Some *emphasis* and ~~strike~~", "<p>Some <em>emphasis</em> and <del>strike</del></p>"},
+		{"link", "[site](https://example.com)", `<a href="https://example.com" rel="nofollow noreferrer">site</a>`},
+		{"autolink", "see https://example.com/x", `<a href="https://example.com/x" rel="nofollow noreferrer">https://example.com/x</a>`},
+		{"code block", "```go\nfmt.Println(\"<b>\")\n```", `<pre><code class="language-go">fmt.Println(&#34;&lt;b&gt;&#34;)`},
+		{"table", "| a | b |\n|:-|-:|\n| 1 | 2 |", `<th align="left">a</th>`},
+		{"task list", "- [x] done\n- [ ] todo", `<li><input checked="" disabled="" type="checkbox"> done</li>`},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.source)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Fatalf("%s: expected %q in %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRender_Sanitizes(t *testing.T) {
+	sources := []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[x](javascript:alert(1))",
+		"[x](data:text/html;base64,PHNjcmlwdD4=)",
+		"![x](https://example.com/pixel.png)",
+		"<a href=\"https://example.com\" onclick=\"alert(1)\">x</a>",
+		"<div style=\"background:url(x)\">x</div>",
+		"```\" onmouseover=\"alert(1)\nx\n```",
+		"<iframe src=\"https://example.com\"></iframe>",
+	}
+	for _, source := range sources {
+		got, err := Render(source)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", source, err)
+		}
+		for _, bad := range []string{"<script", "<img", "<iframe", "<div", "javascript:", "data:", "onerror", "onclick", "onmouseover=", "style="} {
+			if strings.Contains(got, bad) {
+				t.Fatalf("%q: rendered unsafe %q: %s", source, bad, got)
+			}
+		}
+	}
+}
@@ -1,23 +1,31 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/example/notes-template/internal/handlers"
+	"github.com/example/notes-template/internal/logging"
 	"github.com/example/notes-template/internal/services"
 )
 
 const sessionCookieName = "session_token"
 
 type AuthMiddleware struct {
-	authService *services.AuthService
-	userService *services.UserService
+	authService       *services.AuthService
+	userService       *services.UserService
+	reauthWindow      time.Duration // Maximum age of the last password or magic link confirmation
+	verificationGrace time.Duration // How long after sign-up unverified users pass RequireVerified
 }
 
-func NewAuthMiddleware(authService *services.AuthService, userService *services.UserService) *AuthMiddleware {
+func NewAuthMiddleware(authService *services.AuthService, userService *services.UserService, reauthWindow, verificationGrace time.Duration) *AuthMiddleware {
 	return &AuthMiddleware{
-		authService: authService,
-		userService: userService,
+		authService:       authService,
+		userService:       userService,
+		reauthWindow:      reauthWindow,
+		verificationGrace: verificationGrace,
 	}
 }
 
@@ -56,7 +64,70 @@ func (m *AuthMiddleware) RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RequireVerified rejects users whose email is unverified once the grace
+// period after sign-up has passed. The 403 carries an "email_unverified" code
+// and the resend endpoint so the SPA can offer to resend the verification email.
+// Must be used inside RequireAuth.
+func (m *AuthMiddleware) RequireVerified(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := handlers.GetUserFromContext(r.Context())
+		if user == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"Authentication required"}`))
+			return
+		}
+
+		graceEndsAt := user.CreatedAt.Add(m.verificationGrace)
+		if !user.EmailVerified && time.Now().After(graceEndsAt) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":       "Please verify your email address to continue",
+				"code":        "email_unverified",
+				"email":       user.Email,
+				"grace_ended": graceEndsAt,
+				"resend_path": "/api/auth/resend-verification",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // RequireSession mirrors RequireAuth for endpoints that must use a session.
 func (m *AuthMiddleware) RequireSession(next http.Handler) http.Handler {
 	return m.RequireAuth(next)
 }
+
+// RequireRecentAuth rejects requests from sessions that have not confirmed a
+// password or magic link within the reauthentication window. The 401 carries a
+// "reauth_required" code so clients can prompt via POST /api/auth/reauthenticate.
+// Must be used inside RequireAuth.
+func (m *AuthMiddleware) RequireRecentAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookieName)
+		if err != nil || cookie.Value == "" {
+			writeReauthRequired(w)
+			return
+		}
+
+		authenticatedAt, err := m.authService.SessionAuthenticatedAt(r.Context(), cookie.Value)
+		if err != nil && !errors.Is(err, services.ErrSessionNotFound) {
+			logging.Error("Failed to check session authentication time", map[string]interface{}{"error": err.Error()})
+		}
+		if err != nil || time.Since(authenticatedAt) > m.reauthWindow {
+			writeReauthRequired(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeReauthRequired(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	_, _ = w.Write([]byte(`{"error":"Recent authentication required","code":"reauth_required"}`))
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/handlers"
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestAuthMiddleware_RequireVerified(t *testing.T) {
+	m := NewAuthMiddleware(nil, nil, 10*time.Minute, 24*time.Hour)
+	handler := m.RequireVerified(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		wantStatus int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"verified", &models.User{EmailVerified: true, CreatedAt: time.Now().Add(-48 * time.Hour)}, http.StatusOK},
+		{"unverified within grace", &models.User{CreatedAt: time.Now().Add(-time.Hour)}, http.StatusOK},
+		{"unverified after grace", &models.User{CreatedAt: time.Now().Add(-48 * time.Hour)}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/notes", nil)
+			if tt.user != nil {
+				tt.user.ID = uuid.New()
+				req = req.WithContext(handlers.SetUserInContext(req.Context(), tt.user))
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusForbidden {
+				var body map[string]interface{}
+				if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+					t.Fatalf("decoding body: %v", err)
+				}
+				if body["code"] != "email_unverified" {
+					t.Errorf("expected email_unverified code, got %v", body["code"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/services"
+)
+
+const (
+	challengeHeader = "X-Challenge-Token"
+	solutionHeader  = "X-Challenge-Solution"
+)
+
+// ProofOfWork requires a solved challenge from GET /api/auth/challenge before
+// passing requests through. Used on endpoints that send email or create
+// accounts to make automated abuse expensive.
+type ProofOfWork struct {
+	challenges *services.ChallengeService
+}
+
+func NewProofOfWork(challenges *services.ChallengeService) *ProofOfWork {
+	return &ProofOfWork{challenges: challenges}
+}
+
+// Require rejects requests without a valid, unused solution with 403 and a
+// "challenge_required" code so clients can fetch a fresh challenge and retry.
+func (p *ProofOfWork) Require(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get(challengeHeader)
+		solution := r.Header.Get(solutionHeader)
+		if token == "" || solution == "" {
+			writeChallengeError(w, "Proof-of-work challenge required")
+			return
+		}
+
+		err := p.challenges.Verify(r.Context(), token, solution)
+		switch {
+		case err == nil:
+			next.ServeHTTP(w, r)
+		case errors.Is(err, services.ErrChallengeExpired):
+			writeChallengeError(w, "Challenge has expired")
+		case errors.Is(err, services.ErrChallengeUsed):
+			writeChallengeError(w, "Challenge has already been used")
+		case errors.Is(err, services.ErrChallengeInvalid), errors.Is(err, services.ErrChallengeUnsolved):
+			writeChallengeError(w, "Invalid challenge solution")
+		default:
+			logging.Error("Challenge verification failed", map[string]interface{}{"error": err.Error()})
+			writeError(w, http.StatusServiceUnavailable, "Service temporarily unavailable")
+		}
+	})
+}
+
+func writeChallengeError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message, "code": "challenge_required"})
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/notes-template/internal/services"
+)
+
+func TestProofOfWork_Require(t *testing.T) {
+	challenges := services.NewChallengeService([]byte("secret"), 4, time.Minute, nil)
+	handler := NewProofOfWork(challenges).Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	challenge, err := challenges.Issue()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var solution string
+	for i := 0; ; i++ {
+		sum := sha256.Sum256([]byte(challenge.Token + ":" + strconv.Itoa(i)))
+		if sum[0]>>4 == 0 {
+			solution = strconv.Itoa(i)
+			break
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		solution   string
+		wantStatus int
+	}{
+		{"missing headers", "", "", http.StatusForbidden},
+		{"invalid token", "bogus", solution, http.StatusForbidden},
+		{"valid solution", challenge.Token, solution, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/register", nil)
+			if tt.token != "" {
+				req.Header.Set(challengeHeader, tt.token)
+				req.Header.Set(solutionHeader, tt.solution)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusForbidden && !strings.Contains(rr.Body.String(), "challenge_required") {
+				t.Errorf("expected challenge_required code, got %s", rr.Body.String())
+			}
+		})
+	}
+}
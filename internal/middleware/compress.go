@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"compress/gzip"
-	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -11,13 +10,27 @@ import (
 // gzipResponseWriter wraps http.ResponseWriter to provide gzip compression.
 type gzipResponseWriter struct {
 	http.ResponseWriter
-	writer io.Writer
+	writer *gzip.Writer
 }
 
 func (g *gzipResponseWriter) Write(b []byte) (int, error) {
 	return g.writer.Write(b)
 }
 
+// FlushError sends what has been compressed so far, so streamed responses
+// such as server-sent events reach the client as they are written.
+func (g *gzipResponseWriter) FlushError() error {
+	if err := g.writer.Flush(); err != nil {
+		return err
+	}
+	return http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
 // Pool of gzip writers to reduce allocations.
 var gzipPool = sync.Pool{
 	New: func() interface{} {
@@ -43,6 +56,13 @@ func (c *Compress) Apply(next http.Handler) http.Handler {
 			return
 		}
 
+		// Byte ranges refer to the uncompressed body, so a gzipped partial
+		// response would not match its Content-Range
+		if r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		// Skip compression for small responses or already compressed content
 		// We'll let the response handler decide by checking content type
 		// Don't compress images, videos, etc.
@@ -5,7 +5,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestCompress_GzipWhenAccepted(t *testing.T) {
@@ -240,3 +242,57 @@ func TestCompress_VaryHeader(t *testing.T) {
 		t.Errorf("expected Vary: Accept-Encoding, got %q", got)
 	}
 }
+
+func TestCompress_SkipRangeRequests(t *testing.T) {
+	compress := NewCompress()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/1/attachments/2", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=2-4")
+
+	rr := httptest.NewRecorder()
+	compress.Apply(handler).ServeHTTP(rr, req)
+
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no Content-Encoding for a range request, got %q", got)
+	}
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "234" {
+		t.Errorf("expected 206 with %q, got %d with %q", "234", rr.Code, rr.Body.String())
+	}
+}
+
+func TestCompress_Flush(t *testing.T) {
+	compress := NewCompress()
+
+	flushed := make(chan []byte, 1)
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("data: first\n\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("unexpected flush error: %v", err)
+		}
+		flushed <- append([]byte(nil), rr.Body.Bytes()...)
+		_, _ = w.Write([]byte("data: second\n\n"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/notes/events", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	compress.Apply(handler).ServeHTTP(rr, req)
+
+	// What was flushed decompresses on its own, before the stream ends
+	gzReader, err := gzip.NewReader(strings.NewReader(string(<-flushed)))
+	if err != nil {
+		t.Fatalf("failed to create gzip reader: %v", err)
+	}
+	got, _ := io.ReadAll(gzReader)
+	if string(got) != "data: first\n\n" {
+		t.Errorf("expected the first event to be flushed, got %q", got)
+	}
+	if !rr.Flushed {
+		t.Error("expected the flush to reach the underlying writer")
+	}
+}
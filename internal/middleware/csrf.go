@@ -5,6 +5,7 @@ import (
 	"crypto/subtle"
 	"encoding/base64"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -26,7 +27,8 @@ func NewCSRFMiddleware(secure bool) *CSRFMiddleware {
 func (m *CSRFMiddleware) Protect(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Public tokenized endpoints (no session) should not require CSRF headers/cookies.
-		if r.URL.Path == "/r/unsubscribe" {
+		// Public note links only take a link password, which changes no state.
+		if r.URL.Path == "/r/unsubscribe" || strings.HasPrefix(r.URL.Path, "/s/") {
 			next.ServeHTTP(w, r)
 			return
 		}
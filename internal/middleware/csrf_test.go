@@ -131,6 +131,25 @@ func TestCSRFMiddleware_UnsubscribeBypass(t *testing.T) {
 	}
 }
 
+func TestCSRFMiddleware_NoteLinkBypass(t *testing.T) {
+	csrf := NewCSRFMiddleware(false)
+
+	handlerCalled := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerCalled = true
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/s/abc123", nil)
+	rr := httptest.NewRecorder()
+
+	csrf.Protect(handler).ServeHTTP(rr, req)
+
+	if !handlerCalled {
+		t.Error("handler should be called for a note link password without CSRF token")
+	}
+}
+
 func TestCSRFMiddleware_MismatchedTokenFails(t *testing.T) {
 	csrf := NewCSRFMiddleware(false)
 
@@ -25,6 +25,12 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// extend deadlines for long transfers.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 // RequestLogger logs HTTP requests with timing information.
 type RequestLogger struct {
 	logger *logging.Logger
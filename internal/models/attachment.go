@@ -0,0 +1,28 @@
+package models
+
+import (
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is a file attached to a note.
+type Attachment struct {
+	ID          uuid.UUID `json:"id"`
+	NoteID      uuid.UUID `json:"note_id"`
+	UserID      uuid.UUID `json:"user_id"` // Uploader, whose quota the file counts against
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"` // Sniffed from the contents, not taken from the client
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CreateAttachmentParams struct {
+	UserID      uuid.UUID
+	NoteID      uuid.UUID
+	Filename    string
+	ContentType string
+	Size        int64
+	Body        io.Reader // Must yield exactly Size bytes
+}
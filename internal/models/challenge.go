@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Challenge is a signed proof-of-work puzzle. Clients must find a solution
+// such that SHA-256(token + ":" + solution) starts with Difficulty zero bits.
+type Challenge struct {
+	Token      string    `json:"token"`
+	Difficulty int       `json:"difficulty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Invite struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"` // Nil for operator-created invites
+	UsedBy    *uuid.UUID `json:"used_by,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// MagicLinkParams describes a magic link to send.
+type MagicLinkParams struct {
+	Email      string
+	Username   string // Requested username when the link signs up a new account
+	InviteCode string // Invite code carried in the link URL for invite-only sign-up
+	Next       string // Validated post-login redirect carried in the link URL
+}
+
+// MagicLink is the verified payload of a magic link token.
+type MagicLink struct {
+	Email    string
+	Username string
+}
@@ -7,21 +7,121 @@ import (
 )
 
 type Note struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    uuid.UUID `json:"user_id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	NotebookID *uuid.UUID `json:"notebook_id"` // Nil for notes at the root
+	Title      string     `json:"title"`
+	Body       string     `json:"body"`
+	Tags       []string   `json:"tags"`
+	Pinned     bool       `json:"pinned"`   // Listed before unpinned notes
+	Archived   bool       `json:"archived"` // Left out of listings unless asked for
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Version    int        `json:"version"`              // Bumped on every change; the ETag
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set only for notes in the trash
+	// RenderedHTML is Body rendered from Markdown and sanitized. It is only
+	// filled in when a request asks for ?format=html.
+	RenderedHTML string `json:"rendered_html,omitempty"`
 }
 
 type CreateNoteParams struct {
-	UserID uuid.UUID
-	Title  string
-	Body   string
+	UserID     uuid.UUID
+	NotebookID *uuid.UUID
+	Title      string
+	Body       string
+	Tags       []string // Normalized tag names
 }
 
 type UpdateNoteParams struct {
 	Title string
 	Body  string
+	Tags  []string // Replaces the note's tags; nil leaves them unchanged
+	// SetNotebook moves the note to NotebookID (nil for the root); when false
+	// the note stays where it is.
+	SetNotebook bool
+	NotebookID  *uuid.UUID
+	// Version, when non-zero, makes the update conditional on the note still
+	// being at that version.
+	Version int
+}
+
+// NotePatch is a partial note update: nil Title, Body, Pinned and Archived
+// are left alone, and Tags, SetNotebook, NotebookID and Version work as in
+// UpdateNoteParams. Only the fields it sets are written.
+type NotePatch struct {
+	Title       *string
+	Body        *string
+	Tags        []string
+	SetNotebook bool
+	NotebookID  *uuid.UUID
+	Pinned      *bool
+	Archived    *bool
+	Version     int
+}
+
+// Operations accepted by NoteBulkOperation.Op.
+const (
+	NoteBulkDelete    = "delete"
+	NoteBulkMove      = "move"
+	NoteBulkTag       = "tag"
+	NoteBulkUntag     = "untag"
+	NoteBulkArchive   = "archive"
+	NoteBulkUnarchive = "unarchive"
+)
+
+// NoteBulkOperation applies one change to each of NoteIDs. NotebookID is
+// where a move puts the notes (nil for the root) and Tags are the normalized
+// names a tag or untag adds or removes.
+type NoteBulkOperation struct {
+	Op         string
+	NoteIDs    []uuid.UUID
+	NotebookID *uuid.UUID
+	Tags       []string
+}
+
+// NoteBulkResult is the outcome for one note of a bulk request. Err is nil
+// when the change was applied.
+type NoteBulkResult struct {
+	Op     int // Index of the operation in the request
+	NoteID uuid.UUID
+	Err    error
+}
+
+// Sort keys accepted by NoteListOptions.Sort.
+const (
+	NoteSortUpdated = "updated"
+	NoteSortCreated = "created"
+	NoteSortTitle   = "title"
+)
+
+// NoteListOptions controls which page of a user's notes ListByUser returns.
+// Range bounds are inclusive on the After side and exclusive on the Before
+// side so adjacent windows never overlap.
+type NoteListOptions struct {
+	Sort      string // One of the NoteSort constants; defaults to NoteSortUpdated
+	Ascending bool
+	Limit     int    // Clamped to the service's maximum; defaults when zero
+	Cursor    string // Opaque next_cursor from a previous page
+
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	Tag           string     // Only notes carrying this normalized tag name
+	NotebookID    *uuid.UUID // Only notes directly in this notebook
+	Unfiled       bool       // Only notes outside any notebook
+	Archived      bool       // Only archived notes; otherwise they are left out
+}
+
+type NotePage struct {
+	Notes      []*Note `json:"notes"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// NoteSearchResult is a note matched by full-text search. Snippet is
+// HTML-escaped body text with matches wrapped in <mark>.
+type NoteSearchResult struct {
+	*Note
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
 }
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Notebook struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ParentID  *uuid.UUID `json:"parent_id"` // Nil for top-level notebooks
+	Name      string     `json:"name"`
+	Position  int        `json:"position"`   // Order among siblings, starting at 0
+	NoteCount int        `json:"note_count"` // Notes directly in this notebook
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type CreateNotebookParams struct {
+	UserID   uuid.UUID
+	ParentID *uuid.UUID
+	Name     string
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of NoteEvent.
+const (
+	NoteEventCreated = "created"
+	NoteEventUpdated = "updated"
+	NoteEventDeleted = "deleted"
+)
+
+// NoteEvent reports a change to one of a user's notes. Clients fetch the note
+// for its contents.
+type NoteEvent struct {
+	// ID is the event's position in the user's event stream, "<ms>-<seq>".
+	// It is the SSE event id and is empty until the event is published.
+	ID      string    `json:"-"`
+	Type    string    `json:"type"`
+	NoteID  uuid.UUID `json:"note_id"`
+	Version int       `json:"version,omitempty"` // The note's version after the change; unset for deletes
+	At      time.Time `json:"at"`
+}
+
+// After reports whether the event comes later in its stream than the event
+// with the given id. An unparseable id sorts first.
+func (e NoteEvent) After(id string) bool {
+	ms, seq, _ := ParseNoteEventID(e.ID)
+	otherMS, otherSeq, _ := ParseNoteEventID(id)
+	return ms > otherMS || (ms == otherMS && seq > otherSeq)
+}
+
+// ParseNoteEventID splits an event id into its millisecond timestamp and
+// sequence number.
+func ParseNoteEventID(id string) (uint64, uint64, bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
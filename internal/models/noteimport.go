@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a NoteImport.
+const (
+	NoteImportRunning   = "running"
+	NoteImportCompleted = "completed"
+	NoteImportFailed    = "failed"
+)
+
+// NoteImport tracks one upload to POST /api/notes/import. Total counts every
+// note found in the upload; each ends up imported, skipped because an earlier
+// import already brought it in, or failed.
+type NoteImport struct {
+	ID         uuid.UUID         `json:"id"`
+	UserID     uuid.UUID         `json:"user_id"`
+	Status     string            `json:"status"`
+	Total      int               `json:"total"`
+	Imported   int               `json:"imported"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Errors     []NoteImportError `json:"errors"` // The first failures only
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// NoteImportError says why one note of an import failed.
+type NoteImportError struct {
+	Source string `json:"source"` // The file, and the entry or note within it
+	Error  string `json:"error"`
+}
+
+// ImportNoteParams is one validated note to import. Nil timestamps default to
+// the time of the import.
+type ImportNoteParams struct {
+	Source    string
+	Title     string
+	Body      string
+	Tags      []string // Normalized tag names
+	CreatedAt *time.Time
+	UpdatedAt *time.Time
+}
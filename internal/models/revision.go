@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NoteRevision is one saved version of a note. Body is omitted in listings.
+type NoteRevision struct {
+	ID        uuid.UUID `json:"id"`
+	NoteID    uuid.UUID `json:"note_id"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Line diff operations.
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// RevisionDiff compares two revisions of a note line by line.
+type RevisionDiff struct {
+	From      int        `json:"from"`
+	To        int        `json:"to"`
+	FromTitle string     `json:"from_title"`
+	ToTitle   string     `json:"to_title"`
+	Lines     []DiffLine `json:"lines"`
+	// Truncated is set when the bodies were too different to align, so the
+	// diff shows the changed region as a whole delete and insert.
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permissions a note share can grant.
+const (
+	SharePermissionViewer = "viewer" // Read only
+	SharePermissionEditor = "editor" // Read and edit title and body, move to trash
+)
+
+// NoteShare gives another user access to a note.
+type NoteShare struct {
+	NoteID     uuid.UUID `json:"note_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Username   string    `json:"username"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SharedNote is a note someone else shared with the caller.
+type SharedNote struct {
+	*Note
+	Permission    string `json:"permission"`
+	OwnerUsername string `json:"owner_username"`
+}
+
+// NoteLink is a public read-only link to a note. The token itself is only
+// known when the link is created, so URL is empty when links are listed.
+type NoteLink struct {
+	ID          uuid.UUID  `json:"id"`
+	NoteID      uuid.UUID  `json:"note_id"`
+	URL         string     `json:"url,omitempty"`
+	HasPassword bool       `json:"has_password"`
+	ExpiresAt   *time.Time `json:"expires_at"` // Nil for links that do not expire
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type CreateNoteLinkParams struct {
+	ExpiresAt *time.Time
+	Password  string // Empty for links without a password
+}
@@ -10,6 +10,7 @@ type User struct {
 	ID              uuid.UUID  `json:"id"`
 	Email           string     `json:"email"`
 	PasswordHash    string     `json:"-"`
+	HasPassword     bool       `json:"has_password"`
 	Username        string     `json:"username"`
 	EmailVerified   bool       `json:"email_verified"`
 	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
@@ -18,7 +19,8 @@ type User struct {
 }
 
 type CreateUserParams struct {
-	Email        string
-	PasswordHash string
-	Username     string
+	Email         string
+	PasswordHash  string // Empty for passwordless accounts
+	Username      string
+	EmailVerified bool
 }
@@ -0,0 +1,127 @@
+package noteimport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// enexTimeLayout is how Evernote writes note timestamps, always in UTC.
+const enexTimeLayout = "20060102T150405Z"
+
+type enexNote struct {
+	Title   string   `xml:"title"`
+	Content string   `xml:"content"`
+	Created string   `xml:"created"`
+	Updated string   `xml:"updated"`
+	Tags    []string `xml:"tag"`
+}
+
+// parseENEX reads the notes of an Evernote export. Note contents are ENML, an
+// XHTML dialect, and are converted to Markdown-flavoured text; attachments
+// (resource elements) are skipped.
+func parseENEX(source string, data []byte) ([]Note, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+
+	var notes []Note
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("%s: invalid ENEX: %w", source, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "note" {
+			continue
+		}
+		var n enexNote
+		if err := dec.DecodeElement(&n, &start); err != nil {
+			return nil, fmt.Errorf("%s: invalid ENEX: %w", source, err)
+		}
+		note := Note{
+			Source: fmt.Sprintf("%s (note %d)", source, len(notes)+1),
+			Title:  strings.TrimSpace(n.Title),
+			Body:   enmlToText(n.Content),
+			Tags:   n.Tags,
+		}
+		if t, err := time.Parse(enexTimeLayout, strings.TrimSpace(n.Created)); err == nil {
+			note.CreatedAt = &t
+		}
+		if t, err := time.Parse(enexTimeLayout, strings.TrimSpace(n.Updated)); err == nil {
+			note.UpdatedAt = &t
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// enmlToText keeps the text of an ENML document and the shape of its blocks:
+// headings, list items and checkboxes become their Markdown equivalents and
+// other block elements become line breaks.
+func enmlToText(enml string) string {
+	var b strings.Builder
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+
+	z := html.NewTokenizer(strings.NewReader(enml))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		switch tt {
+		case html.TextToken:
+			b.WriteString(tok.Data)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch tok.Data {
+			case "br":
+				b.WriteByte('\n')
+			case "h1", "h2", "h3", "h4", "h5", "h6":
+				newline()
+				b.WriteString(strings.Repeat("#", int(tok.Data[1]-'0')) + " ")
+			case "li":
+				newline()
+				b.WriteString("- ")
+			case "en-todo":
+				checked := "[ ] "
+				for _, attr := range tok.Attr {
+					if attr.Key == "checked" && attr.Val == "true" {
+						checked = "[x] "
+					}
+				}
+				b.WriteString(checked)
+			case "div", "p", "blockquote", "pre", "ul", "ol", "table", "tr", "hr":
+				newline()
+			}
+		case html.EndTagToken:
+			switch tok.Data {
+			case "div", "p", "blockquote", "pre", "ul", "ol", "table", "tr", "li",
+				"h1", "h2", "h3", "h4", "h5", "h6":
+				newline()
+			}
+		}
+	}
+
+	// Evernote pads with non-breaking spaces that mean nothing in Markdown
+	lines := strings.Split(strings.ReplaceAll(b.String(), "\u00a0", " "), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
@@ -0,0 +1,208 @@
+// Package noteimport reads notes out of files exported by other tools:
+// Markdown and plain text files with optional YAML front matter, zip archives
+// of them, and Evernote .enex exports. It only parses; validation against the
+// note limits is up to the caller.
+package noteimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrUnsupported  = errors.New("unsupported file type")
+	ErrTooManyNotes = errors.New("too many notes")
+	ErrTooLarge     = errors.New("import too large")
+)
+
+// Note is one note read from an import file, before validation.
+type Note struct {
+	Source    string // Where the note came from, for error messages
+	Title     string
+	Body      string
+	Tags      []string   // As written in the source; not normalized
+	CreatedAt *time.Time // Nil when the source does not say
+	UpdatedAt *time.Time
+}
+
+// Limits bound what one import may unpack, whatever the files claim about
+// themselves.
+type Limits struct {
+	MaxNotes int   // Notes across all files
+	MaxBytes int64 // Uncompressed bytes across all files
+}
+
+// Reader collects the notes of one import from any number of files.
+type Reader struct {
+	limits Limits
+	notes  []Note
+	read   int64
+}
+
+func NewReader(limits Limits) *Reader {
+	return &Reader{limits: limits}
+}
+
+// Notes returns the notes read so far, in file order.
+func (r *Reader) Notes() []Note {
+	return r.notes
+}
+
+// Add reads the notes in one uploaded file, picking the format from its
+// extension. It returns ErrUnsupported for other files and ErrTooManyNotes
+// or ErrTooLarge once the import goes over its limits.
+func (r *Reader) Add(name string, f io.ReaderAt, size int64) error {
+	switch ext := strings.ToLower(path.Ext(name)); ext {
+	case ".zip":
+		return r.addZip(name, f, size)
+	case ".md", ".markdown", ".txt", ".enex":
+		return r.addFile(name, io.NewSectionReader(f, 0, size))
+	default:
+		return ErrUnsupported
+	}
+}
+
+func (r *Reader) addZip(name string, f io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("%s: not a zip archive: %w", name, err)
+	}
+	for _, entry := range zr.File {
+		base := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(base, ".") {
+			continue
+		}
+		switch strings.ToLower(path.Ext(base)) {
+		case ".md", ".markdown", ".txt", ".enex":
+		default:
+			// Archives often carry images and other files next to the notes
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("%s/%s: %w", name, entry.Name, err)
+		}
+		err = r.addFile(name+"/"+entry.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reader) addFile(source string, f io.Reader) error {
+	data, err := r.readAll(f)
+	if err != nil {
+		if err == ErrTooLarge {
+			return err
+		}
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if strings.EqualFold(path.Ext(source), ".enex") {
+		notes, err := parseENEX(source, data)
+		if err != nil {
+			return err
+		}
+		return r.add(notes...)
+	}
+	return r.add(parseText(source, data))
+}
+
+// readAll reads f against the byte budget. The budget is enforced on what is
+// actually read, since zip headers can lie about sizes.
+func (r *Reader) readAll(f io.Reader) ([]byte, error) {
+	remaining := r.limits.MaxBytes - r.read
+	data, err := io.ReadAll(io.LimitReader(f, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > remaining {
+		return nil, ErrTooLarge
+	}
+	r.read += int64(len(data))
+	return data, nil
+}
+
+func (r *Reader) add(notes ...Note) error {
+	if len(r.notes)+len(notes) > r.limits.MaxNotes {
+		return ErrTooManyNotes
+	}
+	r.notes = append(r.notes, notes...)
+	return nil
+}
+
+// frontMatter is the YAML block that may open a Markdown or text file. Keys
+// other than these, such as the id written by exports, are ignored.
+type frontMatter struct {
+	Title   string     `yaml:"title"`
+	Tags    tagList    `yaml:"tags"`
+	Created *time.Time `yaml:"created"`
+	Updated *time.Time `yaml:"updated"`
+}
+
+// tagList accepts a YAML list of tags or a single comma-separated string.
+type tagList []string
+
+func (t *tagList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		for _, tag := range strings.Split(value.Value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				*t = append(*t, tag)
+			}
+		}
+		return nil
+	}
+	var tags []string
+	if err := value.Decode(&tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
+}
+
+// parseText reads a Markdown or plain text note. The title comes from front
+// matter, else a leading "# " heading (which is then dropped from the body),
+// else the file name.
+func parseText(source string, data []byte) Note {
+	text := string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	text = strings.ToValidUTF8(strings.ReplaceAll(text, "\r\n", "\n"), string(utf8.RuneError))
+	note := Note{Source: source}
+
+	if rest, ok := strings.CutPrefix(text, "---\n"); ok {
+		block, body, found := strings.Cut(rest, "\n---\n")
+		if !found && strings.HasSuffix(rest, "\n---") {
+			block, body, found = strings.TrimSuffix(rest, "\n---"), "", true
+		}
+		var fm frontMatter
+		if found && yaml.Unmarshal([]byte(block), &fm) == nil {
+			note.Title = strings.TrimSpace(fm.Title)
+			note.Tags = fm.Tags
+			note.CreatedAt, note.UpdatedAt = fm.Created, fm.Updated
+			text = body
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	if note.Title == "" {
+		first, rest, _ := strings.Cut(text, "\n")
+		if heading, ok := strings.CutPrefix(first, "# "); ok && strings.TrimSpace(heading) != "" {
+			note.Title = strings.TrimSpace(heading)
+			text = strings.TrimSpace(rest)
+		} else {
+			base := path.Base(source)
+			note.Title = strings.TrimSpace(strings.TrimSuffix(base, path.Ext(base)))
+		}
+	}
+	note.Body = text
+	return note
+}
@@ -0,0 +1,171 @@
+package noteimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testLimits = Limits{MaxNotes: 10, MaxBytes: 1 << 20}
+
+func add(t *testing.T, r *Reader, name string, data []byte) error {
+	t.Helper()
+	return r.Add(name, bytes.NewReader(data), int64(len(data)))
+}
+
+func TestReader_Markdown(t *testing.T) {
+	tests := []struct {
+		name, content, title, body string
+		tags                       []string
+	}{
+		{
+			"front matter",
+			"---\ntitle: Groceries\ntags: [home, \"#errands\"]\ncreated: 2024-03-01T10:00:00Z\nid: 123\n---\n- milk\n- eggs\n",
+			"Groceries", "- milk\n- eggs", []string{"home", "#errands"},
+		},
+		{
+			"comma-separated tags",
+			"---\r\ntitle: Plan\r\ntags: work, q3\r\n---\r\nShip it",
+			"Plan", "Ship it", []string{"work", "q3"},
+		},
+		{"heading", "# Trip ideas\n\nLisbon\n", "Trip ideas", "Lisbon", nil},
+		{"file name", "just text", "todo", "just text", nil},
+		{"invalid front matter kept", "---\n: [\n---\nbody", "todo", "---\n: [\n---\nbody", nil},
+	}
+	for _, tt := range tests {
+		r := NewReader(testLimits)
+		if err := add(t, r, "todo.md", []byte(tt.content)); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		notes := r.Notes()
+		if len(notes) != 1 {
+			t.Fatalf("%s: expected 1 note, got %d", tt.name, len(notes))
+		}
+		n := notes[0]
+		if n.Title != tt.title || n.Body != tt.body || strings.Join(n.Tags, ",") != strings.Join(tt.tags, ",") {
+			t.Errorf("%s: unexpected note %+v", tt.name, n)
+		}
+	}
+}
+
+func TestReader_MarkdownTimestamps(t *testing.T) {
+	r := NewReader(testLimits)
+	if err := add(t, r, "a.md", []byte("---\ntitle: A\ncreated: 2024-03-01T10:00:00Z\n---\nbody")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n := r.Notes()[0]
+	if n.CreatedAt == nil || !n.CreatedAt.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || n.UpdatedAt != nil {
+		t.Fatalf("unexpected timestamps %v %v", n.CreatedAt, n.UpdatedAt)
+	}
+}
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		_, _ = w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestReader_Zip(t *testing.T) {
+	data := zipOf(t, map[string]string{
+		"notes/a.md":          "# A\nalpha",
+		"notes/b.txt":         "beta",
+		"notes/image.png":     "\x89PNG",
+		"__MACOSX/notes/a.md": "junk",
+		"notes/.DS_Store":     "junk",
+	})
+
+	r := NewReader(testLimits)
+	if err := add(t, r, "export.zip", data); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	notes := r.Notes()
+	if len(notes) != 2 {
+		t.Fatalf("expected the two notes, got %+v", notes)
+	}
+	for _, n := range notes {
+		if !strings.HasPrefix(n.Source, "export.zip/notes/") {
+			t.Errorf("unexpected source %q", n.Source)
+		}
+	}
+}
+
+func TestReader_Limits(t *testing.T) {
+	r := NewReader(Limits{MaxNotes: 1, MaxBytes: 1 << 20})
+	if err := add(t, r, "a.md", []byte("a")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := add(t, r, "b.md", []byte("b")); !errors.Is(err, ErrTooManyNotes) {
+		t.Fatalf("expected ErrTooManyNotes, got %v", err)
+	}
+
+	// A small archive that inflates past the byte budget
+	data := zipOf(t, map[string]string{"big.txt": strings.Repeat("x", 4096)})
+	r = NewReader(Limits{MaxNotes: 10, MaxBytes: 1024})
+	if err := add(t, r, "bomb.zip", data); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+
+	if err := add(t, NewReader(testLimits), "photo.jpg", []byte("x")); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+const testENEX = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-export SYSTEM "http://xml.evernote.com/pub/evernote-export4.dtd">
+<en-export export-date="20240101T000000Z" application="Evernote">
+  <note>
+    <title>Packing list</title>
+    <created>20230512T081500Z</created>
+    <updated>20230601T120000Z</updated>
+    <tag>travel</tag>
+    <tag>lists</tag>
+    <content><![CDATA[<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE en-note SYSTEM "http://xml.evernote.com/pub/enml2.dtd">
+<en-note><h2>Clothes</h2><div><en-todo checked="true"/>Socks</div><div><en-todo/>Hat&nbsp;&amp; scarf</div><ul><li>Passport</li></ul><div><br/></div><div>Done</div></en-note>]]></content>
+    <resource><data encoding="base64">aGVsbG8=</data></resource>
+  </note>
+  <note>
+    <title>Empty</title>
+    <content><![CDATA[<en-note/>]]></content>
+  </note>
+</en-export>`
+
+func TestReader_ENEX(t *testing.T) {
+	r := NewReader(testLimits)
+	if err := add(t, r, "My Notebook.enex", []byte(testENEX)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	notes := r.Notes()
+	if len(notes) != 2 {
+		t.Fatalf("expected 2 notes, got %d", len(notes))
+	}
+
+	n := notes[0]
+	want := "## Clothes\n[x] Socks\n[ ] Hat & scarf\n- Passport\n\nDone"
+	if n.Title != "Packing list" || n.Body != want {
+		t.Fatalf("unexpected note %q:\n%s", n.Title, n.Body)
+	}
+	if strings.Join(n.Tags, ",") != "travel,lists" {
+		t.Errorf("unexpected tags %v", n.Tags)
+	}
+	if n.CreatedAt == nil || !n.CreatedAt.Equal(time.Date(2023, 5, 12, 8, 15, 0, 0, time.UTC)) || n.UpdatedAt == nil {
+		t.Errorf("unexpected timestamps %v %v", n.CreatedAt, n.UpdatedAt)
+	}
+	if n.Source != "My Notebook.enex (note 1)" || notes[1].Body != "" {
+		t.Errorf("unexpected second note or source: %q %+v", n.Source, notes[1])
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/storage"
+)
+
+var (
+	ErrAttachmentNotFound      = errors.New("attachment not found")
+	ErrAttachmentTooLarge      = errors.New("attachment too large")
+	ErrAttachmentQuotaExceeded = errors.New("attachment quota exceeded")
+)
+
+const attachmentColumns = `note_attachments.id, note_attachments.note_id, note_attachments.user_id,
+	note_attachments.filename, note_attachments.content_type, note_attachments.size, note_attachments.created_at`
+
+func scanAttachment(row Row, a *models.Attachment) error {
+	return row.Scan(&a.ID, &a.NoteID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.CreatedAt)
+}
+
+// AttachmentService stores files attached to notes. Metadata lives in
+// note_attachments and contents in a BlobStore under the attachment id.
+// Anyone who can read a note can download its attachments; anyone who can
+// edit it can add and remove them.
+type AttachmentService struct {
+	db      DB
+	store   storage.BlobStore
+	maxSize int64
+	quota   int64
+}
+
+// NewAttachmentService creates an attachment service. maxSize bounds each
+// file and quota bounds the total size of the files each user uploaded.
+func NewAttachmentService(db DB, store storage.BlobStore, maxSize, quota int64) *AttachmentService {
+	return &AttachmentService{db: db, store: store, maxSize: maxSize, quota: quota}
+}
+
+// Create checks the limits, stores the blob and then records it. The limits
+// are checked again under a lock on the uploader's row before the insert, so
+// concurrent uploads cannot overrun the quota together.
+func (s *AttachmentService) Create(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error) {
+	if params.Size > s.maxSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if err := s.checkUpload(ctx, s.db, params); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New()
+	if err := s.store.Put(ctx, id.String(), params.Body, params.Size, params.ContentType); err != nil {
+		return nil, fmt.Errorf("storing attachment: %w", err)
+	}
+
+	a := &models.Attachment{}
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, params.UserID); err != nil {
+			return fmt.Errorf("locking user: %w", err)
+		}
+		if err := s.checkUpload(ctx, tx, params); err != nil {
+			return err
+		}
+		return scanAttachment(tx.QueryRow(ctx,
+			`INSERT INTO note_attachments (id, note_id, user_id, filename, content_type, size)
+			 VALUES ($1, $2, $3, $4, $5, $6)
+			 RETURNING `+attachmentColumns,
+			id, params.NoteID, params.UserID, params.Filename, params.ContentType, params.Size,
+		), a)
+	})
+	if err != nil {
+		// No row points at the blob, so nothing else will remove it
+		if delErr := s.store.Delete(ctx, id.String()); delErr != nil {
+			logging.Error("Failed to delete unused attachment blob", map[string]interface{}{
+				"key": id.String(), "error": delErr.Error(),
+			})
+		}
+		if err == ErrNoteNotFound || err == ErrNoteForbidden || err == ErrAttachmentQuotaExceeded {
+			return nil, err
+		}
+		return nil, fmt.Errorf("creating attachment: %w", err)
+	}
+
+	return a, nil
+}
+
+// checkUpload returns ErrNoteNotFound unless the uploader can see the live
+// note, ErrNoteForbidden unless they can edit it, and
+// ErrAttachmentQuotaExceeded if the file would take them over the quota.
+func (s *AttachmentService) checkUpload(ctx context.Context, db DBConn, params models.CreateAttachmentParams) error {
+	if err := checkNoteAccess(ctx, db, params.UserID, params.NoteID, true); err != nil {
+		return err
+	}
+	var used int64
+	if err := db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(size), 0) FROM note_attachments WHERE user_id = $1`,
+		params.UserID,
+	).Scan(&used); err != nil {
+		return fmt.Errorf("summing attachments: %w", err)
+	}
+	if used+params.Size > s.quota {
+		return ErrAttachmentQuotaExceeded
+	}
+	return nil
+}
+
+// ListByNote returns the attachments of a note the user can read, oldest
+// first.
+func (s *AttachmentService) ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.Attachment, error) {
+	if err := checkNoteAccess(ctx, s.db, userID, noteID, false); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+attachmentColumns+`
+		 FROM note_attachments WHERE note_id = $1
+		 ORDER BY created_at, id`,
+		noteID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+	defer rows.Close()
+
+	attachments := []*models.Attachment{}
+	for rows.Next() {
+		a := &models.Attachment{}
+		if err := scanAttachment(rows, a); err != nil {
+			return nil, fmt.Errorf("scanning attachment: %w", err)
+		}
+		attachments = append(attachments, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating attachments: %w", err)
+	}
+
+	return attachments, nil
+}
+
+// Open returns an attachment of a note the user can read, with a reader for
+// its contents that the caller must close.
+func (s *AttachmentService) Open(ctx context.Context, userID, noteID, attachmentID uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error) {
+	a := &models.Attachment{}
+	err := scanAttachment(s.db.QueryRow(ctx,
+		`SELECT `+attachmentColumns+`
+		 FROM note_attachments JOIN notes ON notes.id = note_attachments.note_id
+		 WHERE note_attachments.id = $3 AND notes.id = $1 AND notes.deleted_at IS NULL AND `+noteReadableBy,
+		noteID, userID, attachmentID,
+	), a)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting attachment: %w", err)
+	}
+
+	blob, err := s.store.Open(ctx, a.ID.String())
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("opening attachment: %w", err)
+	}
+	return a, blob, nil
+}
+
+// Delete removes an attachment from a note the user can edit. The blob is
+// deleted straight away; if that fails the sweeper retries it.
+func (s *AttachmentService) Delete(ctx context.Context, userID, noteID, attachmentID uuid.UUID) error {
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if err := checkNoteAccess(ctx, tx, userID, noteID, true); err != nil {
+			return err
+		}
+		result, err := tx.Exec(ctx,
+			`DELETE FROM note_attachments WHERE id = $1 AND note_id = $2`,
+			attachmentID, noteID,
+		)
+		if err != nil {
+			return fmt.Errorf("deleting attachment: %w", err)
+		}
+		if result.RowsAffected() == 0 {
+			return ErrAttachmentNotFound
+		}
+		return nil
+	})
+	if err == ErrNoteNotFound || err == ErrNoteForbidden || err == ErrAttachmentNotFound {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("deleting attachment: %w", err)
+	}
+
+	if err := s.deleteBlob(ctx, attachmentID.String()); err != nil {
+		logging.Error("Failed to delete attachment blob", map[string]interface{}{
+			"key": attachmentID.String(), "error": err.Error(),
+		})
+	}
+	return nil
+}
+
+// SweepBlobs deletes up to limit blobs whose attachment rows are gone and
+// returns how many it deleted. Rows deleted by cascades, such as when the
+// janitor purges trashed notes, are queued by a trigger.
+func (s *AttachmentService) SweepBlobs(ctx context.Context, limit int) (int, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT storage_key FROM attachment_blob_deletions ORDER BY created_at LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("listing deleted blobs: %w", err)
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning deleted blob: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterating deleted blobs: %w", err)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if err := s.deleteBlob(ctx, key); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// RunBlobSweeper calls SweepBlobs every interval until ctx is canceled.
+// Replicas may sweep concurrently; deleting a blob twice is harmless.
+func (s *AttachmentService) RunBlobSweeper(ctx context.Context, interval time.Duration, batchSize int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := s.SweepBlobs(ctx, batchSize)
+			if err != nil {
+				logging.Error("Attachment blob sweep failed", map[string]interface{}{"error": err.Error()})
+			}
+			if err != nil || n < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *AttachmentService) deleteBlob(ctx context.Context, key string) error {
+	if err := s.store.Delete(ctx, key); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(ctx, `DELETE FROM attachment_blob_deletions WHERE storage_key = $1`, key); err != nil {
+		return fmt.Errorf("dequeuing deleted blob: %w", err)
+	}
+	return nil
+}
+
+// checkNoteAccess returns ErrNoteNotFound unless the user can read the live
+// note, and with write set, ErrNoteForbidden unless they can also edit it.
+func checkNoteAccess(ctx context.Context, db DBConn, userID, noteID uuid.UUID, write bool) error {
+	var writable bool
+	err := db.QueryRow(ctx,
+		`SELECT `+noteWritableBy+` FROM notes WHERE id = $1 AND deleted_at IS NULL AND `+noteReadableBy,
+		noteID, userID,
+	).Scan(&writable)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoteNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("checking note: %w", err)
+	}
+	if write && !writable {
+		return ErrNoteForbidden
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+	"github.com/example/notes-template/internal/storage"
+)
+
+// attachmentDB answers the note access check and the quota sum, and records
+// the attachment insert.
+func attachmentDB(t *testing.T, writable bool, used int64, inserted *bool) *mockDB {
+	return &mockDB{
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			return mockCommandTag{affected: 1}, nil
+		},
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "INSERT INTO note_attachments"):
+					*inserted = true
+					*dest[0].(*uuid.UUID) = args[0].(uuid.UUID)
+					*dest[3].(*string) = args[3].(string)
+					*dest[5].(*int64) = args[5].(int64)
+				case strings.Contains(sql, "SUM(size)"):
+					*dest[0].(*int64) = used
+				case strings.Contains(sql, "FROM notes"):
+					*dest[0].(*bool) = writable
+				default:
+					t.Fatalf("unexpected query %s", sql)
+				}
+				return nil
+			}}
+		},
+	}
+}
+
+func newTestBlobStore(t *testing.T) *storage.LocalStore {
+	t.Helper()
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	return store
+}
+
+func attachmentParams(body string) models.CreateAttachmentParams {
+	return models.CreateAttachmentParams{
+		UserID:      uuid.New(),
+		NoteID:      uuid.New(),
+		Filename:    "notes.txt",
+		ContentType: "text/plain; charset=utf-8",
+		Size:        int64(len(body)),
+		Body:        strings.NewReader(body),
+	}
+}
+
+func TestAttachmentService_Create(t *testing.T) {
+	var inserted bool
+	store := newTestBlobStore(t)
+	svc := NewAttachmentService(attachmentDB(t, true, 0, &inserted), store, 1<<20, 10<<20)
+
+	a, err := svc.Create(context.Background(), attachmentParams("hello"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !inserted || a.Filename != "notes.txt" || a.Size != 5 {
+		t.Fatalf("unexpected attachment %+v", a)
+	}
+
+	blob, err := store.Open(context.Background(), a.ID.String())
+	if err != nil {
+		t.Fatalf("expected the blob under the attachment id: %v", err)
+	}
+	defer blob.Close()
+	if body, _ := io.ReadAll(blob); string(body) != "hello" {
+		t.Fatalf("unexpected blob %q", body)
+	}
+}
+
+func TestAttachmentService_Create_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		writable bool
+		used     int64
+		size     int
+		want     error
+	}{
+		{"too large", true, 0, 2 << 10, ErrAttachmentTooLarge},
+		{"over quota", true, 4<<10 - 10, 100, ErrAttachmentQuotaExceeded},
+		{"viewer", false, 0, 100, ErrNoteForbidden},
+	}
+	for _, tt := range tests {
+		var inserted bool
+		svc := NewAttachmentService(attachmentDB(t, tt.writable, tt.used, &inserted), newTestBlobStore(t), 1<<10, 4<<10)
+
+		_, err := svc.Create(context.Background(), attachmentParams(strings.Repeat("x", tt.size)))
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+		if inserted {
+			t.Fatalf("%s: unexpected insert", tt.name)
+		}
+	}
+}
+
+func TestAttachmentService_Delete(t *testing.T) {
+	store := newTestBlobStore(t)
+	attachmentID := uuid.New()
+	if err := store.Put(context.Background(), attachmentID.String(), strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	var dequeued bool
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				*dest[0].(*bool) = true
+				return nil
+			}}
+		},
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if strings.Contains(sql, "attachment_blob_deletions") {
+				dequeued = args[0] == attachmentID.String()
+			}
+			return mockCommandTag{affected: 1}, nil
+		},
+	}
+
+	if err := NewAttachmentService(db, store, 1<<20, 10<<20).Delete(context.Background(), uuid.New(), uuid.New(), attachmentID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Open(context.Background(), attachmentID.String()); !errors.Is(err, storage.ErrBlobNotFound) {
+		t.Fatalf("expected the blob to be deleted, got %v", err)
+	}
+	if !dequeued {
+		t.Fatal("expected the blob to be removed from the deletion queue")
+	}
+}
+
+func TestAttachmentService_Open_MissingBlob(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if !strings.Contains(sql, "deleted_at IS NULL") {
+				t.Fatalf("expected trashed notes to be excluded, got %s", sql)
+			}
+			return mockRow{scan: func(dest ...any) error {
+				*dest[0].(*uuid.UUID) = uuid.New()
+				*dest[6].(*time.Time) = time.Now()
+				return nil
+			}}
+		},
+	}
+
+	_, _, err := NewAttachmentService(db, newTestBlobStore(t), 1<<20, 10<<20).Open(context.Background(), uuid.New(), uuid.New(), uuid.New())
+	if !errors.Is(err, ErrAttachmentNotFound) {
+		t.Fatalf("expected ErrAttachmentNotFound, got %v", err)
+	}
+}
+
+func TestAttachmentService_SweepBlobs(t *testing.T) {
+	store := newTestBlobStore(t)
+	keys := []string{uuid.NewString(), uuid.NewString()}
+	for _, key := range keys {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	var dequeued int
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			return &mockRows{rows: [][]any{{keys[0]}, {keys[1]}}}, nil
+		},
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			dequeued++
+			return mockCommandTag{affected: 1}, nil
+		},
+	}
+
+	n, err := NewAttachmentService(db, store, 1<<20, 10<<20).SweepBlobs(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 2 || dequeued != 2 {
+		t.Fatalf("expected 2 blobs swept and dequeued, got %d and %d", n, dequeued)
+	}
+	for _, key := range keys {
+		if _, err := store.Open(context.Background(), key); !errors.Is(err, storage.ErrBlobNotFound) {
+			t.Fatalf("expected blob %s to be deleted, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/example/notes-template/internal/logging"
+)
+
+// ErrTooManyAttempts means a key failed too often and is locked out until
+// its window passes.
+var ErrTooManyAttempts = errors.New("too many failed attempts")
+
+// AttemptLimiter counts failed attempts per key in redis and refuses further
+// attempts once limit of them fall within window of the first. Only failures
+// count, so legitimate use never locks a key out. Redis errors let attempts
+// through: the limiter slows guessing down, it does not guard access.
+type AttemptLimiter struct {
+	redis  RedisClient
+	prefix string
+	limit  int64
+	window time.Duration
+}
+
+func NewAttemptLimiter(redis RedisClient, prefix string, limit int64, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{redis: redis, prefix: prefix, limit: limit, window: window}
+}
+
+// Check returns ErrTooManyAttempts if key is locked out. A nil limiter
+// allows everything.
+func (l *AttemptLimiter) Check(ctx context.Context, key string) error {
+	if l == nil {
+		return nil
+	}
+	value, err := l.redis.Get(ctx, l.prefix+key)
+	if err != nil {
+		// Missing key, or redis is down
+		return nil
+	}
+	if count, err := strconv.ParseInt(value, 10, 64); err == nil && count >= l.limit {
+		return ErrTooManyAttempts
+	}
+	return nil
+}
+
+// Fail records a failed attempt for key.
+func (l *AttemptLimiter) Fail(ctx context.Context, key string) {
+	if l == nil {
+		return
+	}
+	if _, err := l.redis.Incr(ctx, l.prefix+key, l.window); err != nil {
+		logging.Error("Failed to record failed attempt", map[string]interface{}{
+			"prefix": l.prefix, "error": err.Error(),
+		})
+	}
+}
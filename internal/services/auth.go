@@ -7,6 +7,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +22,8 @@ const (
 	bcryptCost       = 12
 	sessionDuration  = 30 * 24 * time.Hour // 30 days
 	sessionKeyPrefix = "session:"
+	// Unix time the session last confirmed a password or magic link
+	sessionAuthKeyPrefix = "session_auth:"
 )
 
 var (
@@ -32,6 +36,9 @@ var (
 type AuthService struct {
 	db    DBConn
 	redis RedisClient
+
+	dummyHashOnce sync.Once
+	dummyHash     []byte
 }
 
 func NewAuthService(db DBConn, redis RedisClient) *AuthService {
@@ -57,6 +64,16 @@ func (s *AuthService) VerifyPassword(hash, password string) bool {
 	return err == nil
 }
 
+// VerifyDummyPassword runs a bcrypt comparison against a throwaway hash so
+// that logins for unknown or passwordless accounts take as long as a wrong
+// password for a real one.
+func (s *AuthService) VerifyDummyPassword(password string) {
+	s.dummyHashOnce.Do(func() {
+		s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), bcryptCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+}
+
 func (s *AuthService) GenerateSessionToken() (token string, hash string, err error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
@@ -86,7 +103,10 @@ func (s *AuthService) CreateSession(ctx context.Context, userID uuid.UUID) (toke
 	// Store in Redis for fast lookups
 	redisKey := sessionKeyPrefix + tokenHash
 	err = s.redis.Set(ctx, redisKey, userID.String(), sessionDuration)
-	if err != nil {
+	if err == nil {
+		// A new session counts as freshly authenticated
+		_ = s.redis.Set(ctx, sessionAuthKeyPrefix+tokenHash, strconv.FormatInt(time.Now().Unix(), 10), sessionDuration)
+	} else {
 		// Fall back to PostgreSQL if Redis fails
 		_, err = s.db.Exec(ctx,
 			`INSERT INTO sessions (user_id, token_hash, expires_at) VALUES ($1, $2, $3)`,
@@ -142,12 +162,70 @@ func (s *AuthService) ValidateSession(ctx context.Context, token string) (*model
 	return s.getUserByID(ctx, session.UserID)
 }
 
+// MarkSessionAuthenticated records that the session just confirmed the
+// user's password, for RequireRecentAuth.
+func (s *AuthService) MarkSessionAuthenticated(ctx context.Context, token string) error {
+	tokenHash := s.hashToken(token)
+	now := time.Now()
+
+	err := s.redis.Set(ctx, sessionAuthKeyPrefix+tokenHash, strconv.FormatInt(now.Unix(), 10), sessionDuration)
+	if err == nil {
+		return nil
+	}
+
+	// Fall back to PostgreSQL if Redis fails
+	tag, err := s.db.Exec(ctx,
+		`UPDATE sessions SET authenticated_at = $2 WHERE token_hash = $1`,
+		tokenHash, now,
+	)
+	if err != nil {
+		return fmt.Errorf("marking session authenticated: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// SessionAuthenticatedAt returns when the session last confirmed a password
+// or magic link. Sessions created before this was tracked return the zero time.
+func (s *AuthService) SessionAuthenticatedAt(ctx context.Context, token string) (time.Time, error) {
+	tokenHash := s.hashToken(token)
+
+	// Try Redis first
+	value, err := s.redis.Get(ctx, sessionAuthKeyPrefix+tokenHash)
+	if err == nil {
+		unix, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parsing authentication time: %w", err)
+		}
+		return time.Unix(unix, 0), nil
+	}
+
+	// Fall back to PostgreSQL
+	var authenticatedAt *time.Time
+	err = s.db.QueryRow(ctx,
+		`SELECT authenticated_at FROM sessions WHERE token_hash = $1`,
+		tokenHash,
+	).Scan(&authenticatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return time.Time{}, ErrSessionNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying session authentication time: %w", err)
+	}
+	if authenticatedAt == nil {
+		return time.Time{}, nil
+	}
+	return *authenticatedAt, nil
+}
+
 func (s *AuthService) DeleteSession(ctx context.Context, token string) error {
 	tokenHash := s.hashToken(token)
 
 	// Delete from Redis
 	redisKey := sessionKeyPrefix + tokenHash
-	_ = s.redis.Del(ctx, redisKey)
+	_ = s.redis.Del(ctx, redisKey, sessionAuthKeyPrefix+tokenHash)
 
 	// Delete from PostgreSQL
 	_, err := s.db.Exec(ctx, "DELETE FROM sessions WHERE token_hash = $1", tokenHash)
@@ -177,7 +255,7 @@ func (s *AuthService) DeleteAllUserSessions(ctx context.Context, userID uuid.UUI
 
 	// Delete from Redis
 	for _, hash := range tokenHashes {
-		_ = s.redis.Del(ctx, sessionKeyPrefix+hash)
+		_ = s.redis.Del(ctx, sessionKeyPrefix+hash, sessionAuthKeyPrefix+hash)
 	}
 
 	// Delete from PostgreSQL
@@ -203,6 +281,7 @@ func (s *AuthService) getUserByID(ctx context.Context, id uuid.UUID) (*models.Us
 	if err != nil {
 		return nil, fmt.Errorf("getting user: %w", err)
 	}
+	user.HasPassword = user.PasswordHash != ""
 
 	return user, nil
 }
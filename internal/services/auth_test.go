@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestAuthService_SessionAuthenticatedAt_Redis(t *testing.T) {
+	redis := &fakeRedis{values: map[string]any{}}
+	s := NewAuthService(&mockDB{}, redis)
+
+	if err := s.MarkSessionAuthenticated(context.Background(), "token"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	at, err := s.SessionAuthenticatedAt(context.Background(), "token")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if time.Since(at) > time.Minute {
+		t.Fatalf("expected recent authentication time, got %s", at)
+	}
+}
+
+func TestAuthService_SessionAuthenticatedAt_PostgresFallback(t *testing.T) {
+	redis := &fakeRedis{values: map[string]any{}, err: errors.New("redis down")}
+	authenticatedAt := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name    string
+		row     Row
+		want    time.Time
+		wantErr error
+	}{
+		{"authenticated", rowFromValues(&authenticatedAt), authenticatedAt, nil},
+		{"never authenticated", rowFromValues((*time.Time)(nil)), time.Time{}, nil},
+		{"missing session", mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}, time.Time{}, ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{
+				queryRow: func(ctx context.Context, sql string, args ...any) Row { return tt.row },
+			}
+			s := NewAuthService(db, redis)
+
+			at, err := s.SessionAuthenticatedAt(context.Background(), "token")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !at.Equal(tt.want) {
+				t.Fatalf("expected %s, got %s", tt.want, at)
+			}
+		})
+	}
+}
+
+func TestAuthService_MarkSessionAuthenticated_PostgresFallback(t *testing.T) {
+	redis := &fakeRedis{values: map[string]any{}, err: errors.New("redis down")}
+	db := &mockDB{
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			return mockCommandTag{affected: 0}, nil
+		},
+	}
+	s := NewAuthService(db, redis)
+
+	if err := s.MarkSessionAuthenticated(context.Background(), "token"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+const (
+	challengeKeyPrefix = "challenge:"
+	maxSolutionLength  = 64
+)
+
+var (
+	ErrChallengeInvalid  = errors.New("challenge is invalid")
+	ErrChallengeExpired  = errors.New("challenge has expired")
+	ErrChallengeUsed     = errors.New("challenge has already been used")
+	ErrChallengeUnsolved = errors.New("challenge solution is incorrect")
+)
+
+// ChallengeService issues and verifies stateless, HMAC-signed hash puzzles.
+// Redis is only used to reject replayed solutions; a nil client disables
+// replay protection.
+type ChallengeService struct {
+	secret     []byte
+	difficulty int
+	ttl        time.Duration
+	redis      RedisClient
+	now        func() time.Time
+}
+
+func NewChallengeService(secret []byte, difficulty int, ttl time.Duration, redis RedisClient) *ChallengeService {
+	return &ChallengeService{
+		secret:     secret,
+		difficulty: difficulty,
+		ttl:        ttl,
+		redis:      redis,
+		now:        time.Now,
+	}
+}
+
+// Issue creates a new challenge at the configured difficulty.
+// The token has the form "difficulty.expires.nonce.signature".
+func (s *ChallengeService) Issue() (*models.Challenge, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating random bytes: %w", err)
+	}
+
+	expiresAt := s.now().Add(s.ttl).Truncate(time.Second)
+	payload := fmt.Sprintf("%d.%d.%s", s.difficulty, expiresAt.Unix(), hex.EncodeToString(nonce))
+
+	return &models.Challenge{
+		Token:      payload + "." + s.sign(payload),
+		Difficulty: s.difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+// Verify checks the signature, expiry and solution of a challenge and marks it
+// as used. Challenges issued at an older, lower difficulty are still accepted
+// until they expire.
+func (s *ChallengeService) Verify(ctx context.Context, token, solution string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return ErrChallengeInvalid
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(s.sign(payload))) {
+		return ErrChallengeInvalid
+	}
+
+	difficulty, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return ErrChallengeInvalid
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return ErrChallengeInvalid
+	}
+	expiresAt := time.Unix(expires, 0)
+	if !s.now().Before(expiresAt) {
+		return ErrChallengeExpired
+	}
+
+	if solution == "" || len(solution) > maxSolutionLength {
+		return ErrChallengeUnsolved
+	}
+	sum := sha256.Sum256([]byte(token + ":" + solution))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return ErrChallengeUnsolved
+	}
+
+	if s.redis == nil {
+		return nil
+	}
+	fresh, err := s.redis.SetNX(ctx, challengeKeyPrefix+parts[2], "1", expiresAt.Sub(s.now()))
+	if err != nil {
+		return fmt.Errorf("recording challenge use: %w", err)
+	}
+	if !fresh {
+		return ErrChallengeUsed
+	}
+	return nil
+}
+
+func (s *ChallengeService) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, c := range b {
+		if c != 0 {
+			return n + bits.LeadingZeros8(c)
+		}
+		n += 8
+	}
+	return n
+}
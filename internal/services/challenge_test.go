@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func solveChallenge(token string, difficulty int) string {
+	for i := 0; ; i++ {
+		solution := strconv.Itoa(i)
+		sum := sha256.Sum256([]byte(token + ":" + solution))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return solution
+		}
+	}
+}
+
+func TestChallengeService_IssueAndVerify(t *testing.T) {
+	redis := &fakeRedis{values: map[string]any{}}
+	s := NewChallengeService([]byte("secret"), 8, time.Minute, redis)
+
+	challenge, err := s.Issue()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if challenge.Difficulty != 8 {
+		t.Fatalf("expected difficulty 8, got %d", challenge.Difficulty)
+	}
+
+	solution := solveChallenge(challenge.Token, challenge.Difficulty)
+	if err := s.Verify(context.Background(), challenge.Token, solution); err != nil {
+		t.Fatalf("expected valid solution, got %v", err)
+	}
+	if err := s.Verify(context.Background(), challenge.Token, solution); !errors.Is(err, ErrChallengeUsed) {
+		t.Fatalf("expected ErrChallengeUsed on replay, got %v", err)
+	}
+}
+
+func TestChallengeService_Rejects(t *testing.T) {
+	s := NewChallengeService([]byte("secret"), 8, time.Minute, nil)
+	challenge, err := s.Issue()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	solution := solveChallenge(challenge.Token, challenge.Difficulty)
+
+	// Lowering the difficulty in the token invalidates the signature
+	parts := strings.Split(challenge.Token, ".")
+	parts[0] = "1"
+	tampered := strings.Join(parts, ".")
+
+	other := NewChallengeService([]byte("other"), 8, time.Minute, nil)
+
+	expired := NewChallengeService([]byte("secret"), 8, time.Minute, nil)
+	expired.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+	tests := []struct {
+		name     string
+		s        *ChallengeService
+		token    string
+		solution string
+		want     error
+	}{
+		{"malformed token", s, "not-a-token", solution, ErrChallengeInvalid},
+		{"tampered difficulty", s, tampered, solveChallenge(tampered, 1), ErrChallengeInvalid},
+		{"wrong secret", other, challenge.Token, solution, ErrChallengeInvalid},
+		{"expired", expired, challenge.Token, solution, ErrChallengeExpired},
+		{"empty solution", s, challenge.Token, "", ErrChallengeUnsolved},
+		{"too long solution", s, challenge.Token, strings.Repeat("1", maxSolutionLength+1), ErrChallengeUnsolved},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.s.Verify(context.Background(), tt.token, tt.solution); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		in   []byte
+		want int
+	}{
+		{[]byte{0x80}, 0},
+		{[]byte{0x01}, 7},
+		{[]byte{0x00, 0x10}, 11},
+		{[]byte{0x00, 0x00}, 16},
+	}
+	for _, tt := range tests {
+		if got := leadingZeroBits(tt.in); got != tt.want {
+			t.Errorf("leadingZeroBits(%x) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
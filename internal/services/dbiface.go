@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -46,6 +47,45 @@ type DB interface {
 	Begin(ctx context.Context) (Tx, error)
 }
 
+// withTx runs fn in a transaction, committing when fn returns nil and rolling
+// back otherwise. fn's error is returned unwrapped so callers can match
+// sentinel errors.
+func withTx(ctx context.Context, db DB, fn func(tx Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		// The original error matters more than a failed rollback, and the
+		// connection discards the transaction either way.
+		_ = tx.Rollback(ctx)
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// withSavepoint runs fn under a savepoint in tx. When fn fails the work it
+// did is rolled back and the transaction can carry on; fn's error is
+// returned unwrapped.
+func withSavepoint(ctx context.Context, tx Tx, fn func() error) error {
+	if _, err := tx.Exec(ctx, `SAVEPOINT item`); err != nil {
+		return fmt.Errorf("creating savepoint: %w", err)
+	}
+	if err := fn(); err != nil {
+		if _, rbErr := tx.Exec(ctx, `ROLLBACK TO SAVEPOINT item`); rbErr != nil {
+			return fmt.Errorf("rolling back to savepoint: %w", rbErr)
+		}
+		return err
+	}
+	if _, err := tx.Exec(ctx, `RELEASE SAVEPOINT item`); err != nil {
+		return fmt.Errorf("releasing savepoint: %w", err)
+	}
+	return nil
+}
+
 // PoolAdapter wraps *pgxpool.Pool to satisfy DB.
 type PoolAdapter struct {
 	pool *pgxpool.Pool
@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net/smtp"
+	"net/url"
 	"strings"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 
 	"github.com/example/notes-template/internal/config"
 	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/models"
 )
 
 // Token expiration durations
@@ -152,8 +154,8 @@ func (s *EmailService) VerifyEmail(ctx context.Context, token string) error {
 	return nil
 }
 
-// SendMagicLinkEmail sends a magic link for passwordless login
-func (s *EmailService) SendMagicLinkEmail(ctx context.Context, email string) error {
+// SendMagicLinkEmail sends a magic link for passwordless login or sign-up
+func (s *EmailService) SendMagicLinkEmail(ctx context.Context, params models.MagicLinkParams) error {
 	token, tokenHash, err := GenerateToken()
 	if err != nil {
 		return err
@@ -162,46 +164,54 @@ func (s *EmailService) SendMagicLinkEmail(ctx context.Context, email string) err
 	// Store token in database
 	expiresAt := time.Now().Add(MagicLinkTokenExpiry)
 	_, err = s.db.Exec(ctx,
-		`INSERT INTO magic_link_tokens (email, token_hash, expires_at) VALUES ($1, $2, $3)`,
-		email, tokenHash, expiresAt)
+		`INSERT INTO magic_link_tokens (email, token_hash, expires_at, username) VALUES ($1, $2, $3, NULLIF($4, ''))`,
+		params.Email, tokenHash, expiresAt, params.Username)
 	if err != nil {
 		return fmt.Errorf("storing magic link token: %w", err)
 	}
 
-	loginURL := fmt.Sprintf("%s#magic-link?token=%s", s.baseURL, token)
+	loginURL := fmt.Sprintf("%s#magic-link?token=%s%s", s.baseURL, token, nextParam(params.Next))
+	if params.InviteCode != "" {
+		loginURL += "&invite=" + url.QueryEscape(params.InviteCode)
+	}
 
 	html, text := s.renderMagicLinkEmail(loginURL)
 
 	return s.provider.Send(ctx, &Email{
-		To:      email,
+		To:      params.Email,
 		Subject: fmt.Sprintf("Your %s login link", s.fromName),
 		HTML:    html,
 		Text:    text,
 	})
 }
 
-// VerifyMagicLink verifies a magic link token and returns the email
-func (s *EmailService) VerifyMagicLink(ctx context.Context, token string) (string, error) {
+// VerifyMagicLink verifies a magic link token and returns its payload
+func (s *EmailService) VerifyMagicLink(ctx context.Context, token string) (*models.MagicLink, error) {
 	tokenHash := HashToken(token)
 
 	// Find and validate token
 	var id uuid.UUID
-	var email string
+	var link models.MagicLink
+	var username *string
 	var expiresAt time.Time
 	var usedAt *time.Time
 	err := s.db.QueryRow(ctx,
-		`SELECT id, email, expires_at, used_at FROM magic_link_tokens WHERE token_hash = $1`,
-		tokenHash).Scan(&id, &email, &expiresAt, &usedAt)
+		`SELECT id, email, username, expires_at, used_at FROM magic_link_tokens WHERE token_hash = $1`,
+		tokenHash).Scan(&id, &link.Email, &username, &expiresAt, &usedAt)
 	if err != nil {
-		return "", fmt.Errorf("invalid magic link")
+		return nil, fmt.Errorf("invalid magic link")
 	}
 
 	if usedAt != nil {
-		return "", fmt.Errorf("magic link has already been used")
+		return nil, fmt.Errorf("magic link has already been used")
 	}
 
 	if time.Now().After(expiresAt) {
-		return "", fmt.Errorf("magic link has expired")
+		return nil, fmt.Errorf("magic link has expired")
+	}
+
+	if username != nil {
+		link.Username = *username
 	}
 
 	// Mark token as used
@@ -212,11 +222,12 @@ func (s *EmailService) VerifyMagicLink(ctx context.Context, token string) (strin
 		logging.Error("Failed to mark magic link as used", map[string]interface{}{"error": err.Error(), "id": id.String()})
 	}
 
-	return email, nil
+	return &link, nil
 }
 
-// SendPasswordResetEmail sends a password reset link
-func (s *EmailService) SendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email string) error {
+// SendPasswordResetEmail sends a password reset link. A non-empty next is
+// carried in the link so the SPA can redirect after the reset.
+func (s *EmailService) SendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email, next string) error {
 	token, tokenHash, err := GenerateToken()
 	if err != nil {
 		return err
@@ -231,7 +242,7 @@ func (s *EmailService) SendPasswordResetEmail(ctx context.Context, userID uuid.U
 		return fmt.Errorf("storing password reset token: %w", err)
 	}
 
-	resetURL := fmt.Sprintf("%s#reset-password?token=%s", s.baseURL, token)
+	resetURL := fmt.Sprintf("%s#reset-password?token=%s%s", s.baseURL, token, nextParam(next))
 
 	html, text := s.renderPasswordResetEmail(resetURL)
 
@@ -243,6 +254,23 @@ func (s *EmailService) SendPasswordResetEmail(ctx context.Context, userID uuid.U
 	})
 }
 
+// SendAccountExistsEmail tells the owner of an already registered address
+// that someone tried to sign up with it, instead of revealing that to the
+// requester.
+func (s *EmailService) SendAccountExistsEmail(ctx context.Context, email string) error {
+	loginURL := s.baseURL + "#login"
+	resetURL := s.baseURL + "#forgot-password"
+
+	html, text := s.renderAccountExistsEmail(loginURL, resetURL)
+
+	return s.provider.Send(ctx, &Email{
+		To:      email,
+		Subject: fmt.Sprintf("You already have a %s account", s.fromName),
+		HTML:    html,
+		Text:    text,
+	})
+}
+
 // VerifyPasswordResetToken verifies a password reset token and returns the user ID
 func (s *EmailService) VerifyPasswordResetToken(ctx context.Context, token string) (uuid.UUID, error) {
 	tokenHash := HashToken(token)
@@ -279,6 +307,15 @@ func (s *EmailService) MarkPasswordResetUsed(ctx context.Context, token string)
 	return err
 }
 
+// nextParam encodes a redirect target as an extra hash route parameter.
+// Callers validate next before it reaches the email service.
+func nextParam(next string) string {
+	if next == "" {
+		return ""
+	}
+	return "&next=" + url.QueryEscape(next)
+}
+
 // Email templates
 
 func (s *EmailService) renderVerificationEmail(verifyURL string) (html, text string) {
@@ -426,6 +463,54 @@ If you didn't request a password reset, you can safely ignore this email.
 	return html, text
 }
 
+func (s *EmailService) renderAccountExistsEmail(loginURL, resetURL string) (html, text string) {
+	html = fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif; max-width: 600px; margin: 0 auto; padding: 20px;">
+  <h1 style="color: #333; font-size: 24px;">You Already Have an Account</h1>
+
+  <p>Someone tried to create an account with this email address, but you already have one. Sign in instead:</p>
+
+  <a href="%s"
+     style="display: inline-block; background: #4F46E5; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; margin: 20px 0;">
+    Sign In
+  </a>
+
+  <p style="color: #666; font-size: 14px;">
+    Forgot your password? <a href="%s" style="color: #4F46E5;">Reset it here</a>.
+  </p>
+
+  <p style="color: #666; font-size: 14px;">
+    If this wasn't you, you can safely ignore this email. Your account has not been changed.
+  </p>
+
+  <hr style="border: none; border-top: 1px solid #eee; margin: 30px 0;">
+  <p style="color: #999; font-size: 12px;">%s</p>
+</body>
+</html>`, loginURL, resetURL, s.fromName)
+
+	text = fmt.Sprintf(`You Already Have an Account
+
+Someone tried to create an account with this email address, but you already have one.
+
+Sign in here:
+%s
+
+Forgot your password? Reset it here:
+%s
+
+If this wasn't you, you can safely ignore this email. Your account has not been changed.
+
+--
+%s`, loginURL, resetURL, s.fromName)
+
+	return html, text
+}
+
 // ResendProvider sends emails using the Resend API
 type ResendProvider struct {
 	client *resend.Client
@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/models"
+)
+
+var (
+	ErrImportNotFound   = errors.New("import not found")
+	ErrImportInProgress = errors.New("another import is running")
+)
+
+const (
+	// MaxImportNotes caps the notes in one import.
+	MaxImportNotes = 2000
+
+	// SyncImportLimit is the most notes an import saves before answering;
+	// larger imports carry on in the background.
+	SyncImportLimit = 50
+
+	// maxImportErrors caps the failures an import keeps for its status.
+	maxImportErrors = 100
+
+	// importProgressInterval is how many notes a background import saves
+	// between progress updates.
+	importProgressInterval = 25
+
+	// importStaleAfter is how long a running import blocks the next one. A
+	// graceful shutdown marks its imports failed; one still running after
+	// this most likely died with a server that crashed.
+	importStaleAfter = time.Hour
+)
+
+// ImportService saves notes read from other tools' files. Each imported note
+// carries a key derived from its title and body, so importing the same notes
+// again, such as when retrying a failed or interrupted import, skips the ones
+// already saved instead of duplicating them.
+type ImportService struct {
+	db     DB
+	events NoteEventPublisher
+
+	// Background imports run under ctx, which Stop cancels
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+}
+
+// NewImportService creates an import service. Each imported note is reported
+// to events as created; events may be nil.
+func NewImportService(db DB, events NoteEventPublisher) *ImportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ImportService{db: db, events: events, ctx: ctx, cancel: cancel}
+}
+
+// Stop interrupts background imports and blocks until each has finished its
+// current note and been marked failed, for graceful shutdown. Call it once
+// no more imports can start.
+func (s *ImportService) Stop() {
+	s.cancel()
+	s.pending.Wait()
+}
+
+// Start records an import and saves notes, which must already be validated.
+// rejected are the notes the caller found invalid; they count as failed.
+// Imports of up to SyncImportLimit notes finish before Start returns. Larger
+// ones return while still running and report progress through Get. A user
+// runs one import at a time; Start returns ErrImportInProgress otherwise.
+func (s *ImportService) Start(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error) {
+	job := &models.NoteImport{
+		UserID: userID,
+		Status: models.NoteImportRunning,
+		Total:  len(notes) + len(rejected),
+		Failed: len(rejected),
+		Errors: slices.Clone(rejected[:min(len(rejected), maxImportErrors)]),
+	}
+	if job.Errors == nil {
+		job.Errors = []models.NoteImportError{}
+	}
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err != nil {
+		return nil, fmt.Errorf("encoding import errors: %w", err)
+	}
+
+	err = withTx(ctx, s.db, func(tx Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return fmt.Errorf("locking user: %w", err)
+		}
+		var running bool
+		if err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM note_imports
+			 WHERE user_id = $1 AND status = $2 AND created_at > NOW() - $3::interval)`,
+			userID, models.NoteImportRunning, fmt.Sprintf("%d seconds", int64(importStaleAfter.Seconds())),
+		).Scan(&running); err != nil {
+			return fmt.Errorf("checking imports: %w", err)
+		}
+		if running {
+			return ErrImportInProgress
+		}
+		return tx.QueryRow(ctx,
+			`INSERT INTO note_imports (user_id, status, total, failed, errors)
+			 VALUES ($1, $2, $3, $4, $5)
+			 RETURNING id, created_at`,
+			userID, job.Status, job.Total, job.Failed, errorsJSON,
+		).Scan(&job.ID, &job.CreatedAt)
+	})
+	if err == ErrImportInProgress {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("starting import: %w", err)
+	}
+
+	if len(notes) <= SyncImportLimit {
+		s.run(ctx, job, notes)
+		return job, nil
+	}
+	// The job belongs to the goroutine from here on
+	snapshot := *job
+	snapshot.Errors = slices.Clone(job.Errors)
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		s.run(s.ctx, job, notes)
+	}()
+	return &snapshot, nil
+}
+
+// run saves the notes of a started import one at a time, so a failure partway
+// leaves only whole notes behind, and records the outcome. When ctx ends it
+// stops after the current note and marks the import failed, so a retry with
+// the same files can start at once and skip the notes already saved.
+func (s *ImportService) run(ctx context.Context, job *models.NoteImport, notes []models.ImportNoteParams) {
+	// Notes and progress are saved even as ctx ends
+	saveCtx := context.WithoutCancel(ctx)
+	for i, params := range notes {
+		if ctx.Err() != nil {
+			now := time.Now()
+			job.Status, job.FinishedAt = models.NoteImportFailed, &now
+			s.save(saveCtx, job)
+			return
+		}
+		note, err := s.importNote(saveCtx, job.UserID, params)
+		switch {
+		case err != nil:
+			logging.Error("Failed to import note", map[string]interface{}{
+				"import_id": job.ID.String(), "source": params.Source, "error": err.Error(),
+			})
+			job.Failed++
+			if len(job.Errors) < maxImportErrors {
+				job.Errors = append(job.Errors, models.NoteImportError{Source: params.Source, Error: "Could not be saved"})
+			}
+		case note != nil:
+			job.Imported++
+			if s.events != nil {
+				s.events.Publish(saveCtx, job.UserID, models.NoteEvent{Type: models.NoteEventCreated, NoteID: note.ID, Version: note.Version})
+			}
+		default:
+			job.Skipped++
+		}
+		if (i+1)%importProgressInterval == 0 && i+1 < len(notes) {
+			s.save(saveCtx, job)
+		}
+	}
+
+	now := time.Now()
+	job.Status, job.FinishedAt = models.NoteImportCompleted, &now
+	s.save(saveCtx, job)
+}
+
+// importNote saves one note with its tags and first revision. It returns a
+// nil note without saving when the user already has a note with the same
+// import key.
+func (s *ImportService) importNote(ctx context.Context, userID uuid.UUID, params models.ImportNoteParams) (*models.Note, error) {
+	note := &models.Note{}
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		err := scanNote(tx.QueryRow(ctx,
+			`INSERT INTO notes (user_id, title, body, import_key, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, COALESCE($5, NOW()), COALESCE($6, $5, NOW()))
+			 ON CONFLICT (user_id, import_key) WHERE import_key IS NOT NULL DO NOTHING
+			 RETURNING `+noteColumns,
+			userID, params.Title, params.Body, importKey(params), params.CreatedAt, params.UpdatedAt,
+		), note)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, note); err != nil {
+			return err
+		}
+		if len(params.Tags) == 0 {
+			return nil
+		}
+		return setNoteTags(ctx, tx, userID, note.ID, params.Tags)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// importKey identifies an imported note by its content.
+func importKey(params models.ImportNoteParams) string {
+	sum := sha256.Sum256([]byte(params.Title + "\x00" + params.Body))
+	return hex.EncodeToString(sum[:])
+}
+
+// save writes an import's progress. A failure is logged rather than returned:
+// the notes are saved either way and a retry would skip them.
+func (s *ImportService) save(ctx context.Context, job *models.NoteImport) {
+	errorsJSON, err := json.Marshal(job.Errors)
+	if err == nil {
+		_, err = s.db.Exec(ctx,
+			`UPDATE note_imports SET status = $2, imported = $3, skipped = $4, failed = $5, errors = $6, finished_at = $7
+			 WHERE id = $1`,
+			job.ID, job.Status, job.Imported, job.Skipped, job.Failed, errorsJSON, job.FinishedAt,
+		)
+	}
+	if err != nil {
+		logging.Error("Failed to save import progress", map[string]interface{}{
+			"import_id": job.ID.String(), "error": err.Error(),
+		})
+	}
+}
+
+// Get returns one of the user's imports. An import that has been running for
+// too long is reported as failed; importing the same files again skips the
+// notes it saved.
+func (s *ImportService) Get(ctx context.Context, userID, importID uuid.UUID) (*models.NoteImport, error) {
+	job := &models.NoteImport{}
+	var errorsJSON []byte
+	err := s.db.QueryRow(ctx,
+		`SELECT id, user_id, status, total, imported, skipped, failed, errors, created_at, finished_at
+		 FROM note_imports WHERE id = $1 AND user_id = $2`,
+		importID, userID,
+	).Scan(&job.ID, &job.UserID, &job.Status, &job.Total, &job.Imported, &job.Skipped, &job.Failed,
+		&errorsJSON, &job.CreatedAt, &job.FinishedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting import: %w", err)
+	}
+	if err := json.Unmarshal(errorsJSON, &job.Errors); err != nil {
+		return nil, fmt.Errorf("decoding import errors: %w", err)
+	}
+	if job.Status == models.NoteImportRunning && time.Since(job.CreatedAt) > importStaleAfter {
+		job.Status = models.NoteImportFailed
+	}
+	return job, nil
+}
+
+// NoteImportCleanupTask deletes import records older than retention. The
+// notes they imported stay.
+func NoteImportCleanupTask(retention time.Duration) CleanupTask {
+	return CleanupTask{
+		Name: "note_imports",
+		Query: `DELETE FROM note_imports WHERE id IN (
+			SELECT id FROM note_imports WHERE created_at < NOW() - $2::interval
+			LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+		Args: []any{fmt.Sprintf("%d seconds", int64(retention.Seconds()))},
+	}
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+// importDB answers an import's bookkeeping queries. Notes whose title is in
+// existing hit the import key conflict. The final progress update's
+// arguments are stored in saved.
+func importDB(t *testing.T, running bool, existing map[string]bool, saved *[]any) *mockDB {
+	return &mockDB{
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if strings.HasPrefix(sql, "UPDATE note_imports") {
+				*saved = args
+			}
+			return mockCommandTag{affected: 1}, nil
+		},
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				switch {
+				case strings.Contains(sql, "SELECT EXISTS"):
+					*dest[0].(*bool) = running
+				case strings.Contains(sql, "INSERT INTO note_imports"):
+					*dest[0].(*uuid.UUID) = uuid.New()
+					*dest[1].(*time.Time) = time.Now()
+				case strings.Contains(sql, "INSERT INTO notes"):
+					if key := args[3].(string); len(key) != 64 {
+						t.Fatalf("expected a SHA-256 import key, got %q", key)
+					}
+					if !strings.Contains(sql, "ON CONFLICT (user_id, import_key)") {
+						t.Fatalf("expected the insert to skip imported notes, got %s", sql)
+					}
+					if existing[args[1].(string)] {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*uuid.UUID) = uuid.New()
+					*dest[2].(*string) = args[1].(string)
+				default:
+					t.Fatalf("unexpected query %s", sql)
+				}
+				return nil
+			}}
+		},
+	}
+}
+
+func TestImportService_Start(t *testing.T) {
+	var saved []any
+	svc := NewImportService(importDB(t, false, map[string]bool{"Old": true}, &saved), nil)
+
+	notes := []models.ImportNoteParams{
+		{Source: "a.md", Title: "New", Body: "body", Tags: []string{"work"}},
+		{Source: "b.md", Title: "Old", Body: "body"},
+	}
+	rejected := []models.NoteImportError{{Source: "c.md", Error: "Body must be between 1 and 5000 characters"}}
+	job, err := svc.Start(context.Background(), uuid.New(), notes, rejected)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Status != models.NoteImportCompleted || job.FinishedAt == nil {
+		t.Fatalf("expected a small import to finish before returning, got %+v", job)
+	}
+	if job.Total != 3 || job.Imported != 1 || job.Skipped != 1 || job.Failed != 1 || len(job.Errors) != 1 {
+		t.Fatalf("unexpected counts %+v", job)
+	}
+	if len(saved) == 0 || saved[1] != models.NoteImportCompleted || saved[2] != 1 || saved[3] != 1 {
+		t.Fatalf("expected the outcome to be saved, got %v", saved)
+	}
+}
+
+func TestImportService_Start_InProgress(t *testing.T) {
+	var saved []any
+	svc := NewImportService(importDB(t, true, nil, &saved), nil)
+
+	_, err := svc.Start(context.Background(), uuid.New(), []models.ImportNoteParams{{Title: "A", Body: "B"}}, nil)
+	if !errors.Is(err, ErrImportInProgress) {
+		t.Fatalf("expected ErrImportInProgress, got %v", err)
+	}
+}
+
+func TestImportService_Stop(t *testing.T) {
+	var saved []any
+	db := importDB(t, false, nil, &saved)
+	insert := db.queryRow
+	started, release := make(chan struct{}), make(chan struct{})
+	inserted := 0
+	db.queryRow = func(ctx context.Context, sql string, args ...any) Row {
+		if strings.Contains(sql, "INSERT INTO notes") {
+			// Hold the first note until Stop has cancelled the import
+			if inserted++; inserted == 1 {
+				close(started)
+				<-release
+			}
+		}
+		return insert(ctx, sql, args...)
+	}
+	svc := NewImportService(db, nil)
+
+	notes := make([]models.ImportNoteParams, SyncImportLimit+1)
+	for i := range notes {
+		notes[i] = models.ImportNoteParams{Title: fmt.Sprintf("Note %d", i), Body: "body"}
+	}
+	job, err := svc.Start(context.Background(), uuid.New(), notes, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Status != models.NoteImportRunning {
+		t.Fatalf("expected a large import to continue in the background, got %+v", job)
+	}
+
+	<-started
+	stopped := make(chan struct{})
+	go func() {
+		svc.Stop()
+		close(stopped)
+	}()
+	<-svc.ctx.Done()
+	close(release)
+	<-stopped
+
+	// The note in progress is kept and the rest are left for a retry
+	if inserted != 1 {
+		t.Fatalf("expected the import to stop after the current note, got %d inserts", inserted)
+	}
+	if len(saved) == 0 || saved[1] != models.NoteImportFailed || saved[2] != 1 || saved[6] == (*time.Time)(nil) {
+		t.Fatalf("expected the stopped import saved as failed, got %v", saved)
+	}
+}
+
+func TestImportKey(t *testing.T) {
+	a := importKey(models.ImportNoteParams{Source: "a.md", Title: "T", Body: "B", Tags: []string{"x"}})
+	b := importKey(models.ImportNoteParams{Source: "export.zip/a.md", Title: "T", Body: "B"})
+	if a != b {
+		t.Fatal("expected the key to depend only on the title and body")
+	}
+	if a == importKey(models.ImportNoteParams{Title: "T", Body: "B2"}) {
+		t.Fatal("expected different bodies to give different keys")
+	}
+}
+
+func TestImportService_Get(t *testing.T) {
+	created := time.Now().Add(-2 * importStaleAfter)
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if args[0] == uuid.Nil {
+				return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+			}
+			return rowFromValues(args[0], args[1], models.NoteImportRunning, 3, 1, 0, 0,
+				[]byte(`[{"source":"a.md","error":"x"}]`), created, (*time.Time)(nil))
+		},
+	}
+	svc := NewImportService(db, nil)
+
+	job, err := svc.Get(context.Background(), uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if job.Status != models.NoteImportFailed || len(job.Errors) != 1 || job.Errors[0].Source != "a.md" {
+		t.Fatalf("expected a stale import reported as failed, got %+v", job)
+	}
+
+	if _, err := svc.Get(context.Background(), uuid.New(), uuid.Nil); !errors.Is(err, ErrImportNotFound) {
+		t.Fatalf("expected ErrImportNotFound, got %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -21,9 +23,12 @@ type UserServiceInterface interface {
 type AuthServiceInterface interface {
 	HashPassword(password string) (string, error)
 	VerifyPassword(hash, password string) bool
+	VerifyDummyPassword(password string)
 	GenerateSessionToken() (token string, hash string, err error)
 	CreateSession(ctx context.Context, userID uuid.UUID) (token string, err error)
 	ValidateSession(ctx context.Context, token string) (*models.User, error)
+	MarkSessionAuthenticated(ctx context.Context, token string) error
+	SessionAuthenticatedAt(ctx context.Context, token string) (time.Time, error)
 	DeleteSession(ctx context.Context, token string) error
 	DeleteAllUserSessions(ctx context.Context, userID uuid.UUID) error
 }
@@ -32,18 +37,105 @@ type AuthServiceInterface interface {
 type EmailServiceInterface interface {
 	SendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error
 	VerifyEmail(ctx context.Context, token string) error
-	SendMagicLinkEmail(ctx context.Context, email string) error
-	VerifyMagicLink(ctx context.Context, token string) (string, error)
-	SendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email string) error
+	SendMagicLinkEmail(ctx context.Context, params models.MagicLinkParams) error
+	VerifyMagicLink(ctx context.Context, token string) (*models.MagicLink, error)
+	SendPasswordResetEmail(ctx context.Context, userID uuid.UUID, email, next string) error
+	SendAccountExistsEmail(ctx context.Context, email string) error
 	VerifyPasswordResetToken(ctx context.Context, token string) (uuid.UUID, error)
 	MarkPasswordResetUsed(ctx context.Context, token string) error
 }
 
+// InviteServiceInterface defines the contract for invite code operations.
+type InviteServiceInterface interface {
+	Create(ctx context.Context, createdBy *uuid.UUID, ttl time.Duration) (string, *models.Invite, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Invite, error)
+	Validate(ctx context.Context, code string) error
+	Claim(ctx context.Context, code string) (uuid.UUID, error)
+	Release(ctx context.Context, inviteID uuid.UUID) error
+	MarkUsedBy(ctx context.Context, inviteID, userID uuid.UUID) error
+}
+
+// ChallengeServiceInterface defines the contract for proof-of-work challenges.
+type ChallengeServiceInterface interface {
+	Issue() (*models.Challenge, error)
+	Verify(ctx context.Context, token, solution string) error
+}
+
 // NoteServiceInterface defines the contract for notes operations.
 type NoteServiceInterface interface {
 	Create(ctx context.Context, params models.CreateNoteParams) (*models.Note, error)
-	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Note, error)
+	ListByUser(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error)
+	Search(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error)
 	GetByID(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error)
 	Update(ctx context.Context, userID, noteID uuid.UUID, params models.UpdateNoteParams) (*models.Note, error)
-	Delete(ctx context.Context, userID, noteID uuid.UUID) error
+	Patch(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error)
+	Delete(ctx context.Context, userID, noteID uuid.UUID, version int) error
+	ListTrash(ctx context.Context, userID uuid.UUID) ([]*models.Note, error)
+	Restore(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error)
+	EmptyTrash(ctx context.Context, userID uuid.UUID) (int64, error)
+	Bulk(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error)
+	Export(ctx context.Context, userID uuid.UUID, fn func(*models.Note) error) error
+}
+
+// NoteEventsInterface defines the contract for following note changes.
+type NoteEventsInterface interface {
+	Subscribe(ctx context.Context, userID uuid.UUID, lastEventID string) (*NoteEventSubscription, error)
+}
+
+// ImportServiceInterface defines the contract for importing notes.
+type ImportServiceInterface interface {
+	Start(ctx context.Context, userID uuid.UUID, notes []models.ImportNoteParams, rejected []models.NoteImportError) (*models.NoteImport, error)
+	Get(ctx context.Context, userID, importID uuid.UUID) (*models.NoteImport, error)
+}
+
+// ShareServiceInterface defines the contract for sharing notes with other
+// users.
+type ShareServiceInterface interface {
+	Share(ctx context.Context, ownerID, noteID uuid.UUID, recipient, permission string) (*models.NoteShare, error)
+	ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteShare, error)
+	Revoke(ctx context.Context, userID, noteID, shareUserID uuid.UUID) error
+	ListSharedWithUser(ctx context.Context, userID uuid.UUID) ([]*models.SharedNote, error)
+}
+
+// NoteLinkServiceInterface defines the contract for public note links.
+type NoteLinkServiceInterface interface {
+	Create(ctx context.Context, ownerID, noteID uuid.UUID, params models.CreateNoteLinkParams) (*models.NoteLink, string, error)
+	ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteLink, error)
+	Revoke(ctx context.Context, ownerID, noteID, linkID uuid.UUID) error
+	Resolve(ctx context.Context, token, password string) (*models.Note, error)
+}
+
+// AttachmentServiceInterface defines the contract for files attached to
+// notes.
+type AttachmentServiceInterface interface {
+	Create(ctx context.Context, params models.CreateAttachmentParams) (*models.Attachment, error)
+	ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.Attachment, error)
+	Open(ctx context.Context, userID, noteID, attachmentID uuid.UUID) (*models.Attachment, io.ReadSeekCloser, error)
+	Delete(ctx context.Context, userID, noteID, attachmentID uuid.UUID) error
+}
+
+// TagServiceInterface defines the contract for tag management.
+type TagServiceInterface interface {
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Tag, error)
+	Rename(ctx context.Context, userID, tagID uuid.UUID, name string) (*models.Tag, error)
+	Merge(ctx context.Context, userID, sourceID, targetID uuid.UUID) (*models.Tag, error)
+	Delete(ctx context.Context, userID, tagID uuid.UUID) error
+}
+
+// NotebookServiceInterface defines the contract for notebook operations.
+type NotebookServiceInterface interface {
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Notebook, error)
+	Create(ctx context.Context, params models.CreateNotebookParams) (*models.Notebook, error)
+	GetByID(ctx context.Context, userID, notebookID uuid.UUID) (*models.Notebook, error)
+	Rename(ctx context.Context, userID, notebookID uuid.UUID, name string) (*models.Notebook, error)
+	Move(ctx context.Context, userID, notebookID uuid.UUID, parentID *uuid.UUID, position int) (*models.Notebook, error)
+	Delete(ctx context.Context, userID, notebookID uuid.UUID, deleteNotes bool) error
+}
+
+// RevisionServiceInterface defines the contract for note history.
+type RevisionServiceInterface interface {
+	ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.NoteRevision, error)
+	Get(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.NoteRevision, error)
+	Diff(ctx context.Context, userID, noteID uuid.UUID, from, to int) (*models.RevisionDiff, error)
+	Restore(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.Note, error)
 }
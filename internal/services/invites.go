@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var ErrInviteInvalid = errors.New("invite code is invalid or has already been used")
+
+type InviteService struct {
+	db DBConn
+}
+
+func NewInviteService(db DBConn) *InviteService {
+	return &InviteService{db: db}
+}
+
+// Create issues a new single-use invite code. createdBy is nil for invites
+// created by an operator, and a zero ttl means the invite never expires.
+func (s *InviteService) Create(ctx context.Context, createdBy *uuid.UUID, ttl time.Duration) (string, *models.Invite, error) {
+	code, codeHash, err := GenerateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	var expiresAt *time.Time
+	if ttl > 0 {
+		t := time.Now().Add(ttl)
+		expiresAt = &t
+	}
+
+	invite := &models.Invite{}
+	err = s.db.QueryRow(ctx,
+		`INSERT INTO invite_codes (code_hash, created_by, expires_at)
+		 VALUES ($1, $2, $3)
+		 RETURNING id, created_by, used_by, expires_at, used_at, created_at`,
+		codeHash, createdBy, expiresAt,
+	).Scan(&invite.ID, &invite.CreatedBy, &invite.UsedBy, &invite.ExpiresAt, &invite.UsedAt, &invite.CreatedAt)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating invite: %w", err)
+	}
+
+	return code, invite, nil
+}
+
+func (s *InviteService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Invite, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT id, created_by, used_by, expires_at, used_at, created_at
+		 FROM invite_codes WHERE created_by = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []*models.Invite
+	for rows.Next() {
+		invite := &models.Invite{}
+		if err := rows.Scan(&invite.ID, &invite.CreatedBy, &invite.UsedBy, &invite.ExpiresAt, &invite.UsedAt, &invite.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning invite: %w", err)
+		}
+		invites = append(invites, invite)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// Validate reports whether code is an unused, unexpired invite without
+// consuming it.
+func (s *InviteService) Validate(ctx context.Context, code string) error {
+	var exists bool
+	err := s.db.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM invite_codes
+		 WHERE code_hash = $1 AND used_at IS NULL AND (expires_at IS NULL OR expires_at > NOW()))`,
+		HashToken(code),
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("checking invite: %w", err)
+	}
+	if !exists {
+		return ErrInviteInvalid
+	}
+	return nil
+}
+
+// Claim atomically marks an invite as used and returns its ID. Callers must
+// Release the invite if the account it was claimed for is not created.
+func (s *InviteService) Claim(ctx context.Context, code string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := s.db.QueryRow(ctx,
+		`UPDATE invite_codes SET used_at = NOW()
+		 WHERE code_hash = $1 AND used_at IS NULL AND (expires_at IS NULL OR expires_at > NOW())
+		 RETURNING id`,
+		HashToken(code),
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("claiming invite: %w", err)
+	}
+	return id, nil
+}
+
+// Release makes a claimed invite usable again.
+func (s *InviteService) Release(ctx context.Context, inviteID uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE invite_codes SET used_at = NULL WHERE id = $1 AND used_by IS NULL`,
+		inviteID,
+	)
+	if err != nil {
+		return fmt.Errorf("releasing invite: %w", err)
+	}
+	return nil
+}
+
+// MarkUsedBy records the account created with a claimed invite.
+func (s *InviteService) MarkUsedBy(ctx context.Context, inviteID, userID uuid.UUID) error {
+	_, err := s.db.Exec(ctx,
+		`UPDATE invite_codes SET used_by = $2 WHERE id = $1`,
+		inviteID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("marking invite used: %w", err)
+	}
+	return nil
+}
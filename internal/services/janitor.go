@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/example/notes-template/internal/logging"
+)
+
+const janitorLockKey = "janitor:lock"
+
+// CleanupTask deletes stale rows from one table in batches.
+type CleanupTask struct {
+	Name string
+	// Query is a DELETE statement that removes at most $1 rows per call.
+	// Args are appended after the batch size as $2, $3, ...
+	Query string
+	Args  []any
+}
+
+// DefaultCleanupTasks removes expired sessions and expired or used tokens.
+// Rows are kept for the retention period after they stop being valid so
+// verification endpoints can still report "expired" rather than "invalid".
+func DefaultCleanupTasks(retention time.Duration) []CleanupTask {
+	cutoff := fmt.Sprintf("%d seconds", int64(retention.Seconds()))
+	return []CleanupTask{
+		{
+			Name: "sessions",
+			Query: `DELETE FROM sessions WHERE id IN (
+				SELECT id FROM sessions WHERE expires_at < NOW() - $2::interval
+				LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+			Args: []any{cutoff},
+		},
+		{
+			Name: "email_verification_tokens",
+			Query: `DELETE FROM email_verification_tokens WHERE id IN (
+				SELECT id FROM email_verification_tokens WHERE expires_at < NOW() - $2::interval
+				LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+			Args: []any{cutoff},
+		},
+		{
+			Name: "magic_link_tokens",
+			Query: `DELETE FROM magic_link_tokens WHERE id IN (
+				SELECT id FROM magic_link_tokens
+				WHERE expires_at < NOW() - $2::interval OR used_at < NOW() - $2::interval
+				LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+			Args: []any{cutoff},
+		},
+		{
+			Name: "password_reset_tokens",
+			Query: `DELETE FROM password_reset_tokens WHERE id IN (
+				SELECT id FROM password_reset_tokens
+				WHERE expires_at < NOW() - $2::interval OR used_at < NOW() - $2::interval
+				LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+			Args: []any{cutoff},
+		},
+	}
+}
+
+// Janitor periodically runs cleanup tasks. A Redis lock held for one interval
+// ensures that only one replica runs each cycle.
+type Janitor struct {
+	db         DBConn
+	redis      RedisClient
+	tasks      []CleanupTask
+	interval   time.Duration
+	batchSize  int
+	maxBatches int
+	owner      string
+
+	mu          sync.Mutex
+	deleted     map[string]int64
+	runs        int64
+	skipped     int64
+	errors      int64
+	lastRun     time.Time
+	lastRunTime time.Duration
+}
+
+// NewJanitor creates a janitor. Each batch deletes at most batchSize rows in
+// its own statement, so no transaction holds locks on more than one batch.
+func NewJanitor(db DBConn, redis RedisClient, tasks []CleanupTask, interval time.Duration, batchSize int) *Janitor {
+	host, _ := os.Hostname()
+	return &Janitor{
+		db:         db,
+		redis:      redis,
+		tasks:      tasks,
+		interval:   interval,
+		batchSize:  batchSize,
+		maxBatches: 1000,
+		owner:      fmt.Sprintf("%s:%d", host, os.Getpid()),
+		deleted:    make(map[string]int64),
+	}
+}
+
+// Run executes cleanup cycles until ctx is canceled.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.runCycle(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Janitor) runCycle(ctx context.Context) {
+	acquired, err := j.redis.SetNX(ctx, janitorLockKey, j.owner, j.interval)
+	if err != nil {
+		logging.Error("Janitor lock unavailable, skipping cycle", map[string]interface{}{"error": err.Error()})
+		j.recordSkip()
+		return
+	}
+	if !acquired {
+		j.recordSkip()
+		return
+	}
+
+	if _, err := j.RunOnce(ctx); err != nil {
+		logging.Error("Janitor cycle failed", map[string]interface{}{"error": err.Error()})
+	}
+}
+
+// RunOnce runs every task to completion and returns the rows deleted per task.
+// It does not take the replica lock.
+func (j *Janitor) RunOnce(ctx context.Context) (map[string]int64, error) {
+	start := time.Now()
+	deleted := make(map[string]int64, len(j.tasks))
+	var firstErr error
+
+	for _, task := range j.tasks {
+		n, err := j.runTask(ctx, task)
+		deleted[task.Name] = n
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("cleaning %s: %w", task.Name, err)
+		}
+	}
+
+	j.recordRun(deleted, firstErr, start)
+
+	if total := sumDeleted(deleted); total > 0 {
+		fields := map[string]interface{}{"duration_ms": time.Since(start).Milliseconds()}
+		for name, n := range deleted {
+			fields[name] = n
+		}
+		logging.Info("Janitor removed stale rows", fields)
+	}
+
+	return deleted, firstErr
+}
+
+func (j *Janitor) runTask(ctx context.Context, task CleanupTask) (int64, error) {
+	args := append([]any{j.batchSize}, task.Args...)
+
+	var total int64
+	for batch := 0; batch < j.maxBatches; batch++ {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		result, err := j.db.Exec(ctx, task.Query, args...)
+		if err != nil {
+			return total, err
+		}
+
+		n := result.RowsAffected()
+		total += n
+		if n < int64(j.batchSize) {
+			break
+		}
+	}
+	return total, nil
+}
+
+func (j *Janitor) recordRun(deleted map[string]int64, err error, start time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.runs++
+	if err != nil {
+		j.errors++
+	}
+	for name, n := range deleted {
+		j.deleted[name] += n
+	}
+	j.lastRun = start
+	j.lastRunTime = time.Since(start)
+}
+
+func (j *Janitor) recordSkip() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.skipped++
+}
+
+// WriteMetrics writes janitor counters in the Prometheus text format.
+func (j *Janitor) WriteMetrics(w io.Writer) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var b strings.Builder
+	names := make([]string, 0, len(j.tasks))
+	for _, task := range j.tasks {
+		names = append(names, task.Name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(&b, "# HELP janitor_rows_deleted_total Stale rows deleted by the janitor.")
+	fmt.Fprintln(&b, "# TYPE janitor_rows_deleted_total counter")
+	for _, name := range names {
+		fmt.Fprintf(&b, "janitor_rows_deleted_total{table=%q} %d\n", name, j.deleted[name])
+	}
+
+	fmt.Fprintln(&b, "# HELP janitor_runs_total Cleanup cycles run on this replica.")
+	fmt.Fprintln(&b, "# TYPE janitor_runs_total counter")
+	fmt.Fprintf(&b, "janitor_runs_total %d\n", j.runs)
+
+	fmt.Fprintln(&b, "# HELP janitor_skipped_total Cleanup cycles skipped because another replica held the lock.")
+	fmt.Fprintln(&b, "# TYPE janitor_skipped_total counter")
+	fmt.Fprintf(&b, "janitor_skipped_total %d\n", j.skipped)
+
+	fmt.Fprintln(&b, "# HELP janitor_errors_total Cleanup cycles that returned an error.")
+	fmt.Fprintln(&b, "# TYPE janitor_errors_total counter")
+	fmt.Fprintf(&b, "janitor_errors_total %d\n", j.errors)
+
+	if !j.lastRun.IsZero() {
+		fmt.Fprintln(&b, "# HELP janitor_last_run_timestamp_seconds Start time of the last cleanup cycle.")
+		fmt.Fprintln(&b, "# TYPE janitor_last_run_timestamp_seconds gauge")
+		fmt.Fprintf(&b, "janitor_last_run_timestamp_seconds %d\n", j.lastRun.Unix())
+
+		fmt.Fprintln(&b, "# HELP janitor_last_run_duration_seconds Duration of the last cleanup cycle.")
+		fmt.Fprintln(&b, "# TYPE janitor_last_run_duration_seconds gauge")
+		fmt.Fprintf(&b, "janitor_last_run_duration_seconds %g\n", j.lastRunTime.Seconds())
+	}
+
+	_, _ = io.WriteString(w, b.String())
+}
+
+func sumDeleted(deleted map[string]int64) int64 {
+	var total int64
+	for _, n := range deleted {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRedis struct {
+	values map[string]any
+	err    error
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeRedis) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	if f.err != nil {
+		return false, f.err
+	}
+	if _, ok := f.values[key]; ok {
+		return false, nil
+	}
+	f.values[key] = value
+	return true, nil
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	v, ok := f.values[key]
+	if !ok {
+		return "", errors.New("redis: nil")
+	}
+	s, _ := v.(string)
+	return s, nil
+}
+
+func (f *fakeRedis) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return f.err
+}
+
+func (f *fakeRedis) Del(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		delete(f.values, key)
+	}
+	return f.err
+}
+
+func (f *fakeRedis) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	count, _ := strconv.ParseInt(fmt.Sprint(f.values[key]), 10, 64)
+	count++
+	f.values[key] = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: make(map[string]any)}
+}
+
+func TestJanitor_RunOnce_DeletesInBatches(t *testing.T) {
+	remaining := map[string]int64{"sessions": 25, "magic_link_tokens": 3}
+	var calls int
+	db := &fakeDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			calls++
+			batch := int64(args[0].(int))
+			for name := range remaining {
+				if strings.Contains(sql, "FROM "+name+" ") {
+					n := min(batch, remaining[name])
+					remaining[name] -= n
+					return fakeCommandTag{rowsAffected: n}, nil
+				}
+			}
+			return fakeCommandTag{}, nil
+		},
+	}
+
+	janitor := NewJanitor(db, newFakeRedis(), DefaultCleanupTasks(time.Hour), time.Hour, 10)
+	deleted, err := janitor.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if deleted["sessions"] != 25 {
+		t.Errorf("expected 25 sessions deleted, got %d", deleted["sessions"])
+	}
+	if deleted["magic_link_tokens"] != 3 {
+		t.Errorf("expected 3 magic link tokens deleted, got %d", deleted["magic_link_tokens"])
+	}
+	// sessions: 10, 10, 5; magic links: 3; two empty tables: 1 each
+	if calls != 6 {
+		t.Errorf("expected 6 delete statements, got %d", calls)
+	}
+}
+
+func TestTrashPurgeTask(t *testing.T) {
+	var gotSQL string
+	var gotArgs []any
+	db := &fakeDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			gotSQL, gotArgs = sql, args
+			return fakeCommandTag{rowsAffected: 2}, nil
+		},
+	}
+
+	janitor := NewJanitor(db, newFakeRedis(), []CleanupTask{TrashPurgeTask(30 * 24 * time.Hour)}, time.Hour, 10)
+	deleted, err := janitor.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if deleted["trashed_notes"] != 2 {
+		t.Errorf("expected 2 trashed notes deleted, got %v", deleted)
+	}
+	// Without the row lock a note restored mid-purge would still be deleted
+	if !strings.Contains(gotSQL, "deleted_at < NOW() - $2::interval") || !strings.Contains(gotSQL, "LIMIT $1 FOR UPDATE SKIP LOCKED") {
+		t.Errorf("unexpected purge query: %s", gotSQL)
+	}
+	if len(gotArgs) != 2 || gotArgs[0] != 10 || gotArgs[1] != "2592000 seconds" {
+		t.Errorf("expected the batch size and retention, got %v", gotArgs)
+	}
+}
+
+func TestJanitor_RunOnce_ContinuesAfterError(t *testing.T) {
+	db := &fakeDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if strings.Contains(sql, "FROM sessions ") {
+				return nil, errors.New("boom")
+			}
+			return fakeCommandTag{rowsAffected: 1}, nil
+		},
+	}
+
+	janitor := NewJanitor(db, newFakeRedis(), DefaultCleanupTasks(time.Hour), time.Hour, 10)
+	deleted, err := janitor.RunOnce(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "cleaning sessions") {
+		t.Fatalf("expected sessions error, got %v", err)
+	}
+	if deleted["password_reset_tokens"] != 1 {
+		t.Errorf("expected other tasks to run, got %v", deleted)
+	}
+}
+
+func TestJanitor_RunCycle_SkipsWhenLockHeld(t *testing.T) {
+	var calls int
+	db := &fakeDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			calls++
+			return fakeCommandTag{}, nil
+		},
+	}
+	redis := newFakeRedis()
+	redis.values[janitorLockKey] = "other-replica"
+
+	janitor := NewJanitor(db, redis, DefaultCleanupTasks(time.Hour), time.Hour, 10)
+	janitor.runCycle(context.Background())
+
+	if calls != 0 {
+		t.Fatalf("expected no deletes while another replica holds the lock, got %d", calls)
+	}
+
+	var out strings.Builder
+	janitor.WriteMetrics(&out)
+	if !strings.Contains(out.String(), "janitor_skipped_total 1") {
+		t.Errorf("expected skipped metric, got:\n%s", out.String())
+	}
+}
+
+func TestJanitor_WriteMetrics(t *testing.T) {
+	db := &fakeDB{
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			return fakeCommandTag{rowsAffected: 2}, nil
+		},
+	}
+
+	janitor := NewJanitor(db, newFakeRedis(), DefaultCleanupTasks(time.Hour), time.Hour, 10)
+	janitor.runCycle(context.Background())
+
+	var out strings.Builder
+	janitor.WriteMetrics(&out)
+	metrics := out.String()
+
+	for _, want := range []string{
+		`janitor_rows_deleted_total{table="sessions"} 2`,
+		`janitor_rows_deleted_total{table="password_reset_tokens"} 2`,
+		"janitor_runs_total 1",
+		"janitor_errors_total 0",
+		"janitor_last_run_timestamp_seconds",
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("expected metrics to contain %q, got:\n%s", want, metrics)
+		}
+	}
+}
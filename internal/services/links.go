@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var (
+	// ErrNoteLinkNotFound covers unknown, revoked and expired links and links
+	// whose note is in the trash, so a visitor cannot tell them apart.
+	ErrNoteLinkNotFound         = errors.New("note link not found")
+	ErrNoteLinkPasswordRequired = errors.New("note link password required")
+	ErrNoteLinkPasswordInvalid  = errors.New("note link password invalid")
+	ErrTooManyNoteLinks         = errors.New("too many note links")
+)
+
+// MaxNoteLinksPerNote bounds how many unexpired links one note can have.
+const MaxNoteLinksPerNote = 20
+
+const noteLinkColumns = `id, note_id, password_hash IS NOT NULL, expires_at, created_at`
+
+func scanNoteLink(row Row, link *models.NoteLink) error {
+	return row.Scan(&link.ID, &link.NoteID, &link.HasPassword, &link.ExpiresAt, &link.CreatedAt)
+}
+
+const (
+	// noteLinkPasswordLimit is how many wrong passwords a link accepts per
+	// noteLinkPasswordWindow before it refuses further attempts.
+	noteLinkPasswordLimit  = 10
+	noteLinkPasswordWindow = 15 * time.Minute
+)
+
+type NoteLinkService struct {
+	db        DB
+	passwords *AttemptLimiter
+}
+
+// NewNoteLinkService builds a NoteLinkService. With a nil redis, password
+// attempts are not limited.
+func NewNoteLinkService(db DB, redis RedisClient) *NoteLinkService {
+	s := &NoteLinkService{db: db}
+	if redis != nil {
+		s.passwords = NewAttemptLimiter(redis, "note_link_passwords:", noteLinkPasswordLimit, noteLinkPasswordWindow)
+	}
+	return s
+}
+
+// Create makes a public link to one of the owner's notes and returns it with
+// its token. Only the token's hash is stored, so the token cannot be shown
+// again.
+func (s *NoteLinkService) Create(ctx context.Context, ownerID, noteID uuid.UUID, params models.CreateNoteLinkParams) (*models.NoteLink, string, error) {
+	var passwordHash *string
+	if params.Password != "" {
+		if len([]byte(params.Password)) > 72 {
+			return nil, "", ErrPasswordTooLong
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcryptCost)
+		if err != nil {
+			return nil, "", fmt.Errorf("hashing link password: %w", err)
+		}
+		h := string(hash)
+		passwordHash = &h
+	}
+	token, tokenHash, err := GenerateToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	link := &models.NoteLink{}
+	err = withTx(ctx, s.db, func(tx Tx) error {
+		if err := checkNoteOwner(ctx, tx, ownerID, noteID); err != nil {
+			return err
+		}
+		var count int
+		if err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM note_links
+			 WHERE note_id = $1 AND (expires_at IS NULL OR expires_at > NOW())`,
+			noteID,
+		).Scan(&count); err != nil {
+			return fmt.Errorf("counting links: %w", err)
+		}
+		if count >= MaxNoteLinksPerNote {
+			return ErrTooManyNoteLinks
+		}
+		return scanNoteLink(tx.QueryRow(ctx,
+			`INSERT INTO note_links (note_id, token_hash, password_hash, expires_at)
+			 VALUES ($1, $2, $3, $4)
+			 RETURNING `+noteLinkColumns,
+			noteID, tokenHash, passwordHash, params.ExpiresAt,
+		), link)
+	})
+	if err == ErrNoteNotFound || err == ErrTooManyNoteLinks {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("creating note link: %w", err)
+	}
+
+	return link, token, nil
+}
+
+// ListByNote returns the links to an owner's note, newest first, including
+// expired ones until the janitor removes them.
+func (s *NoteLinkService) ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteLink, error) {
+	if err := checkNoteOwner(ctx, s.db, ownerID, noteID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+noteLinkColumns+`
+		 FROM note_links WHERE note_id = $1
+		 ORDER BY created_at DESC, id DESC`,
+		noteID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing note links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*models.NoteLink{}
+	for rows.Next() {
+		link := &models.NoteLink{}
+		if err := scanNoteLink(rows, link); err != nil {
+			return nil, fmt.Errorf("scanning note link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating note links: %w", err)
+	}
+
+	return links, nil
+}
+
+// Revoke deletes a link to one of the owner's notes.
+func (s *NoteLinkService) Revoke(ctx context.Context, ownerID, noteID, linkID uuid.UUID) error {
+	result, err := s.db.Exec(ctx,
+		`DELETE FROM note_links l USING notes
+		 WHERE l.id = $1 AND l.note_id = $2 AND notes.id = l.note_id AND notes.user_id = $3`,
+		linkID, noteID, ownerID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoking note link: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrNoteLinkNotFound
+	}
+	return nil
+}
+
+// Resolve returns the note a link token points to. Links with a password
+// need it. Wrong passwords are counted per link, and once a link has had
+// too many it returns ErrTooManyAttempts for a while, whatever the password.
+func (s *NoteLinkService) Resolve(ctx context.Context, token, password string) (*models.Note, error) {
+	note := &models.Note{}
+	var passwordHash *string
+	tokenHash := HashToken(token)
+	err := scanNote(s.db.QueryRow(ctx,
+		`SELECT `+noteColumns+`, l.password_hash
+		 FROM note_links l JOIN notes ON notes.id = l.note_id
+		 WHERE l.token_hash = $1 AND notes.deleted_at IS NULL
+		   AND (l.expires_at IS NULL OR l.expires_at > NOW())`,
+		tokenHash,
+	), note, &passwordHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteLinkNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving note link: %w", err)
+	}
+
+	if passwordHash != nil {
+		if password == "" {
+			return nil, ErrNoteLinkPasswordRequired
+		}
+		if err := s.passwords.Check(ctx, tokenHash); err != nil {
+			return nil, err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*passwordHash), []byte(password)) != nil {
+			s.passwords.Fail(ctx, tokenHash)
+			return nil, ErrNoteLinkPasswordInvalid
+		}
+	}
+	return note, nil
+}
+
+// NoteLinkCleanupTask deletes links that expired more than retention ago.
+func NoteLinkCleanupTask(retention time.Duration) CleanupTask {
+	return CleanupTask{
+		Name: "note_links",
+		Query: `DELETE FROM note_links WHERE id IN (
+			SELECT id FROM note_links WHERE expires_at < NOW() - $2::interval
+			LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+		Args: []any{fmt.Sprintf("%d seconds", int64(retention.Seconds()))},
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestNoteLinkService_Resolve(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	protected := string(hash)
+
+	tests := []struct {
+		name         string
+		passwordHash *string
+		password     string
+		want         error
+	}{
+		{"open link", nil, "", nil},
+		{"password required", &protected, "", ErrNoteLinkPasswordRequired},
+		{"wrong password", &protected, "guess", ErrNoteLinkPasswordInvalid},
+		{"right password", &protected, "secret", nil},
+	}
+	for _, tt := range tests {
+		db := &mockDB{
+			queryRow: func(ctx context.Context, sql string, args ...any) Row {
+				if args[0] != HashToken("token") {
+					t.Fatalf("%s: expected the token hash, got %v", tt.name, args[0])
+				}
+				if !strings.Contains(sql, "expires_at > NOW()") || !strings.Contains(sql, "deleted_at IS NULL") {
+					t.Fatalf("%s: expected expired links and trashed notes to be excluded, got %s", tt.name, sql)
+				}
+				return mockRow{scan: func(dest ...any) error {
+					*dest[2].(*string) = "Title"
+					*dest[len(dest)-1].(**string) = tt.passwordHash
+					return nil
+				}}
+			},
+		}
+
+		note, err := NewNoteLinkService(db, nil).Resolve(context.Background(), "token", tt.password)
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+		if tt.want == nil && note.Title != "Title" {
+			t.Fatalf("%s: unexpected note %+v", tt.name, note)
+		}
+	}
+}
+
+func TestNoteLinkService_Resolve_LocksOutGuessing(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	protected := string(hash)
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				*dest[len(dest)-1].(**string) = &protected
+				return nil
+			}}
+		},
+	}
+	svc := NewNoteLinkService(db, newFakeRedis())
+
+	for i := 0; i < noteLinkPasswordLimit; i++ {
+		if _, err := svc.Resolve(context.Background(), "token", "guess"); !errors.Is(err, ErrNoteLinkPasswordInvalid) {
+			t.Fatalf("attempt %d: expected ErrNoteLinkPasswordInvalid, got %v", i, err)
+		}
+	}
+	// Locked out, even with the right password
+	if _, err := svc.Resolve(context.Background(), "token", "secret"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	// Other links are not affected
+	if _, err := svc.Resolve(context.Background(), "other", "secret"); err != nil {
+		t.Fatalf("expected another link to resolve, got %v", err)
+	}
+}
+
+func TestNoteLinkService_Resolve_NotFound(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+
+	if _, err := NewNoteLinkService(db, nil).Resolve(context.Background(), "revoked", ""); !errors.Is(err, ErrNoteLinkNotFound) {
+		t.Fatalf("expected ErrNoteLinkNotFound, got %v", err)
+	}
+}
+
+func TestNoteLinkService_Create_TooMany(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "INSERT") {
+				t.Fatal("unexpected insert")
+			}
+			return mockRow{scan: func(dest ...any) error {
+				switch d := dest[0].(type) {
+				case *bool:
+					*d = true
+				case *int:
+					*d = MaxNoteLinksPerNote
+				}
+				return nil
+			}}
+		},
+	}
+
+	_, _, err := NewNoteLinkService(db, nil).Create(context.Background(), uuid.New(), uuid.New(), models.CreateNoteLinkParams{})
+	if !errors.Is(err, ErrTooManyNoteLinks) {
+		t.Fatalf("expected ErrTooManyNoteLinks, got %v", err)
+	}
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var (
+	ErrNotebookNotFound       = errors.New("notebook not found")
+	ErrNotebookParentNotFound = errors.New("parent notebook not found")
+	ErrNotebookCycle          = errors.New("notebook cannot be moved into itself or a descendant")
+)
+
+const notebookColumns = `notebooks.id, notebooks.user_id, notebooks.parent_id, notebooks.name, notebooks.position,
+	(SELECT COUNT(*) FROM notes WHERE notes.notebook_id = notebooks.id AND notes.deleted_at IS NULL) AS note_count,
+	notebooks.created_at, notebooks.updated_at`
+
+func scanNotebook(row Row, nb *models.Notebook) error {
+	return row.Scan(&nb.ID, &nb.UserID, &nb.ParentID, &nb.Name, &nb.Position, &nb.NoteCount, &nb.CreatedAt, &nb.UpdatedAt)
+}
+
+// notebookSubtree selects the ids of notebook $1 (owned by $2) and all of its
+// descendants.
+const notebookSubtree = `WITH RECURSIVE subtree AS (
+		SELECT id FROM notebooks WHERE id = $1 AND user_id = $2
+		UNION ALL
+		SELECT n.id FROM notebooks n JOIN subtree s ON n.parent_id = s.id
+	)`
+
+// checkNotebookOwner returns ErrNotebookNotFound unless notebookID is nil or
+// one of the user's notebooks. The row is share-locked so it cannot be
+// deleted before the caller's transaction commits.
+func checkNotebookOwner(ctx context.Context, tx Tx, userID uuid.UUID, notebookID *uuid.UUID) error {
+	if notebookID == nil {
+		return nil
+	}
+	var id uuid.UUID
+	err := tx.QueryRow(ctx,
+		`SELECT id FROM notebooks WHERE id = $1 AND user_id = $2 FOR SHARE`,
+		*notebookID, userID,
+	).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotebookNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("checking notebook: %w", err)
+	}
+	return nil
+}
+
+// lockNotebookTree serializes structural changes to one user's notebooks so
+// two concurrent moves cannot combine into a cycle.
+func lockNotebookTree(ctx context.Context, tx Tx, userID uuid.UUID) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended('notebooks:' || $1::text, 0))`, userID); err != nil {
+		return fmt.Errorf("locking notebooks: %w", err)
+	}
+	return nil
+}
+
+type NotebookService struct {
+	db     DB
+	events NoteEventPublisher
+}
+
+// NewNotebookService creates a notebook service. Notes a notebook deletion
+// trashes or moves are reported to events; events may be nil.
+func NewNotebookService(db DB, events NoteEventPublisher) *NotebookService {
+	return &NotebookService{db: db, events: events}
+}
+
+// ListByUser returns every notebook of the user as a flat list ordered by
+// parent and position; clients assemble the tree from parent_id.
+func (s *NotebookService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Notebook, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+notebookColumns+`
+		 FROM notebooks WHERE user_id = $1
+		 ORDER BY parent_id NULLS FIRST, position, created_at`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing notebooks: %w", err)
+	}
+	defer rows.Close()
+
+	notebooks := []*models.Notebook{}
+	for rows.Next() {
+		nb := &models.Notebook{}
+		if err := scanNotebook(rows, nb); err != nil {
+			return nil, fmt.Errorf("scanning notebook: %w", err)
+		}
+		notebooks = append(notebooks, nb)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating notebooks: %w", err)
+	}
+
+	return notebooks, nil
+}
+
+// Create adds a notebook after its future siblings.
+func (s *NotebookService) Create(ctx context.Context, params models.CreateNotebookParams) (*models.Notebook, error) {
+	nb := &models.Notebook{}
+	err := scanNotebook(s.db.QueryRow(ctx,
+		`INSERT INTO notebooks (user_id, parent_id, name, position)
+		 SELECT $1::uuid, $2::uuid, $3::text,
+		        (SELECT COALESCE(MAX(position) + 1, 0) FROM notebooks
+		         WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2)
+		 WHERE $2::uuid IS NULL OR EXISTS (SELECT 1 FROM notebooks WHERE id = $2 AND user_id = $1)
+		 RETURNING `+notebookColumns,
+		params.UserID, params.ParentID, params.Name,
+	), nb)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotebookParentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating notebook: %w", err)
+	}
+
+	return nb, nil
+}
+
+func (s *NotebookService) GetByID(ctx context.Context, userID, notebookID uuid.UUID) (*models.Notebook, error) {
+	nb := &models.Notebook{}
+	err := scanNotebook(s.db.QueryRow(ctx,
+		`SELECT `+notebookColumns+` FROM notebooks WHERE id = $1 AND user_id = $2`,
+		notebookID, userID,
+	), nb)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotebookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting notebook: %w", err)
+	}
+
+	return nb, nil
+}
+
+func (s *NotebookService) Rename(ctx context.Context, userID, notebookID uuid.UUID, name string) (*models.Notebook, error) {
+	nb := &models.Notebook{}
+	err := scanNotebook(s.db.QueryRow(ctx,
+		`UPDATE notebooks SET name = $1 WHERE id = $2 AND user_id = $3
+		 RETURNING `+notebookColumns,
+		name, notebookID, userID,
+	), nb)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotebookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("renaming notebook: %w", err)
+	}
+
+	return nb, nil
+}
+
+// Move places a notebook under parentID (nil for the top level) at position
+// among its new siblings, shifting the others. Moving within the same parent
+// reorders. Positions past the end append.
+func (s *NotebookService) Move(ctx context.Context, userID, notebookID uuid.UUID, parentID *uuid.UUID, position int) (*models.Notebook, error) {
+	if parentID != nil && *parentID == notebookID {
+		return nil, ErrNotebookCycle
+	}
+	if position < 0 {
+		position = 0
+	}
+
+	nb := &models.Notebook{}
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if err := lockNotebookTree(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		var oldParent *uuid.UUID
+		var oldPosition int
+		err := tx.QueryRow(ctx,
+			`SELECT parent_id, position FROM notebooks WHERE id = $1 AND user_id = $2`,
+			notebookID, userID,
+		).Scan(&oldParent, &oldPosition)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotebookNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			var exists, cycle bool
+			err := tx.QueryRow(ctx,
+				notebookSubtree+`
+				 SELECT EXISTS (SELECT 1 FROM notebooks WHERE id = $3 AND user_id = $2),
+				        EXISTS (SELECT 1 FROM subtree WHERE id = $3)`,
+				notebookID, userID, *parentID,
+			).Scan(&exists, &cycle)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				return ErrNotebookParentNotFound
+			}
+			if cycle {
+				return ErrNotebookCycle
+			}
+		}
+
+		// Close the gap at the old position, then open one at the new position.
+		if _, err := tx.Exec(ctx,
+			`UPDATE notebooks SET position = position - 1
+			 WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND position > $3`,
+			userID, oldParent, oldPosition,
+		); err != nil {
+			return err
+		}
+		var siblings int
+		if err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM notebooks
+			 WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND id <> $3`,
+			userID, parentID, notebookID,
+		).Scan(&siblings); err != nil {
+			return err
+		}
+		if position > siblings {
+			position = siblings
+		}
+		if _, err := tx.Exec(ctx,
+			`UPDATE notebooks SET position = position + 1
+			 WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND position >= $3 AND id <> $4`,
+			userID, parentID, position, notebookID,
+		); err != nil {
+			return err
+		}
+
+		return scanNotebook(tx.QueryRow(ctx,
+			`UPDATE notebooks SET parent_id = $1, position = $2 WHERE id = $3
+			 RETURNING `+notebookColumns,
+			parentID, position, notebookID,
+		), nb)
+	})
+	if errors.Is(err, ErrNotebookNotFound) || errors.Is(err, ErrNotebookParentNotFound) || errors.Is(err, ErrNotebookCycle) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("moving notebook: %w", err)
+	}
+
+	return nb, nil
+}
+
+// Delete removes a notebook and its sub-notebooks. With deleteNotes the notes
+// they contain are deleted too; otherwise they move to the root.
+func (s *NotebookService) Delete(ctx context.Context, userID, notebookID uuid.UUID, deleteNotes bool) error {
+	var changes []noteChange
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if err := lockNotebookTree(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		var parentID *uuid.UUID
+		var position int
+		err := tx.QueryRow(ctx,
+			`SELECT parent_id, position FROM notebooks WHERE id = $1 AND user_id = $2`,
+			notebookID, userID,
+		).Scan(&parentID, &position)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotebookNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		// Notes outside the trash are trashed or moved to the root here, so
+		// their changes can be reported
+		set, event := "notebook_id = NULL", models.NoteEventUpdated
+		if deleteNotes {
+			set, event = "deleted_at = NOW()", models.NoteEventDeleted
+		}
+		rows, err := tx.Query(ctx,
+			notebookSubtree+`
+			 UPDATE notes SET `+set+`
+			 WHERE user_id = $2 AND notebook_id IN (SELECT id FROM subtree) AND deleted_at IS NULL
+			 RETURNING id, user_id, version`,
+			notebookID, userID,
+		)
+		if err != nil {
+			return err
+		}
+		if changes, err = scanNoteChanges(rows, event); err != nil {
+			return err
+		}
+		// Sub-notebooks cascade and the remaining notes, all in the trash,
+		// fall back to the root through the foreign keys.
+		if _, err := tx.Exec(ctx, `DELETE FROM notebooks WHERE id = $1`, notebookID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx,
+			`UPDATE notebooks SET position = position - 1
+			 WHERE user_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND position > $3`,
+			userID, parentID, position,
+		)
+		return err
+	})
+	if errors.Is(err, ErrNotebookNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("deleting notebook: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changes...)
+	return nil
+}
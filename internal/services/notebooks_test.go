@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestNotebookService_Create_ParentNotFound(t *testing.T) {
+	parentID := uuid.New()
+	svc := NewNotebookService(&fakeDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return fakeRow{scanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}, nil)
+
+	_, err := svc.Create(context.Background(), models.CreateNotebookParams{UserID: uuid.New(), ParentID: &parentID, Name: "Child"})
+	if !errors.Is(err, ErrNotebookParentNotFound) {
+		t.Fatalf("expected ErrNotebookParentNotFound, got %v", err)
+	}
+}
+
+func TestNotebookService_Move_Cycle(t *testing.T) {
+	notebookID := uuid.New()
+	childID := uuid.New()
+
+	svc := NewNotebookService(&fakeDB{}, nil)
+	if _, err := svc.Move(context.Background(), uuid.New(), notebookID, &notebookID, 0); !errors.Is(err, ErrNotebookCycle) {
+		t.Fatalf("expected ErrNotebookCycle for self-parent, got %v", err)
+	}
+
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "WITH RECURSIVE") {
+				if args[2] != childID {
+					t.Fatalf("expected cycle check against %s, got %v", childID, args[2])
+				}
+				return rowFromValues(true, true)
+			}
+			return rowFromValues(nil, 2)
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if strings.Contains(sql, "position") {
+				t.Fatalf("unexpected reorder after cycle: %s", sql)
+			}
+			return fakeCommandTag{}, nil
+		},
+	}
+	svc = NewNotebookService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+	if _, err := svc.Move(context.Background(), uuid.New(), notebookID, &childID, 0); !errors.Is(err, ErrNotebookCycle) {
+		t.Fatalf("expected ErrNotebookCycle for descendant parent, got %v", err)
+	}
+}
+
+func TestNotebookService_Move_Reorder(t *testing.T) {
+	userID := uuid.New()
+	notebookID := uuid.New()
+	now := time.Now()
+
+	var execs []string
+	var finalPosition any
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			switch {
+			case strings.HasPrefix(sql, "SELECT parent_id, position"):
+				return rowFromValues(nil, 0)
+			case strings.HasPrefix(sql, "SELECT COUNT(*)"):
+				return rowFromValues(2)
+			default:
+				finalPosition = args[1]
+				return rowFromValues(notebookID, userID, nil, "Work", args[1], 0, now, now)
+			}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			execs = append(execs, sql)
+			return fakeCommandTag{}, nil
+		},
+	}
+	svc := NewNotebookService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+
+	nb, err := svc.Move(context.Background(), userID, notebookID, nil, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Two siblings remain, so position 10 clamps to the end
+	if finalPosition != 2 || nb.Position != 2 {
+		t.Fatalf("expected position clamped to 2, got %v", finalPosition)
+	}
+	if len(execs) != 3 || !strings.Contains(execs[0], "pg_advisory_xact_lock") {
+		t.Fatalf("unexpected statements: %v", execs)
+	}
+}
+
+func TestNotebookService_Delete(t *testing.T) {
+	for _, deleteNotes := range []bool{false, true} {
+		userID, noteID := uuid.New(), uuid.New()
+		var statements []string
+		tx := &fakeTx{
+			QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+				return rowFromValues(nil, 1)
+			},
+			QueryFunc: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+				statements = append(statements, sql)
+				return &fakeRows{rows: [][]any{{noteID, userID, 3}}}, nil
+			},
+			ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+				statements = append(statements, sql)
+				return fakeCommandTag{rowsAffected: 1}, nil
+			},
+		}
+		events := &mockNoteEventPublisher{}
+		svc := NewNotebookService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, events)
+
+		if err := svc.Delete(context.Background(), userID, uuid.New(), deleteNotes); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		trashedNotes, movedNotes := false, false
+		for _, sql := range statements {
+			if strings.Contains(sql, "DELETE FROM notes") {
+				t.Fatalf("notes must go to the trash, not be deleted: %s", sql)
+			}
+			trashedNotes = trashedNotes || strings.Contains(sql, "SET deleted_at = NOW()")
+			movedNotes = movedNotes || strings.Contains(sql, "SET notebook_id = NULL")
+		}
+		if trashedNotes != deleteNotes || movedNotes == deleteNotes {
+			t.Fatalf("deleteNotes=%v: notes trashed = %v, moved = %v", deleteNotes, trashedNotes, movedNotes)
+		}
+
+		want := models.NoteEvent{Type: models.NoteEventUpdated, NoteID: noteID, Version: 3}
+		if deleteNotes {
+			want = models.NoteEvent{Type: models.NoteEventDeleted, NoteID: noteID}
+		}
+		if len(events.published) != 1 || events.published[0].userID != userID || events.published[0].event != want {
+			t.Fatalf("deleteNotes=%v: expected %+v, got %+v", deleteNotes, want, events.published)
+		}
+	}
+}
+
+func TestNotebookService_Delete_NotFound(t *testing.T) {
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return fakeRow{scanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+	svc := NewNotebookService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+
+	if err := svc.Delete(context.Background(), uuid.New(), uuid.New(), true); !errors.Is(err, ErrNotebookNotFound) {
+		t.Fatalf("expected ErrNotebookNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+// MaxNoteBulkItems caps the note changes in one bulk request, counted over
+// the note ids of every operation.
+const MaxNoteBulkItems = 100
+
+// ErrNoteBulkAborted means an all-or-nothing bulk request hit a note it could
+// not change and nothing was applied.
+var ErrNoteBulkAborted = errors.New("bulk note changes rolled back")
+
+// Bulk applies ops to the user's notes in one transaction, returning a result
+// per note in request order.
+//
+// With atomic set the first note that fails rolls everything back: the
+// results stop at that note and ErrNoteBulkAborted is returned. Otherwise
+// each note is changed under its own savepoint, a failure is reported only in
+// that note's result, and the rest commit.
+//
+// Deleting follows Delete, so editors can trash notes shared with them; every
+// other operation is the owner's, as in Patch.
+func (s *NoteService) Bulk(ctx context.Context, userID uuid.UUID, ops []models.NoteBulkOperation, atomic bool) ([]models.NoteBulkResult, error) {
+	var results []models.NoteBulkResult
+	var changed []noteChange
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		for i, op := range ops {
+			opErr := prepareBulkOperation(ctx, tx, userID, op)
+			if opErr != nil && !isBulkItemError(opErr) {
+				return opErr
+			}
+			for _, noteID := range op.NoteIDs {
+				var change noteChange
+				err := opErr
+				if err == nil && atomic {
+					change, err = applyBulkItem(ctx, tx, userID, op, noteID)
+				} else if err == nil {
+					err = withSavepoint(ctx, tx, func() error {
+						var itemErr error
+						change, itemErr = applyBulkItem(ctx, tx, userID, op, noteID)
+						return itemErr
+					})
+				}
+				results = append(results, models.NoteBulkResult{Op: i, NoteID: noteID, Err: err})
+				if err != nil && (atomic || !isBulkItemError(err)) {
+					return err
+				}
+				if err == nil {
+					changed = append(changed, change)
+				}
+			}
+		}
+		return nil
+	})
+	if isBulkItemError(err) {
+		return results, ErrNoteBulkAborted
+	}
+	if err != nil {
+		return nil, fmt.Errorf("applying bulk note changes: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changed...)
+	return results, nil
+}
+
+// isBulkItemError reports whether err is a reason one note of a bulk request
+// could not be changed, as opposed to a failure of the whole request.
+func isBulkItemError(err error) bool {
+	return err == ErrNoteNotFound || err == ErrNoteForbidden || err == ErrNotebookNotFound || err == ErrTooManyTags
+}
+
+// prepareBulkOperation does the work an operation shares between its notes:
+// checking a move's notebook.
+func prepareBulkOperation(ctx context.Context, tx Tx, userID uuid.UUID, op models.NoteBulkOperation) error {
+	if op.Op == models.NoteBulkMove {
+		return checkNotebookOwner(ctx, tx, userID, op.NotebookID)
+	}
+	return nil
+}
+
+// applyBulkItem applies op to one note and returns the change to report.
+// Retagging touches the note so its version and updated_at move, as a
+// tag-only Patch does. Tags are created per note, under its savepoint, so a
+// note that fails leaves no unused tags behind.
+func applyBulkItem(ctx context.Context, tx Tx, userID uuid.UUID, op models.NoteBulkOperation, noteID uuid.UUID) (noteChange, error) {
+	change := noteChange{event: models.NoteEventUpdated, noteID: noteID}
+	args := []any{noteID, userID}
+	set, access, ownerOnly := "updated_at = NOW()", "notes.user_id = $2", true
+	switch op.Op {
+	case models.NoteBulkDelete:
+		set, access, ownerOnly = "deleted_at = NOW()", noteWritableBy, false
+		change.event = models.NoteEventDeleted
+	case models.NoteBulkMove:
+		set = "notebook_id = $3"
+		args = append(args, op.NotebookID)
+	case models.NoteBulkArchive, models.NoteBulkUnarchive:
+		set = "archived = $3"
+		args = append(args, op.Op == models.NoteBulkArchive)
+	case models.NoteBulkTag, models.NoteBulkUntag:
+	default:
+		return change, fmt.Errorf("unknown bulk operation %q", op.Op)
+	}
+
+	err := tx.QueryRow(ctx,
+		`UPDATE notes SET `+set+` WHERE id = $1 AND deleted_at IS NULL AND `+access+`
+		 RETURNING user_id, version`,
+		args...,
+	).Scan(&change.ownerID, &change.version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return change, noteWriteConflict(ctx, tx, userID, noteID, 0, ownerOnly)
+	}
+	if err != nil {
+		return change, fmt.Errorf("updating note: %w", err)
+	}
+	if change.event == models.NoteEventDeleted {
+		change.version = 0
+	}
+
+	switch op.Op {
+	case models.NoteBulkTag:
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO tags (user_id, name) SELECT $1::uuid, unnest($2::text[])
+			 ON CONFLICT (user_id, name) DO NOTHING`,
+			userID, op.Tags,
+		); err != nil {
+			return change, fmt.Errorf("creating tags: %w", err)
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO note_tags (note_id, tag_id)
+			 SELECT $1::uuid, id FROM tags WHERE user_id = $2 AND name = ANY($3)
+			 ON CONFLICT DO NOTHING`,
+			noteID, userID, op.Tags,
+		); err != nil {
+			return change, fmt.Errorf("attaching tags: %w", err)
+		}
+		var count int
+		if err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM note_tags WHERE note_id = $1`,
+			noteID,
+		).Scan(&count); err != nil {
+			return change, fmt.Errorf("counting tags: %w", err)
+		}
+		if count > MaxTagsPerNote {
+			return change, ErrTooManyTags
+		}
+	case models.NoteBulkUntag:
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM note_tags WHERE note_id = $1
+			 AND tag_id IN (SELECT id FROM tags WHERE user_id = $2 AND name = ANY($3))`,
+			noteID, userID, op.Tags,
+		); err != nil {
+			return change, fmt.Errorf("detaching tags: %w", err)
+		}
+	}
+	return change, nil
+}
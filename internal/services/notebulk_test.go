@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+// bulkDB changes every note except missing, which no one can see, and
+// records the statements it ran.
+func bulkDB(missing uuid.UUID, tagCount int, execs *[]string) *mockDB {
+	return &mockDB{
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			*execs = append(*execs, sql)
+			return mockCommandTag{affected: 1}, nil
+		},
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				switch {
+				case strings.HasPrefix(sql, "UPDATE notes"):
+					*execs = append(*execs, sql)
+					if args[0] == missing {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*uuid.UUID) = args[1].(uuid.UUID)
+					*dest[1].(*int) = 2
+				case strings.Contains(sql, "COUNT(*) FROM note_tags"):
+					*dest[0].(*int) = tagCount
+				case strings.Contains(sql, "note_shares"):
+					*dest[0].(*bool) = false
+					*dest[1].(*string) = ""
+				}
+				return nil
+			}}
+		},
+	}
+}
+
+func TestNoteService_Bulk_AtomicAbortsOnFailure(t *testing.T) {
+	missing := uuid.New()
+	var execs []string
+	svc := NewNoteService(bulkDB(missing, 0, &execs), nil)
+
+	ops := []models.NoteBulkOperation{
+		{Op: models.NoteBulkDelete, NoteIDs: []uuid.UUID{uuid.New(), missing, uuid.New()}},
+	}
+	results, err := svc.Bulk(context.Background(), uuid.New(), ops, true)
+	if !errors.Is(err, ErrNoteBulkAborted) {
+		t.Fatalf("expected ErrNoteBulkAborted, got %v", err)
+	}
+	if len(results) != 2 || results[1].NoteID != missing || results[1].Err != ErrNoteNotFound {
+		t.Fatalf("expected results to stop at the missing note, got %+v", results)
+	}
+	for _, sql := range execs {
+		if strings.Contains(sql, "SAVEPOINT") {
+			t.Fatalf("unexpected savepoint in atomic mode: %s", sql)
+		}
+	}
+}
+
+func TestNoteService_Bulk_BestEffort(t *testing.T) {
+	missing := uuid.New()
+	var execs []string
+	events := &mockNoteEventPublisher{}
+	svc := NewNoteService(bulkDB(missing, 0, &execs), events)
+
+	notebookID := uuid.New()
+	ops := []models.NoteBulkOperation{
+		{Op: models.NoteBulkMove, NoteIDs: []uuid.UUID{missing, uuid.New()}, NotebookID: &notebookID},
+		{Op: models.NoteBulkArchive, NoteIDs: []uuid.UUID{uuid.New()}},
+	}
+	results, err := svc.Bulk(context.Background(), uuid.New(), ops, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected a result per note, got %+v", results)
+	}
+	if results[0].Err != ErrNoteNotFound || results[1].Err != nil || results[2].Err != nil || results[2].Op != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	var rolledBack, archived bool
+	for _, sql := range execs {
+		rolledBack = rolledBack || sql == "ROLLBACK TO SAVEPOINT item"
+		archived = archived || strings.Contains(sql, "SET archived = $3")
+	}
+	if !rolledBack || !archived {
+		t.Fatalf("expected the failed note rolled back to its savepoint and the rest applied, got %v", execs)
+	}
+	if len(events.published) != 2 || events.published[0].event.NoteID != results[1].NoteID ||
+		events.published[1].event.Type != models.NoteEventUpdated || events.published[1].event.Version != 2 {
+		t.Fatalf("expected an updated event per changed note, got %+v", events.published)
+	}
+}
+
+func TestNoteService_Bulk_TagLimit(t *testing.T) {
+	var execs []string
+	svc := NewNoteService(bulkDB(uuid.Nil, MaxTagsPerNote+1, &execs), nil)
+
+	ops := []models.NoteBulkOperation{{Op: models.NoteBulkTag, NoteIDs: []uuid.UUID{uuid.New()}, Tags: []string{"work"}}}
+	results, err := svc.Bulk(context.Background(), uuid.New(), ops, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != ErrTooManyTags {
+		t.Fatalf("expected ErrTooManyTags, got %+v", results)
+	}
+}
+
+func TestNoteService_Bulk_TagCreatedPerNote(t *testing.T) {
+	missing := uuid.New()
+	var execs []string
+	svc := NewNoteService(bulkDB(missing, 0, &execs), nil)
+
+	ops := []models.NoteBulkOperation{{Op: models.NoteBulkTag, NoteIDs: []uuid.UUID{missing}, Tags: []string{"new"}}}
+	results, err := svc.Bulk(context.Background(), uuid.New(), ops, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Err != ErrNoteNotFound {
+		t.Fatalf("expected ErrNoteNotFound, got %+v", results)
+	}
+	// The only note failed, so no tag may be created outside its savepoint
+	for _, sql := range execs {
+		if strings.Contains(sql, "INSERT INTO tags") {
+			t.Fatalf("expected no tags created for a failed note, got %v", execs)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/logging"
+	"github.com/example/notes-template/internal/models"
+)
+
+const (
+	// noteEventsChannel is the pub/sub channel every replica listens on.
+	noteEventsChannel = "note_events"
+
+	// noteEventStreamPrefix prefixes the per-user stream kept for replay.
+	noteEventStreamPrefix = "note_events:"
+
+	// noteEventBacklog is about how many events a user's stream keeps. A
+	// client that missed more reloads instead of replaying.
+	noteEventBacklog = 1000
+
+	// noteEventRetention is how long a user's stream outlives their last
+	// event.
+	noteEventRetention = 24 * time.Hour
+
+	// noteEventBuffer is how many events a subscriber may fall behind before
+	// it is dropped. Its client reconnects and replays what it missed.
+	noteEventBuffer = 64
+)
+
+// NoteEventPublisher receives the changes the note services make. Publishing must
+// not fail the change it reports, so Publish handles its own errors.
+type NoteEventPublisher interface {
+	Publish(ctx context.Context, userID uuid.UUID, event models.NoteEvent)
+}
+
+// noteChange is a note a write changed, published once the write commits.
+type noteChange struct {
+	event   string
+	noteID  uuid.UUID
+	ownerID uuid.UUID
+	version int
+}
+
+// scanNoteChanges reads the id, user_id and version of each note a write
+// changed, as returned by its RETURNING clause.
+func scanNoteChanges(rows Rows, event string) ([]noteChange, error) {
+	defer rows.Close()
+	var changes []noteChange
+	for rows.Next() {
+		change := noteChange{event: event}
+		if err := rows.Scan(&change.noteID, &change.ownerID, &change.version); err != nil {
+			return nil, err
+		}
+		if event == models.NoteEventDeleted {
+			change.version = 0
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// publishNoteChanges reports committed changes to events, which may be nil.
+// The changes are saved, so publishing outlives a cancelled request.
+func publishNoteChanges(ctx context.Context, events NoteEventPublisher, changes ...noteChange) {
+	if events == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	for _, change := range changes {
+		events.Publish(ctx, change.ownerID, models.NoteEvent{Type: change.event, NoteID: change.noteID, Version: change.version})
+	}
+}
+
+// NoteEventSubscription is one client's feed of a user's note events.
+type NoteEventSubscription struct {
+	// Replay holds the events after the client's Last-Event-ID, to send
+	// before Events.
+	Replay []models.NoteEvent
+	// Reset means the client must reload its notes, because it had no
+	// Last-Event-ID or is too far behind to replay. Cursor is then the
+	// position of the reload; otherwise it is the last event replayed.
+	Reset  bool
+	Cursor string
+	// Events delivers new events, possibly some already replayed, until the
+	// subscription closes it.
+	Events <-chan models.NoteEvent
+	Close  func()
+}
+
+// NoteEvents records note changes in a redis stream per user and broadcasts
+// them over pub/sub, so a change made on any replica reaches the user's
+// clients on every replica. Each replica holds one pub/sub connection and
+// fans events out to its own subscribers.
+type NoteEvents struct {
+	redis RedisEventLog
+
+	mu     sync.Mutex
+	subs   map[uuid.UUID]map[chan models.NoteEvent]struct{}
+	closed bool
+}
+
+func NewNoteEvents(redis RedisEventLog) *NoteEvents {
+	return &NoteEvents{redis: redis, subs: make(map[uuid.UUID]map[chan models.NoteEvent]struct{})}
+}
+
+func noteEventStream(userID uuid.UUID) string {
+	return noteEventStreamPrefix + userID.String()
+}
+
+// Publish records and broadcasts event for the user. Failures are logged: the
+// change itself is saved, and clients catch up on their next reload.
+func (n *NoteEvents) Publish(ctx context.Context, userID uuid.UUID, event models.NoteEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now().UTC()
+	}
+	data, err := json.Marshal(event)
+	if err == nil {
+		_, err = n.redis.AppendEvent(ctx, noteEventStream(userID), noteEventsChannel, string(data),
+			noteEventBacklog, noteEventRetention)
+	}
+	if err != nil {
+		logging.Error("Failed to publish note event", map[string]interface{}{
+			"user_id": userID.String(), "note_id": event.NoteID.String(), "error": err.Error(),
+		})
+	}
+}
+
+// Run delivers broadcast events to this replica's subscribers until ctx is
+// done, then closes every subscription so their streams end.
+func (n *NoteEvents) Run(ctx context.Context) {
+	for entry := range n.redis.SubscribeEvents(ctx, noteEventsChannel) {
+		userID, err := uuid.Parse(strings.TrimPrefix(entry.Stream, noteEventStreamPrefix))
+		if err != nil {
+			continue
+		}
+		event, err := decodeNoteEvent(entry)
+		if err != nil {
+			continue
+		}
+		n.dispatch(userID, event)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	for _, subs := range n.subs {
+		for ch := range subs {
+			close(ch)
+		}
+	}
+	n.subs = nil
+}
+
+func decodeNoteEvent(entry StreamEntry) (models.NoteEvent, error) {
+	var event models.NoteEvent
+	if err := json.Unmarshal([]byte(entry.Value), &event); err != nil {
+		return event, err
+	}
+	event.ID = entry.ID
+	return event, nil
+}
+
+// dispatch hands event to the user's subscribers, dropping any that are too
+// far behind to take it.
+func (n *NoteEvents) dispatch(userID uuid.UUID, event models.NoteEvent) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs[userID] {
+		select {
+		case ch <- event:
+		default:
+			n.remove(userID, ch)
+		}
+	}
+}
+
+// remove closes and forgets a subscriber. The caller holds n.mu.
+func (n *NoteEvents) remove(userID uuid.UUID, ch chan models.NoteEvent) {
+	if _, ok := n.subs[userID][ch]; !ok {
+		return
+	}
+	delete(n.subs[userID], ch)
+	if len(n.subs[userID]) == 0 {
+		delete(n.subs, userID)
+	}
+	close(ch)
+}
+
+// Subscribe starts a feed of the user's note events. With a lastEventID the
+// feed replays what came after it when the stream still has it; otherwise
+// the subscription asks the client to reload. The caller must call Close.
+func (n *NoteEvents) Subscribe(ctx context.Context, userID uuid.UUID, lastEventID string) (*NoteEventSubscription, error) {
+	// Subscribe before reading the stream so no event falls in between
+	ch := make(chan models.NoteEvent, noteEventBuffer)
+	n.mu.Lock()
+	if n.closed {
+		close(ch)
+	} else {
+		if n.subs[userID] == nil {
+			n.subs[userID] = make(map[chan models.NoteEvent]struct{})
+		}
+		n.subs[userID][ch] = struct{}{}
+	}
+	n.mu.Unlock()
+
+	sub := &NoteEventSubscription{Events: ch, Close: func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.remove(userID, ch)
+	}}
+
+	if lastEventID != "" {
+		replay, ok, err := n.since(ctx, userID, lastEventID)
+		if err != nil {
+			sub.Close()
+			return nil, err
+		}
+		if ok {
+			sub.Replay, sub.Cursor = replay, lastEventID
+			if len(replay) > 0 {
+				sub.Cursor = replay[len(replay)-1].ID
+			}
+			return sub, nil
+		}
+	}
+
+	cursor, err := n.redis.LastEventID(ctx, noteEventStream(userID))
+	if err != nil {
+		sub.Close()
+		return nil, fmt.Errorf("reading note events: %w", err)
+	}
+	sub.Reset, sub.Cursor = true, cursor
+	return sub, nil
+}
+
+// since returns the user's events after lastEventID, or false if the stream
+// no longer reaches back that far. "0-0" is the position of an empty stream.
+func (n *NoteEvents) since(ctx context.Context, userID uuid.UUID, lastEventID string) ([]models.NoteEvent, bool, error) {
+	if _, _, ok := models.ParseNoteEventID(lastEventID); !ok {
+		return nil, false, nil
+	}
+	// The range includes lastEventID itself, which proves nothing after it
+	// was trimmed
+	entries, err := n.redis.ReadEvents(ctx, noteEventStream(userID), lastEventID, noteEventBacklog+1)
+	if err != nil {
+		return nil, false, fmt.Errorf("reading note events: %w", err)
+	}
+	if len(entries) > noteEventBacklog {
+		return nil, false, nil
+	}
+	if lastEventID != "0-0" {
+		if len(entries) == 0 || entries[0].ID != lastEventID {
+			return nil, false, nil
+		}
+		entries = entries[1:]
+	}
+
+	events := make([]models.NoteEvent, 0, len(entries))
+	for _, entry := range entries {
+		event, err := decodeNoteEvent(entry)
+		if err != nil {
+			return nil, false, fmt.Errorf("decoding note event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, true, nil
+}
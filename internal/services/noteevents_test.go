@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+type publishedNoteEvent struct {
+	userID uuid.UUID
+	event  models.NoteEvent
+}
+
+type mockNoteEventPublisher struct {
+	published []publishedNoteEvent
+}
+
+func (m *mockNoteEventPublisher) Publish(ctx context.Context, userID uuid.UUID, event models.NoteEvent) {
+	m.published = append(m.published, publishedNoteEvent{userID: userID, event: event})
+}
+
+// memoryEventLog keeps streams in memory and broadcasts on one channel, which
+// the test closes to stop Run.
+type memoryEventLog struct {
+	streams map[string][]StreamEntry
+	seq     int
+	live    chan StreamEntry
+}
+
+func newMemoryEventLog() *memoryEventLog {
+	return &memoryEventLog{streams: make(map[string][]StreamEntry), live: make(chan StreamEntry, 16)}
+}
+
+func (m *memoryEventLog) AppendEvent(ctx context.Context, stream, channel, value string, maxLen int64, ttl time.Duration) (string, error) {
+	m.seq++
+	entry := StreamEntry{Stream: stream, ID: fmt.Sprintf("%d-0", m.seq), Value: value}
+	m.streams[stream] = append(m.streams[stream], entry)
+	m.live <- entry
+	return entry.ID, nil
+}
+
+func (m *memoryEventLog) ReadEvents(ctx context.Context, stream, start string, count int64) ([]StreamEntry, error) {
+	var entries []StreamEntry
+	for _, entry := range m.streams[stream] {
+		if entry.ID == start || (models.NoteEvent{ID: entry.ID}).After(start) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries[:min(len(entries), int(count))], nil
+}
+
+func (m *memoryEventLog) LastEventID(ctx context.Context, stream string) (string, error) {
+	entries := m.streams[stream]
+	if len(entries) == 0 {
+		return "0-0", nil
+	}
+	return entries[len(entries)-1].ID, nil
+}
+
+func (m *memoryEventLog) SubscribeEvents(ctx context.Context, channel string) <-chan StreamEntry {
+	return m.live
+}
+
+func receiveNoteEvent(t *testing.T, events <-chan models.NoteEvent) models.NoteEvent {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return models.NoteEvent{}
+}
+
+func TestNoteEvents_Deliver(t *testing.T) {
+	log := newMemoryEventLog()
+	events := NewNoteEvents(log)
+	userID := uuid.New()
+
+	sub, err := events.Subscribe(context.Background(), userID, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+	if !sub.Reset || sub.Cursor != "0-0" {
+		t.Fatalf("expected a fresh subscription to reload from 0-0, got %+v", sub)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		events.Run(context.Background())
+		close(done)
+	}()
+
+	noteID := uuid.New()
+	events.Publish(context.Background(), uuid.New(), models.NoteEvent{Type: models.NoteEventCreated, NoteID: uuid.New()})
+	events.Publish(context.Background(), userID, models.NoteEvent{Type: models.NoteEventUpdated, NoteID: noteID, Version: 3})
+
+	got := receiveNoteEvent(t, sub.Events)
+	if got.ID != "2-0" || got.Type != models.NoteEventUpdated || got.NoteID != noteID || got.Version != 3 || got.At.IsZero() {
+		t.Fatalf("expected only the user's event, got %+v", got)
+	}
+
+	// Stopping Run ends every subscription
+	close(log.live)
+	<-done
+	if _, ok := <-sub.Events; ok {
+		t.Fatal("expected the subscription to be closed")
+	}
+	late, err := events.Subscribe(context.Background(), userID, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := <-late.Events; ok {
+		t.Fatal("expected subscriptions after shutdown to be closed")
+	}
+}
+
+func TestNoteEvents_Replay(t *testing.T) {
+	log := newMemoryEventLog()
+	events := NewNoteEvents(log)
+	userID := uuid.New()
+	for i := 0; i < 3; i++ {
+		events.Publish(context.Background(), userID, models.NoteEvent{Type: models.NoteEventCreated, NoteID: uuid.New()})
+	}
+	// Trim the first event, as the backlog cap would
+	stream := noteEventStream(userID)
+	log.streams[stream] = log.streams[stream][1:]
+
+	tests := []struct {
+		lastEventID string
+		reset       bool
+		cursor      string
+		replay      int
+	}{
+		{"2-0", false, "3-0", 1},
+		{"3-0", false, "3-0", 0},
+		{"0-0", false, "3-0", 2},
+		{"1-0", true, "3-0", 0}, // Trimmed
+		{"9-0", true, "3-0", 0}, // Unknown
+		{"bogus", true, "3-0", 0},
+	}
+	for _, tt := range tests {
+		sub, err := events.Subscribe(context.Background(), userID, tt.lastEventID)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.lastEventID, err)
+		}
+		sub.Close()
+		if sub.Reset != tt.reset || sub.Cursor != tt.cursor || len(sub.Replay) != tt.replay {
+			t.Fatalf("%s: expected reset=%v cursor=%s and %d replayed, got %+v", tt.lastEventID, tt.reset, tt.cursor, tt.replay, sub)
+		}
+		if tt.replay > 0 && sub.Replay[len(sub.Replay)-1].ID != "3-0" {
+			t.Fatalf("%s: expected replay to end at the latest event, got %+v", tt.lastEventID, sub.Replay)
+		}
+	}
+}
+
+func TestNoteEvents_DropsSlowSubscribers(t *testing.T) {
+	events := NewNoteEvents(newMemoryEventLog())
+	userID := uuid.New()
+	sub, err := events.Subscribe(context.Background(), userID, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sub.Close()
+
+	for i := 0; i <= noteEventBuffer; i++ {
+		events.dispatch(userID, models.NoteEvent{ID: fmt.Sprintf("%d-0", i+1)})
+	}
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	if received != noteEventBuffer {
+		t.Fatalf("expected the buffered events and then a closed subscription, got %d", received)
+	}
+}
+
+func TestNoteEvent_After(t *testing.T) {
+	tests := []struct {
+		id, other string
+		want      bool
+	}{
+		{"5-1", "5-0", true},
+		{"6-0", "5-9", true},
+		{"10-0", "9-0", true},
+		{"5-0", "5-0", false},
+		{"5-0", "", true},
+		{"", "5-0", false},
+	}
+	for _, tt := range tests {
+		if got := (models.NoteEvent{ID: tt.id}).After(tt.other); got != tt.want {
+			t.Errorf("%q after %q: expected %v, got %v", tt.id, tt.other, tt.want, got)
+		}
+	}
+}
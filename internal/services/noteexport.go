@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+// Export calls fn with each of the user's notes, archived ones included and
+// trashed ones left out, oldest first. Notes are read as they are sent rather
+// than loaded up front, so the export holds a database connection until fn
+// has seen the last one. An error from fn stops the export and is returned
+// unwrapped.
+func (s *NoteService) Export(ctx context.Context, userID uuid.UUID, fn func(*models.Note) error) error {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+noteColumns+`
+		 FROM notes WHERE notes.user_id = $1 AND notes.deleted_at IS NULL
+		 ORDER BY notes.created_at, notes.id`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("exporting notes: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		note := &models.Note{}
+		if err := scanNote(rows, note); err != nil {
+			return fmt.Errorf("scanning note: %w", err)
+		}
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating notes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestNoteService_Export(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	rows := [][]any{
+		{uuid.New(), userID, "First", "Body", now, now, []string{"work"}, nil, 1, false, false},
+		{uuid.New(), userID, "Archived", "Body", now, now, []string{}, nil, 2, false, true},
+		{uuid.New(), userID, "Unsent", "Body", now, now, []string{}, nil, 1, false, false},
+	}
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			if !strings.Contains(sql, "notes.user_id = $1 AND notes.deleted_at IS NULL") {
+				t.Fatalf("expected the caller's notes outside the trash, got %s", sql)
+			}
+			if strings.Contains(sql, "NOT notes.archived") {
+				t.Fatalf("expected archived notes to be exported, got %s", sql)
+			}
+			if args[0] != userID {
+				t.Fatalf("expected user %s, got %v", userID, args[0])
+			}
+			return &mockRows{rows: rows}, nil
+		},
+	}
+	svc := NewNoteService(db, nil)
+
+	var titles []string
+	stop := errors.New("client went away")
+	err := svc.Export(context.Background(), userID, func(note *models.Note) error {
+		titles = append(titles, note.Title)
+		if note.Archived {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("expected fn's error unwrapped, got %v", err)
+	}
+	if strings.Join(titles, ",") != "First,Archived" {
+		t.Fatalf("expected the export to stop at the failing note, got %v", titles)
+	}
+}
@@ -2,8 +2,14 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -11,46 +17,202 @@ import (
 	"github.com/example/notes-template/internal/models"
 )
 
-var ErrNoteNotFound = errors.New("note not found")
+var (
+	ErrNoteNotFound    = errors.New("note not found")
+	ErrNoteNotInTrash  = errors.New("note not in trash")
+	ErrInvalidCursor   = errors.New("invalid cursor")
+	ErrInvalidNoteSort = errors.New("invalid note sort")
+	ErrEmptySearch     = errors.New("search query has no searchable words")
+
+	// ErrNoteForbidden means the user can see the note through a share but
+	// the share does not allow this change.
+	ErrNoteForbidden = errors.New("note change not permitted")
+
+	// ErrNoteVersionMismatch means a conditional write found the note at a
+	// different version than the caller expected.
+	ErrNoteVersionMismatch = errors.New("note version mismatch")
+)
+
+const (
+	DefaultNoteListLimit = 50
+	MaxNoteListLimit     = 100
+
+	DefaultNoteSearchLimit = 20
+)
+
+// Headline delimiters are control characters so Search can HTML-escape the
+// snippet before turning them into <mark> tags.
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+var headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop +
+	", MaxWords=30, MinWords=10, MaxFragments=2, FragmentDelimiter=\" … \""
+
+var headlineReplacer = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>")
+
+// noteSortColumns whitelists the columns ListByUser may interpolate into
+// ORDER BY and the keyset condition.
+var noteSortColumns = map[string]string{
+	models.NoteSortUpdated: "updated_at",
+	models.NoteSortCreated: "created_at",
+	models.NoteSortTitle:   "title",
+}
+
+// noteColumns is the select list scanned by scanNote. Tags are aggregated per
+// row through the note_tags primary key rather than joined, so pagination and
+// ranking stay one row per note.
+const noteColumns = `notes.id, notes.user_id, notes.title, notes.body, notes.created_at, notes.updated_at,
+	COALESCE((SELECT array_agg(t.name ORDER BY t.name) FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+	          WHERE nt.note_id = notes.id), '{}') AS tags,
+	notes.notebook_id, notes.version, notes.pinned, notes.archived`
+
+// Access conditions for the note in $1 and the user in $2. Owners can do
+// anything; a share lets viewers read the note and editors also write it.
+const (
+	noteReadableBy = `(notes.user_id = $2 OR EXISTS (SELECT 1 FROM note_shares s
+		WHERE s.note_id = notes.id AND s.user_id = $2))`
+	noteWritableBy = `(notes.user_id = $2 OR EXISTS (SELECT 1 FROM note_shares s
+		WHERE s.note_id = notes.id AND s.user_id = $2 AND s.permission = 'editor'))`
+)
+
+func scanNote(row Row, note *models.Note, extra ...any) error {
+	dest := append([]any{&note.ID, &note.UserID, &note.Title, &note.Body, &note.CreatedAt, &note.UpdatedAt, &note.Tags, &note.NotebookID, &note.Version, &note.Pinned, &note.Archived}, extra...)
+	return row.Scan(dest...)
+}
 
 type NoteService struct {
-	db DBConn
+	db     DB
+	events NoteEventPublisher
+}
+
+// NewNoteService creates a note service. Changes are reported to events
+// after they commit; events may be nil.
+func NewNoteService(db DB, events NoteEventPublisher) *NoteService {
+	return &NoteService{db: db, events: events}
 }
 
-func NewNoteService(db DBConn) *NoteService {
-	return &NoteService{db: db}
+// publish reports a committed change to the note's owner.
+func (s *NoteService) publish(ctx context.Context, ownerID uuid.UUID, eventType string, noteID uuid.UUID, version int) {
+	publishNoteChanges(ctx, s.events, noteChange{event: eventType, noteID: noteID, ownerID: ownerID, version: version})
 }
 
 func (s *NoteService) Create(ctx context.Context, params models.CreateNoteParams) (*models.Note, error) {
 	note := &models.Note{}
-	err := s.db.QueryRow(ctx,
-		`INSERT INTO notes (user_id, title, body)
-		 VALUES ($1, $2, $3)
-		 RETURNING id, user_id, title, body, created_at, updated_at`,
-		params.UserID, params.Title, params.Body,
-	).Scan(&note.ID, &note.UserID, &note.Title, &note.Body, &note.CreatedAt, &note.UpdatedAt)
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if err := checkNotebookOwner(ctx, tx, params.UserID, params.NotebookID); err != nil {
+			return err
+		}
+		err := scanNote(tx.QueryRow(ctx,
+			`INSERT INTO notes (user_id, title, body, notebook_id)
+			 VALUES ($1, $2, $3, $4)
+			 RETURNING `+noteColumns,
+			params.UserID, params.Title, params.Body, params.NotebookID,
+		), note)
+		if err != nil {
+			return err
+		}
+		if err := recordRevision(ctx, tx, note); err != nil {
+			return err
+		}
+		if len(params.Tags) == 0 {
+			return nil
+		}
+		note.Tags = params.Tags
+		return setNoteTags(ctx, tx, params.UserID, note.ID, params.Tags)
+	})
+	if errors.Is(err, ErrNotebookNotFound) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("creating note: %w", err)
 	}
 
+	s.publish(ctx, note.UserID, models.NoteEventCreated, note.ID, note.Version)
 	return note, nil
 }
 
-func (s *NoteService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Note, error) {
-	rows, err := s.db.Query(ctx,
-		`SELECT id, user_id, title, body, created_at, updated_at
-		 FROM notes WHERE user_id = $1 ORDER BY updated_at DESC`,
-		userID,
+// ListByUser returns one page of a user's notes using keyset pagination on
+// (pinned, sort column, id), so deep pages cost the same as the first one.
+// Pinned notes come first whatever the sort order. Archived notes are left
+// out unless opts.Archived asks for only them.
+func (s *NoteService) ListByUser(ctx context.Context, userID uuid.UUID, opts models.NoteListOptions) (*models.NotePage, error) {
+	if opts.Sort == "" {
+		opts.Sort = models.NoteSortUpdated
+	}
+	column, ok := noteSortColumns[opts.Sort]
+	if !ok {
+		return nil, ErrInvalidNoteSort
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultNoteListLimit
+	}
+	if limit > MaxNoteListLimit {
+		limit = MaxNoteListLimit
+	}
+
+	args := []any{userID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	conds := []string{"notes.user_id = $1", "notes.deleted_at IS NULL", "NOT notes.archived"}
+	if opts.Archived {
+		conds[2] = "notes.archived"
+	}
+	if opts.CreatedAfter != nil {
+		conds = append(conds, "created_at >= "+arg(*opts.CreatedAfter))
+	}
+	if opts.CreatedBefore != nil {
+		conds = append(conds, "created_at < "+arg(*opts.CreatedBefore))
+	}
+	if opts.UpdatedAfter != nil {
+		conds = append(conds, "updated_at >= "+arg(*opts.UpdatedAfter))
+	}
+	if opts.UpdatedBefore != nil {
+		conds = append(conds, "updated_at < "+arg(*opts.UpdatedBefore))
+	}
+	if opts.NotebookID != nil {
+		conds = append(conds, "notebook_id = "+arg(*opts.NotebookID))
+	} else if opts.Unfiled {
+		conds = append(conds, "notebook_id IS NULL")
+	}
+	if opts.Tag != "" {
+		conds = append(conds, `EXISTS (SELECT 1 FROM note_tags nt JOIN tags t ON t.id = nt.tag_id
+			WHERE nt.note_id = notes.id AND t.user_id = $1 AND t.name = `+arg(opts.Tag)+`)`)
+	}
+
+	direction, comparison := "DESC", "<"
+	if opts.Ascending {
+		direction, comparison = "ASC", ">"
+	}
+	if opts.Cursor != "" {
+		pinned, value, id, err := decodeNoteCursor(opts.Cursor, opts.Sort, opts.Ascending)
+		if err != nil {
+			return nil, err
+		}
+		p := arg(pinned)
+		conds = append(conds, fmt.Sprintf("(pinned < %s OR (pinned = %s AND (%s, id) %s (%s, %s)))",
+			p, p, column, comparison, arg(value), arg(id)))
+	}
+
+	query := fmt.Sprintf(
+		`SELECT %s
+		 FROM notes WHERE %s ORDER BY pinned DESC, %s %s, id %s LIMIT %s`,
+		noteColumns, strings.Join(conds, " AND "), column, direction, direction, arg(limit+1),
 	)
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("listing notes: %w", err)
 	}
 	defer rows.Close()
 
-	var notes []*models.Note
+	notes := []*models.Note{}
 	for rows.Next() {
 		note := &models.Note{}
-		if err := rows.Scan(&note.ID, &note.UserID, &note.Title, &note.Body, &note.CreatedAt, &note.UpdatedAt); err != nil {
+		if err := scanNote(rows, note); err != nil {
 			return nil, fmt.Errorf("scanning note: %w", err)
 		}
 		notes = append(notes, note)
@@ -60,16 +222,117 @@ func (s *NoteService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*mode
 		return nil, fmt.Errorf("iterating notes: %w", err)
 	}
 
-	return notes, nil
+	page := &models.NotePage{Notes: notes}
+	if len(notes) > limit {
+		page.Notes = notes[:limit]
+		page.NextCursor = encodeNoteCursor(page.Notes[limit-1], opts.Sort, opts.Ascending)
+	}
+	return page, nil
+}
+
+// Search returns the user's notes matching query, best match first. The query
+// syntax is a list of words that must all match; "quoted words" match as a
+// phrase and a trailing * matches by prefix.
+func (s *NoteService) Search(ctx context.Context, userID uuid.UUID, query string, limit int) ([]*models.NoteSearchResult, error) {
+	tsquery := buildNoteTSQuery(query)
+	if tsquery == "" {
+		return nil, ErrEmptySearch
+	}
+	if limit <= 0 {
+		limit = DefaultNoteSearchLimit
+	}
+	if limit > MaxNoteListLimit {
+		limit = MaxNoteListLimit
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT `+noteColumns+`,
+		        ts_rank(search_vector, query) AS rank,
+		        ts_headline('english', translate(body, E'\x02\x03', ''), query, $3) AS snippet
+		 FROM notes, to_tsquery('english', $2) AS query
+		 WHERE notes.user_id = $1 AND notes.deleted_at IS NULL AND search_vector @@ query
+		 ORDER BY rank DESC, updated_at DESC, id
+		 LIMIT $4`,
+		userID, tsquery, headlineOptions, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("searching notes: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.NoteSearchResult{}
+	for rows.Next() {
+		result := &models.NoteSearchResult{Note: &models.Note{}}
+		if err := scanNote(rows, result.Note, &result.Rank, &result.Snippet); err != nil {
+			return nil, fmt.Errorf("scanning search result: %w", err)
+		}
+		result.Snippet = headlineReplacer.Replace(html.EscapeString(result.Snippet))
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating search results: %w", err)
+	}
+
+	return results, nil
 }
 
+// buildNoteTSQuery turns user input into to_tsquery syntax. Only letters and
+// digits survive, so tsquery operators typed by the user cannot change the
+// query's shape. Words are ANDed; a quoted group or a hyphenated word becomes
+// a <-> phrase, and a trailing * adds the :* prefix marker.
+func buildNoteTSQuery(q string) string {
+	var clauses []string
+	for {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		var chunk string
+		prefix := false
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				chunk, q = q[1:], ""
+			} else {
+				chunk, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			chunk, q = q[:end], q[end:]
+			prefix = strings.HasSuffix(chunk, "*")
+		}
+
+		words := strings.FieldsFunc(strings.ToLower(chunk), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		clause := strings.Join(words, " <-> ")
+		if prefix {
+			clause += ":*"
+		}
+		if len(words) > 1 {
+			clause = "(" + clause + ")"
+		}
+		clauses = append(clauses, clause)
+	}
+	return strings.Join(clauses, " & ")
+}
+
+// GetByID returns a note the user owns or has been shared.
 func (s *NoteService) GetByID(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
 	note := &models.Note{}
-	err := s.db.QueryRow(ctx,
-		`SELECT id, user_id, title, body, created_at, updated_at
-		 FROM notes WHERE id = $1 AND user_id = $2`,
+	err := scanNote(s.db.QueryRow(ctx,
+		`SELECT `+noteColumns+`
+		 FROM notes WHERE id = $1 AND deleted_at IS NULL AND `+noteReadableBy,
 		noteID, userID,
-	).Scan(&note.ID, &note.UserID, &note.Title, &note.Body, &note.CreatedAt, &note.UpdatedAt)
+	), note)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNoteNotFound
 	}
@@ -81,29 +344,310 @@ func (s *NoteService) GetByID(ctx context.Context, userID, noteID uuid.UUID) (*m
 }
 
 func (s *NoteService) Update(ctx context.Context, userID, noteID uuid.UUID, params models.UpdateNoteParams) (*models.Note, error) {
+	return s.Patch(ctx, userID, noteID, models.NotePatch{
+		Title:       &params.Title,
+		Body:        &params.Body,
+		Tags:        params.Tags,
+		SetNotebook: params.SetNotebook,
+		NotebookID:  params.NotebookID,
+		Version:     params.Version,
+	})
+}
+
+// Patch writes only the fields patch sets. A patch that only changes tags
+// still touches the note so its version and updated_at move; pinning and
+// archiving move only the version. Editors of a shared note may change its
+// title and body; tags, notebook, pinned and archived belong to the owner.
+func (s *NoteService) Patch(ctx context.Context, userID, noteID uuid.UUID, patch models.NotePatch) (*models.Note, error) {
+	args := []any{noteID, userID, patch.Version}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var sets []string
+	if patch.Title != nil {
+		sets = append(sets, "title = "+arg(*patch.Title))
+	}
+	if patch.Body != nil {
+		sets = append(sets, "body = "+arg(*patch.Body))
+	}
+	if patch.SetNotebook {
+		sets = append(sets, "notebook_id = "+arg(patch.NotebookID))
+	}
+	if patch.Pinned != nil {
+		sets = append(sets, "pinned = "+arg(*patch.Pinned))
+	}
+	if patch.Archived != nil {
+		sets = append(sets, "archived = "+arg(*patch.Archived))
+	}
+	// The updated_at trigger skips updates that change pinned or archived,
+	// so an edit alongside a flag sets it here
+	if patch.Title != nil || patch.Body != nil || patch.Tags != nil || patch.SetNotebook || len(sets) == 0 {
+		sets = append(sets, "updated_at = NOW()")
+	}
+	ownerOnly := patch.Tags != nil || patch.SetNotebook || patch.Pinned != nil || patch.Archived != nil
+	access := noteWritableBy
+	if ownerOnly {
+		access = "notes.user_id = $2"
+	}
+
 	note := &models.Note{}
-	err := s.db.QueryRow(ctx,
-		`UPDATE notes SET title = $1, body = $2 WHERE id = $3 AND user_id = $4
-		 RETURNING id, user_id, title, body, created_at, updated_at`,
-		params.Title, params.Body, noteID, userID,
-	).Scan(&note.ID, &note.UserID, &note.Title, &note.Body, &note.CreatedAt, &note.UpdatedAt)
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if patch.SetNotebook {
+			if err := checkNotebookOwner(ctx, tx, userID, patch.NotebookID); err != nil {
+				return err
+			}
+		}
+		err := scanNote(tx.QueryRow(ctx,
+			`UPDATE notes SET `+strings.Join(sets, ", ")+`
+			 WHERE id = $1 AND deleted_at IS NULL AND ($3 = 0 OR version = $3) AND `+access+`
+			 RETURNING `+noteColumns,
+			args...,
+		), note)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return noteWriteConflict(ctx, tx, userID, noteID, patch.Version, ownerOnly)
+		}
+		if err != nil {
+			return err
+		}
+		if patch.Title != nil || patch.Body != nil {
+			if err := recordRevision(ctx, tx, note); err != nil {
+				return err
+			}
+		}
+		if patch.Tags == nil {
+			return nil
+		}
+		note.Tags = patch.Tags
+		return setNoteTags(ctx, tx, userID, noteID, patch.Tags)
+	})
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, ErrNoteNotFound
 	}
+	if err == ErrNotebookNotFound || err == ErrNoteNotFound || err == ErrNoteForbidden || err == ErrNoteVersionMismatch {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("updating note: %w", err)
 	}
 
+	s.publish(ctx, note.UserID, models.NoteEventUpdated, note.ID, note.Version)
 	return note, nil
 }
 
-func (s *NoteService) Delete(ctx context.Context, userID, noteID uuid.UUID) error {
-	result, err := s.db.Exec(ctx, `DELETE FROM notes WHERE id = $1 AND user_id = $2`, noteID, userID)
+// Delete moves a note to the trash. It stays restorable until EmptyTrash or
+// the purge task removes it. Editors of a shared note can trash it too, into
+// the owner's trash. A non-zero version makes the delete conditional like
+// UpdateNoteParams.Version.
+func (s *NoteService) Delete(ctx context.Context, userID, noteID uuid.UUID, version int) error {
+	var ownerID uuid.UUID
+	err := s.db.QueryRow(ctx,
+		`UPDATE notes SET deleted_at = NOW()
+		 WHERE id = $1 AND deleted_at IS NULL AND ($3 = 0 OR version = $3) AND `+noteWritableBy+`
+		 RETURNING user_id`,
+		noteID, userID, version,
+	).Scan(&ownerID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return noteWriteConflict(ctx, s.db, userID, noteID, version, false)
+	}
 	if err != nil {
 		return fmt.Errorf("deleting note: %w", err)
 	}
-	if result.RowsAffected() == 0 {
+
+	s.publish(ctx, ownerID, models.NoteEventDeleted, noteID, 0)
+	return nil
+}
+
+// noteWriteConflict explains why a write matched no row: ErrNoteNotFound if
+// the user cannot see the note, ErrNoteForbidden if their share does not
+// allow the write (ownerOnly writes need the owner), and otherwise
+// ErrNoteVersionMismatch for a conditional write.
+func noteWriteConflict(ctx context.Context, db DBConn, userID, noteID uuid.UUID, version int, ownerOnly bool) error {
+	var owned bool
+	var permission string
+	if err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL),
+		        COALESCE((SELECT s.permission FROM note_shares s JOIN notes ON notes.id = s.note_id
+		                  WHERE s.note_id = $1 AND s.user_id = $2 AND notes.deleted_at IS NULL), '')`,
+		noteID, userID,
+	).Scan(&owned, &permission); err != nil {
+		return fmt.Errorf("checking note: %w", err)
+	}
+	switch {
+	case !owned && permission == "":
+		return ErrNoteNotFound
+	case !owned && (ownerOnly || permission != models.SharePermissionEditor):
+		return ErrNoteForbidden
+	case version != 0:
+		return ErrNoteVersionMismatch
+	default:
+		// The note went away between the write and this check.
 		return ErrNoteNotFound
 	}
+}
+
+// ListTrash returns the user's trashed notes, most recently deleted first.
+func (s *NoteService) ListTrash(ctx context.Context, userID uuid.UUID) ([]*models.Note, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+noteColumns+`, notes.deleted_at
+		 FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL
+		 ORDER BY deleted_at DESC, id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing trash: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []*models.Note{}
+	for rows.Next() {
+		note := &models.Note{}
+		if err := scanNote(rows, note, &note.DeletedAt); err != nil {
+			return nil, fmt.Errorf("scanning note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating trash: %w", err)
+	}
+
+	return notes, nil
+}
+
+// Restore takes a note out of the trash. Notes whose notebook was deleted
+// in the meantime come back at the root.
+func (s *NoteService) Restore(ctx context.Context, userID, noteID uuid.UUID) (*models.Note, error) {
+	note := &models.Note{}
+	err := scanNote(s.db.QueryRow(ctx,
+		`UPDATE notes SET deleted_at = NULL
+		 WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL
+		 RETURNING `+noteColumns,
+		noteID, userID,
+	), note)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotInTrash
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restoring note: %w", err)
+	}
+
+	// Back in the owner's lists, as if created
+	s.publish(ctx, note.UserID, models.NoteEventCreated, note.ID, note.Version)
+	return note, nil
+}
+
+// EmptyTrash permanently deletes the user's trashed notes and returns how
+// many were removed.
+func (s *NoteService) EmptyTrash(ctx context.Context, userID uuid.UUID) (int64, error) {
+	rows, err := s.db.Query(ctx,
+		`DELETE FROM notes WHERE user_id = $1 AND deleted_at IS NOT NULL
+		 RETURNING id, user_id, version`,
+		userID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("emptying trash: %w", err)
+	}
+	changes, err := scanNoteChanges(rows, models.NoteEventDeleted)
+	if err != nil {
+		return 0, fmt.Errorf("emptying trash: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changes...)
+	return int64(len(changes)), nil
+}
+
+// TrashPurgeTask permanently deletes notes that have been in the trash
+// longer than retention. The row locks make Postgres recheck deleted_at, so
+// a note restored while the purge waits for it is kept.
+func TrashPurgeTask(retention time.Duration) CleanupTask {
+	return CleanupTask{
+		Name: "trashed_notes",
+		Query: `DELETE FROM notes WHERE id IN (
+			SELECT id FROM notes WHERE deleted_at < NOW() - $2::interval
+			LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+		Args: []any{fmt.Sprintf("%d seconds", int64(retention.Seconds()))},
+	}
+}
+
+// setNoteTags makes tags the exact tag set of a note, creating any tags the
+// user does not have yet. tags must already be normalized.
+func setNoteTags(ctx context.Context, tx Tx, userID, noteID uuid.UUID, tags []string) error {
+	if tags == nil {
+		tags = []string{}
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO tags (user_id, name) SELECT $1::uuid, unnest($2::text[])
+		 ON CONFLICT (user_id, name) DO NOTHING`,
+		userID, tags,
+	); err != nil {
+		return fmt.Errorf("creating tags: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM note_tags WHERE note_id = $1
+		 AND tag_id NOT IN (SELECT id FROM tags WHERE user_id = $2 AND name = ANY($3))`,
+		noteID, userID, tags,
+	); err != nil {
+		return fmt.Errorf("detaching tags: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO note_tags (note_id, tag_id)
+		 SELECT $1::uuid, id FROM tags WHERE user_id = $2 AND name = ANY($3)
+		 ON CONFLICT DO NOTHING`,
+		noteID, userID, tags,
+	); err != nil {
+		return fmt.Errorf("attaching tags: %w", err)
+	}
 	return nil
 }
+
+// noteCursor is the decoded form of the opaque next_cursor. It records the
+// sort it was issued for so a cursor cannot be replayed against a different
+// ordering and silently skip rows.
+type noteCursor struct {
+	Sort      string    `json:"s"`
+	Ascending bool      `json:"a,omitempty"`
+	Pinned    *bool     `json:"p"`
+	Value     string    `json:"v"`
+	ID        uuid.UUID `json:"id"`
+}
+
+func encodeNoteCursor(note *models.Note, sort string, ascending bool) string {
+	c := noteCursor{Sort: sort, Ascending: ascending, Pinned: &note.Pinned, ID: note.ID}
+	switch sort {
+	case models.NoteSortCreated:
+		c.Value = note.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case models.NoteSortTitle:
+		c.Value = note.Title
+	default:
+		c.Value = note.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	// Marshalling a struct of strings, bools and a UUID cannot fail.
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeNoteCursor returns the keyset position encoded in cursor, with the
+// value typed for the sort column, or ErrInvalidCursor. Cursors from before
+// notes could be pinned carry no pinned flag and are rejected.
+func decodeNoteCursor(cursor, sort string, ascending bool) (bool, any, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return false, nil, uuid.Nil, ErrInvalidCursor
+	}
+	var c noteCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return false, nil, uuid.Nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Ascending != ascending || c.Pinned == nil || c.ID == uuid.Nil {
+		return false, nil, uuid.Nil, ErrInvalidCursor
+	}
+	if sort == models.NoteSortTitle {
+		return *c.Pinned, c.Value, c.ID, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, c.Value)
+	if err != nil {
+		return false, nil, uuid.Nil, ErrInvalidCursor
+	}
+	return *c.Pinned, t, c.ID, nil
+}
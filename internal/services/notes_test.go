@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,6 +49,18 @@ func (m *mockRows) Scan(dest ...any) error {
 			*d = row[i].(string)
 		case *time.Time:
 			*d = row[i].(time.Time)
+		case *int:
+			*d = row[i].(int)
+		case *bool:
+			*d = row[i].(bool)
+		case *float32:
+			*d = row[i].(float32)
+		case *[]string:
+			*d = row[i].([]string)
+		case **uuid.UUID:
+			*d, _ = row[i].(*uuid.UUID)
+		case **time.Time:
+			*d, _ = row[i].(*time.Time)
 		default:
 			return errors.New("unsupported scan type")
 		}
@@ -79,10 +92,32 @@ func (m *mockDB) QueryRow(ctx context.Context, sql string, args ...any) Row {
 	return m.queryRow(ctx, sql, args...)
 }
 
+// Begin returns a transaction that runs statements through the same funcs.
+func (m *mockDB) Begin(ctx context.Context) (Tx, error) {
+	return &mockTx{mockDB: m}, nil
+}
+
+type mockTx struct {
+	*mockDB
+	committed  bool
+	rolledBack bool
+}
+
+func (m *mockTx) Commit(ctx context.Context) error {
+	m.committed = true
+	return nil
+}
+
+func (m *mockTx) Rollback(ctx context.Context) error {
+	m.rolledBack = true
+	return nil
+}
+
 func TestNoteService_Create(t *testing.T) {
 	userID := uuid.New()
 	noteID := uuid.New()
 	now := time.Now()
+	var execs []string
 	db := &mockDB{
 		queryRow: func(ctx context.Context, sql string, args ...any) Row {
 			return mockRow{scan: func(dest ...any) error {
@@ -92,12 +127,17 @@ func TestNoteService_Create(t *testing.T) {
 				*dest[3].(*string) = "Body"
 				*dest[4].(*time.Time) = now
 				*dest[5].(*time.Time) = now
+				*dest[6].(*[]string) = []string{}
 				return nil
 			}}
 		},
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			execs = append(execs, sql)
+			return mockCommandTag{affected: 1}, nil
+		},
 	}
 
-	svc := NewNoteService(db)
+	svc := NewNoteService(db, nil)
 	note, err := svc.Create(context.Background(), models.CreateNoteParams{
 		UserID: userID,
 		Title:  "Title",
@@ -109,6 +149,81 @@ func TestNoteService_Create(t *testing.T) {
 	if note.ID != noteID {
 		t.Fatalf("expected note id %s, got %s", noteID, note.ID)
 	}
+	if len(execs) != 1 || !strings.Contains(execs[0], "INSERT INTO note_revisions") {
+		t.Fatalf("expected the first revision to be recorded, got %v", execs)
+	}
+}
+
+func TestNoteService_Create_WithTags(t *testing.T) {
+	userID := uuid.New()
+	noteID := uuid.New()
+	now := time.Now()
+	var execs []string
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				*dest[0].(*uuid.UUID) = noteID
+				*dest[1].(*uuid.UUID) = userID
+				*dest[4].(*time.Time) = now
+				*dest[5].(*time.Time) = now
+				*dest[6].(*[]string) = []string{}
+				return nil
+			}}
+		},
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			execs = append(execs, sql)
+			if strings.Contains(sql, "note_revisions") {
+				return mockCommandTag{affected: 1}, nil
+			}
+			if tags := args[len(args)-1].([]string); len(tags) != 2 {
+				t.Fatalf("expected 2 tags, got %v", tags)
+			}
+			return mockCommandTag{affected: 1}, nil
+		},
+	}
+
+	svc := NewNoteService(db, nil)
+	note, err := svc.Create(context.Background(), models.CreateNoteParams{
+		UserID: userID,
+		Title:  "Title",
+		Body:   "Body",
+		Tags:   []string{"home", "work"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(execs) != 4 || !strings.Contains(execs[1], "INSERT INTO tags") || !strings.Contains(execs[3], "INSERT INTO note_tags") {
+		t.Fatalf("unexpected statements: %v", execs)
+	}
+	if len(note.Tags) != 2 {
+		t.Fatalf("expected note to carry its tags, got %v", note.Tags)
+	}
+}
+
+func TestNoteService_Update_NilTagsKeepsTags(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error {
+				*dest[6].(*[]string) = []string{"kept"}
+				return nil
+			}}
+		},
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if !strings.Contains(sql, "INSERT INTO note_revisions") {
+				t.Fatalf("unexpected statement: %s", sql)
+			}
+			return mockCommandTag{affected: 1}, nil
+		},
+	}
+
+	svc := NewNoteService(db, nil)
+	note, err := svc.Update(context.Background(), uuid.New(), uuid.New(), models.UpdateNoteParams{Title: "T", Body: "B"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(note.Tags) != 1 || note.Tags[0] != "kept" {
+		t.Fatalf("expected existing tags, got %v", note.Tags)
+	}
 }
 
 func TestNoteService_ListByUser(t *testing.T) {
@@ -117,17 +232,197 @@ func TestNoteService_ListByUser(t *testing.T) {
 	now := time.Now()
 	db := &mockDB{
 		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
-			return &mockRows{rows: [][]any{{noteID, userID, "Title", "Body", now, now}}}, nil
+			return &mockRows{rows: [][]any{{noteID, userID, "Title", "Body", now, now, []string{}, nil, 1, false, false}}}, nil
+		},
+	}
+
+	svc := NewNoteService(db, nil)
+	page, err := svc.ListByUser(context.Background(), userID, models.NoteListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Notes) != 1 {
+		t.Fatalf("expected 1 note, got %d", len(page.Notes))
+	}
+	if page.NextCursor != "" {
+		t.Fatalf("expected no next cursor, got %q", page.NextCursor)
+	}
+}
+
+func TestNoteService_ListByUser_Paginates(t *testing.T) {
+	userID := uuid.New()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows [][]any
+	for i := 0; i < 3; i++ {
+		ts := base.Add(-time.Duration(i) * time.Hour)
+		rows = append(rows, []any{uuid.New(), userID, "Title", "Body", ts, ts, []string{}, nil, 1, false, false})
+	}
+
+	var gotSQL string
+	var gotArgs []any
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			gotSQL, gotArgs = sql, args
+			return &mockRows{rows: rows}, nil
+		},
+	}
+
+	svc := NewNoteService(db, nil)
+	page, err := svc.ListByUser(context.Background(), userID, models.NoteListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Notes) != 2 {
+		t.Fatalf("expected 2 notes, got %d", len(page.Notes))
+	}
+	if page.NextCursor == "" {
+		t.Fatal("expected a next cursor")
+	}
+	if !strings.Contains(gotSQL, "ORDER BY pinned DESC, updated_at DESC, id DESC") || gotArgs[len(gotArgs)-1] != 3 {
+		t.Fatalf("unexpected query %q with args %v", gotSQL, gotArgs)
+	}
+
+	_, err = svc.ListByUser(context.Background(), userID, models.NoteListOptions{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(gotSQL, "(pinned < $2 OR (pinned = $2 AND (updated_at, id) < ($3, $4)))") {
+		t.Fatalf("expected keyset condition, got %q", gotSQL)
+	}
+	if gotArgs[1] != false || gotArgs[2] != base.Add(-time.Hour) || gotArgs[3] != page.Notes[1].ID {
+		t.Fatalf("expected cursor from last note, got %v", gotArgs)
+	}
+}
+
+func TestNoteService_ListByUser_Tag(t *testing.T) {
+	var gotSQL string
+	var gotArgs []any
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			gotSQL, gotArgs = sql, args
+			return &mockRows{}, nil
+		},
+	}
+
+	svc := NewNoteService(db, nil)
+	if _, err := svc.ListByUser(context.Background(), uuid.New(), models.NoteListOptions{Tag: "work"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(gotSQL, "t.name = $2") || gotArgs[1] != "work" {
+		t.Fatalf("expected tag filter, got %q with %v", gotSQL, gotArgs)
+	}
+}
+
+func TestNoteService_ListByUser_Archived(t *testing.T) {
+	var gotSQL string
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			gotSQL = sql
+			return &mockRows{}, nil
+		},
+	}
+	svc := NewNoteService(db, nil)
+
+	if _, err := svc.ListByUser(context.Background(), uuid.New(), models.NoteListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(gotSQL, "AND NOT notes.archived") {
+		t.Fatalf("expected archived notes to be left out, got %q", gotSQL)
+	}
+
+	if _, err := svc.ListByUser(context.Background(), uuid.New(), models.NoteListOptions{Archived: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(gotSQL, "NOT notes.archived") || !strings.Contains(gotSQL, "AND notes.archived") {
+		t.Fatalf("expected only archived notes, got %q", gotSQL)
+	}
+}
+
+func TestNoteCursor_Pinned(t *testing.T) {
+	note := &models.Note{ID: uuid.New(), Title: "Title", Pinned: true}
+	pinned, value, id, err := decodeNoteCursor(encodeNoteCursor(note, models.NoteSortTitle, true), models.NoteSortTitle, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !pinned || value != "Title" || id != note.ID {
+		t.Fatalf("unexpected cursor %v %v %v", pinned, value, id)
+	}
+}
+
+func TestNoteService_ListByUser_InvalidCursor(t *testing.T) {
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			t.Fatal("query should not run")
+			return nil, nil
+		},
+	}
+	svc := NewNoteService(db, nil)
+
+	note := &models.Note{ID: uuid.New(), Title: "Title"}
+	titleCursor := encodeNoteCursor(note, models.NoteSortTitle, true)
+
+	for _, opts := range []models.NoteListOptions{
+		{Cursor: "not-a-cursor"},
+		{Cursor: titleCursor},
+		{Sort: models.NoteSortTitle, Cursor: titleCursor},
+	} {
+		if _, err := svc.ListByUser(context.Background(), uuid.New(), opts); !errors.Is(err, ErrInvalidCursor) {
+			t.Fatalf("expected ErrInvalidCursor for %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestBuildNoteTSQuery(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello world", "hello & world"},
+		{`"exact phrase" here`, "(exact <-> phrase) & here"},
+		{"proj*", "proj:*"},
+		{"e-mail*", "(e <-> mail:*)"},
+		{`x & !y | z:*`, "x & y & z:*"},
+		{`"unterminated phrase`, "(unterminated <-> phrase)"},
+		{"Ünïcode", "ünïcode"},
+		{`" " * !`, ""},
+	}
+	for _, tt := range tests {
+		if got := buildNoteTSQuery(tt.in); got != tt.want {
+			t.Errorf("buildNoteTSQuery(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNoteService_Search(t *testing.T) {
+	userID := uuid.New()
+	noteID := uuid.New()
+	now := time.Now()
+	var gotArgs []any
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			gotArgs = args
+			snippet := "a <b> \x02match\x03 & more"
+			return &mockRows{rows: [][]any{{noteID, userID, "Title", "Body", now, now, []string{"work"}, nil, 1, false, false, float32(0.7), snippet}}}, nil
 		},
 	}
 
-	svc := NewNoteService(db)
-	notes, err := svc.ListByUser(context.Background(), userID)
+	svc := NewNoteService(db, nil)
+	results, err := svc.Search(context.Background(), userID, `"match more"`, 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if len(notes) != 1 {
-		t.Fatalf("expected 1 note, got %d", len(notes))
+	if gotArgs[0] != userID || gotArgs[1] != "(match <-> more)" || gotArgs[3] != DefaultNoteSearchLimit {
+		t.Fatalf("unexpected args: %v", gotArgs)
+	}
+	if len(results) != 1 || results[0].ID != noteID || results[0].Rank != 0.7 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if want := "a &lt;b&gt; <mark>match</mark> &amp; more"; results[0].Snippet != want {
+		t.Fatalf("expected snippet %q, got %q", want, results[0].Snippet)
+	}
+
+	if _, err := svc.Search(context.Background(), userID, "!!", 0); !errors.Is(err, ErrEmptySearch) {
+		t.Fatalf("expected ErrEmptySearch, got %v", err)
 	}
 }
 
@@ -140,7 +435,7 @@ func TestNoteService_Update_NotFound(t *testing.T) {
 		},
 	}
 
-	svc := NewNoteService(db)
+	svc := NewNoteService(db, nil)
 	_, err := svc.Update(context.Background(), uuid.New(), uuid.New(), models.UpdateNoteParams{Title: "T", Body: "B"})
 	if !errors.Is(err, ErrNoteNotFound) {
 		t.Fatalf("expected ErrNoteNotFound, got %v", err)
@@ -149,14 +444,233 @@ func TestNoteService_Update_NotFound(t *testing.T) {
 
 func TestNoteService_Delete_NotFound(t *testing.T) {
 	db := &mockDB{
-		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
-			return mockCommandTag{affected: 0}, nil
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.HasPrefix(sql, "UPDATE notes") {
+				return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+			}
+			// Neither owned nor shared
+			return mockRow{scan: func(dest ...any) error { return nil }}
 		},
 	}
 
-	svc := NewNoteService(db)
-	err := svc.Delete(context.Background(), uuid.New(), uuid.New())
+	svc := NewNoteService(db, nil)
+	err := svc.Delete(context.Background(), uuid.New(), uuid.New(), 0)
 	if !errors.Is(err, ErrNoteNotFound) {
 		t.Fatalf("expected ErrNoteNotFound, got %v", err)
 	}
 }
+
+func TestNoteService_Delete_MovesToTrash(t *testing.T) {
+	ownerID := uuid.New()
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if !strings.Contains(sql, "SET deleted_at = NOW()") {
+				t.Fatalf("expected a soft delete, got %s", sql)
+			}
+			return mockRow{scan: func(dest ...any) error {
+				*dest[0].(*uuid.UUID) = ownerID
+				return nil
+			}}
+		},
+	}
+	events := &mockNoteEventPublisher{}
+
+	noteID := uuid.New()
+	if err := NewNoteService(db, events).Delete(context.Background(), uuid.New(), noteID, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events.published) != 1 || events.published[0].userID != ownerID ||
+		events.published[0].event.Type != models.NoteEventDeleted || events.published[0].event.NoteID != noteID {
+		t.Fatalf("expected a deleted event for the owner, got %+v", events.published)
+	}
+}
+
+func TestNoteService_EmptyTrash(t *testing.T) {
+	userID := uuid.New()
+	noteIDs := []uuid.UUID{uuid.New(), uuid.New()}
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			if !strings.Contains(sql, "DELETE FROM notes") || !strings.Contains(sql, "deleted_at IS NOT NULL") {
+				t.Fatalf("expected only trashed notes deleted, got %s", sql)
+			}
+			return &mockRows{rows: [][]any{{noteIDs[0], userID, 3}, {noteIDs[1], userID, 1}}}, nil
+		},
+	}
+	events := &mockNoteEventPublisher{}
+
+	deleted, err := NewNoteService(db, events).EmptyTrash(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleted != 2 {
+		t.Fatalf("expected 2 notes deleted, got %d", deleted)
+	}
+	if len(events.published) != 2 || events.published[1].userID != userID ||
+		events.published[1].event != (models.NoteEvent{Type: models.NoteEventDeleted, NoteID: noteIDs[1]}) {
+		t.Fatalf("expected a deleted event per note, got %+v", events.published)
+	}
+}
+
+func TestNoteService_ListTrash(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			if !strings.Contains(sql, "deleted_at IS NOT NULL") {
+				t.Fatalf("expected only trashed notes, got %s", sql)
+			}
+			return &mockRows{rows: [][]any{{uuid.New(), userID, "Title", "Body", now, now, []string{}, nil, 1, false, false, &now}}}, nil
+		},
+	}
+
+	notes, err := NewNoteService(db, nil).ListTrash(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) != 1 || notes[0].DeletedAt == nil || !notes[0].DeletedAt.Equal(now) {
+		t.Fatalf("expected one trashed note with deleted_at, got %+v", notes)
+	}
+}
+
+func TestNoteService_Restore_NotInTrash(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+
+	_, err := NewNoteService(db, nil).Restore(context.Background(), uuid.New(), uuid.New())
+	if !errors.Is(err, ErrNoteNotInTrash) {
+		t.Fatalf("expected ErrNoteNotInTrash, got %v", err)
+	}
+}
+
+func TestNoteService_Update_VersionMismatch(t *testing.T) {
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "SELECT EXISTS") {
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*bool) = true
+					return nil
+				}}
+			}
+			if args[2] != 3 {
+				t.Fatalf("expected the update to be conditional on version 3, got %v", args[2])
+			}
+			return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+
+	_, err := NewNoteService(db, nil).Update(context.Background(), uuid.New(), uuid.New(), models.UpdateNoteParams{Title: "T", Body: "B", Version: 3})
+	if !errors.Is(err, ErrNoteVersionMismatch) {
+		t.Fatalf("expected ErrNoteVersionMismatch, got %v", err)
+	}
+}
+
+func TestNoteService_Delete_Conditional(t *testing.T) {
+	for _, exists := range []bool{true, false} {
+		db := &mockDB{
+			queryRow: func(ctx context.Context, sql string, args ...any) Row {
+				if strings.HasPrefix(sql, "UPDATE notes") {
+					return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+				}
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*bool) = exists
+					return nil
+				}}
+			},
+		}
+
+		want := ErrNoteNotFound
+		if exists {
+			want = ErrNoteVersionMismatch
+		}
+		if err := NewNoteService(db, nil).Delete(context.Background(), uuid.New(), uuid.New(), 2); !errors.Is(err, want) {
+			t.Fatalf("exists=%v: expected %v, got %v", exists, want, err)
+		}
+	}
+}
+
+func TestNoteService_Patch_WritesOnlyChangedColumns(t *testing.T) {
+	body := "New body"
+	pinned := true
+	tests := []struct {
+		name     string
+		patch    models.NotePatch
+		set      string
+		touched  bool
+		revision bool
+	}{
+		{"body", models.NotePatch{Body: &body}, "SET body = $4", true, true},
+		{"tags only", models.NotePatch{Tags: []string{"work"}}, "SET updated_at = NOW()", true, false},
+		{"pin only", models.NotePatch{Pinned: &pinned}, "SET pinned = $4", false, false},
+		// The trigger skips flag changes, so the edit must set updated_at
+		{"body and pin", models.NotePatch{Body: &body, Pinned: &pinned}, "SET body = $4, pinned = $5, updated_at = NOW()", true, true},
+		{"tags and pin", models.NotePatch{Tags: []string{"work"}, Pinned: &pinned}, "SET pinned = $4, updated_at = NOW()", true, false},
+	}
+	for _, tt := range tests {
+		var execs []string
+		db := &mockDB{
+			queryRow: func(ctx context.Context, sql string, args ...any) Row {
+				if !strings.Contains(sql, tt.set) || strings.Contains(sql, "title =") ||
+					strings.Contains(sql, "updated_at = NOW()") != tt.touched {
+					t.Fatalf("%s: unexpected update %s", tt.name, sql)
+				}
+				return mockRow{scan: func(dest ...any) error { return nil }}
+			},
+			exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+				execs = append(execs, sql)
+				return mockCommandTag{affected: 1}, nil
+			},
+		}
+
+		if _, err := NewNoteService(db, nil).Patch(context.Background(), uuid.New(), uuid.New(), tt.patch); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		recorded := len(execs) > 0 && strings.Contains(execs[0], "note_revisions")
+		if recorded != tt.revision {
+			t.Fatalf("%s: revision recorded = %v, want %v", tt.name, recorded, tt.revision)
+		}
+	}
+}
+
+func TestNoteService_Patch_SharedAccess(t *testing.T) {
+	title := "New title"
+	pinned := true
+	tests := []struct {
+		name       string
+		patch      models.NotePatch
+		permission string
+		want       error
+	}{
+		{"viewer", models.NotePatch{Title: &title}, models.SharePermissionViewer, ErrNoteForbidden},
+		{"editor retagging", models.NotePatch{Tags: []string{"work"}}, models.SharePermissionEditor, ErrNoteForbidden},
+		{"editor pinning", models.NotePatch{Pinned: &pinned}, models.SharePermissionEditor, ErrNoteForbidden},
+		{"stranger", models.NotePatch{Title: &title}, "", ErrNoteNotFound},
+	}
+	for _, tt := range tests {
+		db := &mockDB{
+			queryRow: func(ctx context.Context, sql string, args ...any) Row {
+				if strings.HasPrefix(sql, "UPDATE") {
+					wantAccess := "note_shares"
+					if tt.patch.Tags != nil || tt.patch.Pinned != nil {
+						wantAccess = "notes.user_id = $2\n"
+					}
+					if !strings.Contains(sql, wantAccess) {
+						t.Fatalf("%s: expected access check %q in %s", tt.name, wantAccess, sql)
+					}
+					return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+				}
+				return mockRow{scan: func(dest ...any) error {
+					*dest[1].(*string) = tt.permission
+					return nil
+				}}
+			},
+		}
+
+		_, err := NewNoteService(db, nil).Patch(context.Background(), uuid.New(), uuid.New(), tt.patch)
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -10,9 +11,13 @@ import (
 // RedisClient narrows redis operations used by services.
 type RedisClient interface {
 	Set(ctx context.Context, key string, value any, expiration time.Duration) error
+	SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error)
 	Get(ctx context.Context, key string) (string, error)
 	Expire(ctx context.Context, key string, expiration time.Duration) error
 	Del(ctx context.Context, keys ...string) error
+	// Incr adds one to the counter at key and returns the new count. A new
+	// counter expires after expiration.
+	Incr(ctx context.Context, key string, expiration time.Duration) (int64, error)
 }
 
 // RedisAdapter wraps *redis.Client to satisfy RedisClient.
@@ -29,6 +34,10 @@ func (r *RedisAdapter) Set(ctx context.Context, key string, value any, expiratio
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
+func (r *RedisAdapter) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
 func (r *RedisAdapter) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
@@ -40,3 +49,108 @@ func (r *RedisAdapter) Expire(ctx context.Context, key string, expiration time.D
 func (r *RedisAdapter) Del(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
+
+// incrScript increments and sets the expiry of a new counter in one step, so
+// a crash in between cannot leave a counter that never expires.
+var incrScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (r *RedisAdapter) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return incrScript.Run(ctx, r.client, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+// StreamEntry is one value in a redis stream.
+type StreamEntry struct {
+	Stream string `json:"stream"`
+	ID     string `json:"id"`
+	Value  string `json:"value"`
+}
+
+// RedisEventLog keeps events in capped redis streams for replay and
+// broadcasts each one over pub/sub as it is added.
+type RedisEventLog interface {
+	// AppendEvent adds value to stream, trimmed to about maxLen entries and
+	// expiring ttl after the last append, and publishes the new entry on
+	// channel. It returns the entry's id.
+	AppendEvent(ctx context.Context, stream, channel, value string, maxLen int64, ttl time.Duration) (string, error)
+	// ReadEvents returns up to count entries of stream from id start on.
+	ReadEvents(ctx context.Context, stream, start string, count int64) ([]StreamEntry, error)
+	// LastEventID returns the id of the newest entry in stream, or "0-0".
+	LastEventID(ctx context.Context, stream string) (string, error)
+	// SubscribeEvents delivers the entries published on channel until ctx
+	// is done, reconnecting as needed. Entries published while reconnecting
+	// are missed.
+	SubscribeEvents(ctx context.Context, channel string) <-chan StreamEntry
+}
+
+// appendEventScript adds to the stream and publishes in one step, so
+// subscribers see entries in stream order.
+var appendEventScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[2], '*', 'value', ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+redis.call('PUBLISH', ARGV[4], cjson.encode({stream = KEYS[1], id = id, value = ARGV[1]}))
+return id
+`)
+
+func (r *RedisAdapter) AppendEvent(ctx context.Context, stream, channel, value string, maxLen int64, ttl time.Duration) (string, error) {
+	return appendEventScript.Run(ctx, r.client, []string{stream}, value, maxLen, ttl.Milliseconds(), channel).Text()
+}
+
+func (r *RedisAdapter) ReadEvents(ctx context.Context, stream, start string, count int64) ([]StreamEntry, error) {
+	messages, err := r.client.XRangeN(ctx, stream, start, "+", count).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]StreamEntry, len(messages))
+	for i, msg := range messages {
+		value, _ := msg.Values["value"].(string)
+		entries[i] = StreamEntry{Stream: stream, ID: msg.ID, Value: value}
+	}
+	return entries, nil
+}
+
+func (r *RedisAdapter) LastEventID(ctx context.Context, stream string) (string, error) {
+	messages, err := r.client.XRevRangeN(ctx, stream, "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 {
+		return "0-0", nil
+	}
+	return messages[0].ID, nil
+}
+
+func (r *RedisAdapter) SubscribeEvents(ctx context.Context, channel string) <-chan StreamEntry {
+	pubsub := r.client.Subscribe(ctx, channel)
+	entries := make(chan StreamEntry, 64)
+	go func() {
+		defer close(entries)
+		defer func() { _ = pubsub.Close() }()
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+				var entry StreamEntry
+				if err := json.Unmarshal([]byte(msg.Payload), &entry); err != nil {
+					continue
+				}
+				select {
+				case entries <- entry:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return entries
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/example/notes-template/internal/logging"
+)
+
+// Registration modes
+const (
+	RegistrationOpen    = "open"
+	RegistrationInvite  = "invite"
+	RegistrationDomains = "domains"
+)
+
+var (
+	ErrInviteRequired          = errors.New("an invite code is required to register")
+	ErrEmailDomainNotAllowed   = errors.New("registration is not open to this email domain")
+	ErrUnknownRegistrationMode = errors.New("unknown registration mode")
+)
+
+// RegistrationPolicy decides whether a new account may be created.
+type RegistrationPolicy struct {
+	mode    string
+	domains map[string]bool
+	invites InviteServiceInterface
+}
+
+// NewRegistrationPolicy builds a policy for mode. allowedDomains is only used
+// in domains mode and invites only in invite mode.
+func NewRegistrationPolicy(mode string, allowedDomains []string, invites InviteServiceInterface) *RegistrationPolicy {
+	domains := make(map[string]bool, len(allowedDomains))
+	for _, d := range allowedDomains {
+		d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "@")
+		if d != "" {
+			domains[d] = true
+		}
+	}
+	return &RegistrationPolicy{
+		mode:    mode,
+		domains: domains,
+		invites: invites,
+	}
+}
+
+// Mode returns the configured registration mode.
+func (p *RegistrationPolicy) Mode() string {
+	return p.mode
+}
+
+// Check reports whether email may register with inviteCode without consuming
+// the invite. Use it for early feedback; Admit is authoritative.
+func (p *RegistrationPolicy) Check(ctx context.Context, email, inviteCode string) error {
+	switch p.mode {
+	case RegistrationOpen:
+		return nil
+	case RegistrationDomains:
+		return p.checkDomain(email)
+	case RegistrationInvite:
+		if strings.TrimSpace(inviteCode) == "" {
+			return ErrInviteRequired
+		}
+		return p.invites.Validate(ctx, strings.TrimSpace(inviteCode))
+	default:
+		return ErrUnknownRegistrationMode
+	}
+}
+
+// Admit enforces the policy for a new account, claiming the invite in invite
+// mode. The returned Admission must be completed or canceled once the account
+// has been created or has failed to be created.
+func (p *RegistrationPolicy) Admit(ctx context.Context, email, inviteCode string) (*Admission, error) {
+	switch p.mode {
+	case RegistrationOpen:
+		return &Admission{}, nil
+	case RegistrationDomains:
+		if err := p.checkDomain(email); err != nil {
+			return nil, err
+		}
+		return &Admission{}, nil
+	case RegistrationInvite:
+		if strings.TrimSpace(inviteCode) == "" {
+			return nil, ErrInviteRequired
+		}
+		inviteID, err := p.invites.Claim(ctx, strings.TrimSpace(inviteCode))
+		if err != nil {
+			return nil, err
+		}
+		return &Admission{invites: p.invites, inviteID: inviteID}, nil
+	default:
+		return nil, ErrUnknownRegistrationMode
+	}
+}
+
+func (p *RegistrationPolicy) checkDomain(email string) error {
+	idx := strings.LastIndex(email, "@")
+	if idx == -1 || !p.domains[strings.ToLower(email[idx+1:])] {
+		return ErrEmailDomainNotAllowed
+	}
+	return nil
+}
+
+// Admission tracks an invite claimed for an account that is being created.
+type Admission struct {
+	invites  InviteServiceInterface
+	inviteID uuid.UUID
+}
+
+// Complete records the created account against the claimed invite.
+func (a *Admission) Complete(ctx context.Context, userID uuid.UUID) {
+	if a.invites == nil {
+		return
+	}
+	if err := a.invites.MarkUsedBy(ctx, a.inviteID, userID); err != nil {
+		logging.Error("Failed to record invite use", map[string]interface{}{"error": err.Error(), "invite_id": a.inviteID.String()})
+	}
+}
+
+// Cancel releases the claimed invite so it can be used again.
+func (a *Admission) Cancel(ctx context.Context) {
+	if a.invites == nil {
+		return
+	}
+	if err := a.invites.Release(ctx, a.inviteID); err != nil {
+		logging.Error("Failed to release invite", map[string]interface{}{"error": err.Error(), "invite_id": a.inviteID.String()})
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRegistrationPolicy_Domains(t *testing.T) {
+	policy := NewRegistrationPolicy(RegistrationDomains, []string{"Example.com", "@corp.example"}, nil)
+
+	tests := []struct {
+		email   string
+		allowed bool
+	}{
+		{"alice@example.com", true},
+		{"bob@EXAMPLE.COM", true},
+		{"carol@corp.example", true},
+		{"dave@sub.example.com", false},
+		{"eve@example.com.evil", false},
+		{"no-at-sign", false},
+	}
+
+	for _, tt := range tests {
+		err := policy.Check(context.Background(), tt.email, "")
+		if tt.allowed && err != nil {
+			t.Errorf("Check(%q) returned %v, want nil", tt.email, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrEmailDomainNotAllowed) {
+			t.Errorf("Check(%q) returned %v, want ErrEmailDomainNotAllowed", tt.email, err)
+		}
+	}
+}
+
+func TestRegistrationPolicy_InviteRequired(t *testing.T) {
+	policy := NewRegistrationPolicy(RegistrationInvite, nil, NewInviteService(&mockDB{}))
+
+	if _, err := policy.Admit(context.Background(), "a@example.com", "  "); !errors.Is(err, ErrInviteRequired) {
+		t.Fatalf("expected ErrInviteRequired, got %v", err)
+	}
+}
+
+func TestRegistrationPolicy_OpenAdmission(t *testing.T) {
+	policy := NewRegistrationPolicy(RegistrationOpen, nil, nil)
+
+	admission, err := policy.Admit(context.Background(), "a@example.com", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Completing or canceling an admission without an invite is a no-op
+	admission.Complete(context.Background(), [16]byte{})
+	admission.Cancel(context.Background())
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// maxDiffCells bounds the LCS table DiffLines allocates for the region left
+// after trimming common leading and trailing lines.
+const maxDiffCells = 4_000_000
+
+// recordRevision appends note's title and body to its history unless they
+// match the latest revision, so tag- or notebook-only edits add nothing.
+// It must run in the transaction that wrote the note; the note row lock
+// keeps revision numbers sequential.
+func recordRevision(ctx context.Context, tx Tx, note *models.Note) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO note_revisions (note_id, revision, title, body)
+		 SELECT $1::uuid, COALESCE(latest.revision, 0) + 1, $2::text, $3::text
+		 FROM (SELECT 1) AS one
+		 LEFT JOIN LATERAL (
+		     SELECT revision, title, body FROM note_revisions
+		     WHERE note_id = $1 ORDER BY revision DESC LIMIT 1
+		 ) AS latest ON true
+		 WHERE latest.revision IS NULL OR latest.title <> $2 OR latest.body <> $3`,
+		note.ID, note.Title, note.Body,
+	)
+	if err != nil {
+		return fmt.Errorf("recording revision: %w", err)
+	}
+	return nil
+}
+
+// NoteRevisionCleanupTask prunes revisions older than maxAge while always
+// keeping the newest keep revisions of every note, which includes the
+// revision matching its current content. Candidates come from the
+// created_at index, and each is checked against its note's (keep+1)th
+// newest revision through the (note_id, revision) index, so a batch never
+// ranks the whole table.
+func NoteRevisionCleanupTask(keep int, maxAge time.Duration) CleanupTask {
+	return CleanupTask{
+		Name: "note_revisions",
+		Query: `DELETE FROM note_revisions WHERE id IN (
+			SELECT old.id FROM note_revisions AS old
+			WHERE old.created_at < NOW() - $3::interval
+			  AND old.revision <= (
+			      SELECT revision FROM note_revisions
+			      WHERE note_id = old.note_id
+			      ORDER BY revision DESC OFFSET $2 LIMIT 1)
+			LIMIT $1 FOR UPDATE SKIP LOCKED)`,
+		Args: []any{keep, fmt.Sprintf("%d seconds", int64(maxAge.Seconds()))},
+	}
+}
+
+type RevisionService struct {
+	db     DB
+	events NoteEventPublisher
+}
+
+// NewRevisionService creates a revision service. Restores are reported to
+// events as updates; events may be nil.
+func NewRevisionService(db DB, events NoteEventPublisher) *RevisionService {
+	return &RevisionService{db: db, events: events}
+}
+
+// ListByNote returns a note's revisions newest first, without bodies.
+func (s *RevisionService) ListByNote(ctx context.Context, userID, noteID uuid.UUID) ([]*models.NoteRevision, error) {
+	var exists bool
+	if err := s.db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		noteID, userID,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("checking note: %w", err)
+	}
+	if !exists {
+		return nil, ErrNoteNotFound
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT id, note_id, revision, title, created_at
+		 FROM note_revisions WHERE note_id = $1 ORDER BY revision DESC`,
+		noteID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.NoteRevision{}
+	for rows.Next() {
+		rev := &models.NoteRevision{}
+		if err := rows.Scan(&rev.ID, &rev.NoteID, &rev.Revision, &rev.Title, &rev.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning revision: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating revisions: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// Get returns one revision with its body. Revision 0 means the latest.
+func (s *RevisionService) Get(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.NoteRevision, error) {
+	rev, err := getRevision(ctx, s.db, userID, noteID, revision)
+	if err != nil && !errors.Is(err, ErrRevisionNotFound) {
+		return nil, fmt.Errorf("getting revision: %w", err)
+	}
+	return rev, err
+}
+
+func getRevision(ctx context.Context, db DBConn, userID, noteID uuid.UUID, revision int) (*models.NoteRevision, error) {
+	rev := &models.NoteRevision{}
+	err := db.QueryRow(ctx,
+		`SELECT r.id, r.note_id, r.revision, r.title, r.body, r.created_at
+		 FROM note_revisions r JOIN notes n ON n.id = r.note_id
+		 WHERE r.note_id = $1 AND n.user_id = $2 AND n.deleted_at IS NULL
+		   AND ($3 = 0 OR r.revision = $3)
+		 ORDER BY r.revision DESC LIMIT 1`,
+		noteID, userID, revision,
+	).Scan(&rev.ID, &rev.NoteID, &rev.Revision, &rev.Title, &rev.Body, &rev.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// Diff compares two revisions line by line. to 0 compares against the latest.
+func (s *RevisionService) Diff(ctx context.Context, userID, noteID uuid.UUID, from, to int) (*models.RevisionDiff, error) {
+	fromRev, err := s.Get(ctx, userID, noteID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.Get(ctx, userID, noteID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, truncated := DiffLines(strings.Split(fromRev.Body, "\n"), strings.Split(toRev.Body, "\n"))
+	return &models.RevisionDiff{
+		From:      fromRev.Revision,
+		To:        toRev.Revision,
+		FromTitle: fromRev.Title,
+		ToTitle:   toRev.Title,
+		Lines:     lines,
+		Truncated: truncated,
+	}, nil
+}
+
+// Restore copies an old revision's title and body onto the note, recording
+// the result as a new revision so the history stays append-only.
+func (s *RevisionService) Restore(ctx context.Context, userID, noteID uuid.UUID, revision int) (*models.Note, error) {
+	note := &models.Note{}
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		rev, err := getRevision(ctx, tx, userID, noteID, revision)
+		if err != nil {
+			return err
+		}
+		err = scanNote(tx.QueryRow(ctx,
+			`UPDATE notes SET title = $1, body = $2
+			 WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
+			 RETURNING `+noteColumns,
+			rev.Title, rev.Body, noteID, userID,
+		), note)
+		if err != nil {
+			return err
+		}
+		return recordRevision(ctx, tx, note)
+	})
+	if errors.Is(err, ErrRevisionNotFound) {
+		return nil, err
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("restoring revision: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, noteChange{event: models.NoteEventUpdated, noteID: note.ID, ownerID: note.UserID, version: note.Version})
+	return note, nil
+}
+
+// DiffLines returns an edit script turning a into b as equal, delete and
+// insert lines. It aligns lines by longest common subsequence after trimming
+// the common prefix and suffix; when what remains is too large to align it
+// reports the region as deleted and re-inserted and sets truncated.
+func DiffLines(a, b []string) (lines []models.DiffLine, truncated bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines = make([]models.DiffLine, 0, len(a)+len(b)-prefix-suffix)
+	for _, line := range a[:prefix] {
+		lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: line})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		truncated = true
+		for _, line := range midA {
+			lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: line})
+		}
+		for _, line := range midB {
+			lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: line})
+		}
+	} else {
+		lines = appendLCSDiff(lines, midA, midB)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: line})
+	}
+	return lines, truncated
+}
+
+func appendLCSDiff(lines []models.DiffLine, a, b []string) []models.DiffLine {
+	n, m := len(a), len(b)
+	width := m + 1
+	// lcs[i*width+j] is the LCS length of a[i:] and b[j:]. maxDiffCells keeps
+	// min(n, m), and so every entry, well inside uint16.
+	lcs := make([]uint16, (n+1)*width)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: a[i]})
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: a[i]})
+			i++
+		default:
+			lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: a[i]})
+	}
+	for ; j < m; j++ {
+		lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: b[j]})
+	}
+	return lines
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func diffString(lines []models.DiffLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.Op {
+		case models.DiffEqual:
+			b.WriteString(" ")
+		case models.DiffInsert:
+			b.WriteString("+")
+		case models.DiffDelete:
+			b.WriteString("-")
+		}
+		b.WriteString(l.Text)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{"identical", []string{"a", "b"}, []string{"a", "b"}, " a\n b\n"},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, " a\n+b\n c\n"},
+		{"delete", []string{"a", "b", "c"}, []string{"a", "c"}, " a\n-b\n c\n"},
+		{"replace", []string{"a", "b", "c"}, []string{"a", "x", "c"}, " a\n-b\n+x\n c\n"},
+		{"interleaved", []string{"a", "b", "c", "d"}, []string{"b", "x", "d", "e"}, "-a\n b\n-c\n+x\n d\n+e\n"},
+		{"from empty", nil, []string{"a"}, "+a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, truncated := DiffLines(tt.a, tt.b)
+			if truncated {
+				t.Fatal("unexpected truncation")
+			}
+			if got := diffString(lines); got != tt.want {
+				t.Fatalf("diff mismatch\ngot:\n%swant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLines_TruncatesLargeInput(t *testing.T) {
+	a := make([]string, 2100)
+	b := make([]string, 2100)
+	for i := range a {
+		a[i] = "a" + strings.Repeat("x", i%7) + string(rune('0'+i%10))
+		b[i] = "b" + a[i]
+	}
+	a = append([]string{"same"}, a...)
+	b = append([]string{"same"}, b...)
+
+	lines, truncated := DiffLines(a, b)
+	if !truncated {
+		t.Fatal("expected truncation")
+	}
+	if len(lines) != 1+2100*2 || lines[0].Op != models.DiffEqual || lines[1].Op != models.DiffDelete || lines[len(lines)-1].Op != models.DiffInsert {
+		t.Fatalf("unexpected truncated diff shape: %d lines", len(lines))
+	}
+}
+
+func TestRevisionService_ListByNote_NotOwned(t *testing.T) {
+	svc := NewRevisionService(&fakeDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return rowFromValues(false)
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			t.Fatal("revisions listed for a note the user does not own")
+			return nil, nil
+		},
+	}, nil)
+
+	if _, err := svc.ListByNote(context.Background(), uuid.New(), uuid.New()); !errors.Is(err, ErrNoteNotFound) {
+		t.Fatalf("expected ErrNoteNotFound, got %v", err)
+	}
+}
+
+func TestRevisionService_Get_NotFound(t *testing.T) {
+	svc := NewRevisionService(&fakeDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return fakeRow{scanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}, nil)
+
+	if _, err := svc.Get(context.Background(), uuid.New(), uuid.New(), 4); !errors.Is(err, ErrRevisionNotFound) {
+		t.Fatalf("expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestRevisionService_Diff(t *testing.T) {
+	noteID := uuid.New()
+	bodies := map[int]string{2: "one\ntwo", 0: "one\n2\nthree"}
+	revs := map[int]int{2: 2, 0: 5}
+	svc := NewRevisionService(&fakeDB{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			n := args[2].(int)
+			return rowFromValues(uuid.New(), noteID, revs[n], "Title", bodies[n], time.Now())
+		},
+	}, nil)
+
+	diff, err := svc.Diff(context.Background(), uuid.New(), noteID, 2, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff.From != 2 || diff.To != 5 {
+		t.Fatalf("expected diff 2..5, got %d..%d", diff.From, diff.To)
+	}
+	if got := diffString(diff.Lines); got != " one\n-two\n+2\n+three\n" {
+		t.Fatalf("unexpected diff:\n%s", got)
+	}
+}
+
+func TestRevisionService_Restore(t *testing.T) {
+	userID := uuid.New()
+	noteID := uuid.New()
+	var updateArgs []any
+	var recorded bool
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "FROM note_revisions") {
+				return rowFromValues(uuid.New(), noteID, 3, "Old title", "Old body", time.Now())
+			}
+			updateArgs = args
+			return rowFromValues(noteID, userID, args[0], args[1], time.Now(), time.Now(), []string{}, nil, 4, false, false)
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			recorded = strings.Contains(sql, "INSERT INTO note_revisions") && args[1] == "Old title"
+			return fakeCommandTag{rowsAffected: 1}, nil
+		},
+	}
+	events := &mockNoteEventPublisher{}
+	svc := NewRevisionService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, events)
+
+	note, err := svc.Restore(context.Background(), userID, noteID, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if note.Title != "Old title" || note.Body != "Old body" {
+		t.Fatalf("expected restored content, got %q / %q", note.Title, note.Body)
+	}
+	if updateArgs[2] != noteID || updateArgs[3] != userID {
+		t.Fatalf("update not scoped to the note owner: %v", updateArgs)
+	}
+	if !recorded {
+		t.Fatal("expected the restore to be recorded as a new revision")
+	}
+	if len(events.published) != 1 || events.published[0].userID != userID ||
+		events.published[0].event != (models.NoteEvent{Type: models.NoteEventUpdated, NoteID: noteID, Version: 4}) {
+		t.Fatalf("expected an update for the restored note, got %+v", events.published)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var (
+	ErrShareNotFound          = errors.New("share not found")
+	ErrShareRecipientNotFound = errors.New("share recipient not found")
+	ErrShareWithSelf          = errors.New("cannot share a note with its owner")
+	ErrInvalidSharePermission = errors.New("invalid share permission")
+)
+
+const (
+	// shareMissLimit is how many shares with unknown recipients a user may
+	// attempt per shareMissWindow. Each miss tells the user that an address
+	// is not registered, so the limit keeps Share from being used to probe
+	// for accounts.
+	shareMissLimit  = 20
+	shareMissWindow = time.Hour
+)
+
+type ShareService struct {
+	db     DB
+	misses *AttemptLimiter
+}
+
+// NewShareService builds a ShareService. With a nil redis, unknown
+// recipients are not limited.
+func NewShareService(db DB, redis RedisClient) *ShareService {
+	s := &ShareService{db: db}
+	if redis != nil {
+		s.misses = NewAttemptLimiter(redis, "share_misses:", shareMissLimit, shareMissWindow)
+	}
+	return s
+}
+
+// Share gives the user identified by recipient, an email address or a
+// username, access to one of the owner's notes. Sharing again with the same
+// user changes their permission. Both are matched without regard to case.
+// After too many unknown recipients it returns ErrTooManyAttempts.
+func (s *ShareService) Share(ctx context.Context, ownerID, noteID uuid.UUID, recipient, permission string) (*models.NoteShare, error) {
+	if permission != models.SharePermissionViewer && permission != models.SharePermissionEditor {
+		return nil, ErrInvalidSharePermission
+	}
+	recipient = strings.TrimSpace(recipient)
+	if err := s.misses.Check(ctx, ownerID.String()); err != nil {
+		return nil, err
+	}
+
+	share := &models.NoteShare{NoteID: noteID, Permission: permission}
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		if err := checkNoteOwner(ctx, tx, ownerID, noteID); err != nil {
+			return err
+		}
+		err := tx.QueryRow(ctx,
+			`SELECT id, username FROM users WHERE email = LOWER($1) OR LOWER(username) = LOWER($1)
+			 ORDER BY email = LOWER($1) DESC, username = $1 DESC LIMIT 1`,
+			recipient,
+		).Scan(&share.UserID, &share.Username)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrShareRecipientNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("finding recipient: %w", err)
+		}
+		if share.UserID == ownerID {
+			return ErrShareWithSelf
+		}
+		return tx.QueryRow(ctx,
+			`INSERT INTO note_shares (note_id, user_id, permission)
+			 VALUES ($1, $2, $3)
+			 ON CONFLICT (note_id, user_id) DO UPDATE SET permission = EXCLUDED.permission
+			 RETURNING created_at`,
+			noteID, share.UserID, permission,
+		).Scan(&share.CreatedAt)
+	})
+	if err == ErrShareRecipientNotFound {
+		s.misses.Fail(ctx, ownerID.String())
+		return nil, err
+	}
+	if err == ErrNoteNotFound || err == ErrShareWithSelf {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sharing note: %w", err)
+	}
+
+	return share, nil
+}
+
+// ListByNote returns who an owner's note is shared with, oldest share first.
+func (s *ShareService) ListByNote(ctx context.Context, ownerID, noteID uuid.UUID) ([]*models.NoteShare, error) {
+	if err := checkNoteOwner(ctx, s.db, ownerID, noteID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT s.note_id, s.user_id, u.username, s.permission, s.created_at
+		 FROM note_shares s JOIN users u ON u.id = s.user_id
+		 WHERE s.note_id = $1
+		 ORDER BY s.created_at, u.username`,
+		noteID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing shares: %w", err)
+	}
+	defer rows.Close()
+
+	shares := []*models.NoteShare{}
+	for rows.Next() {
+		share := &models.NoteShare{}
+		if err := rows.Scan(&share.NoteID, &share.UserID, &share.Username, &share.Permission, &share.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning share: %w", err)
+		}
+		shares = append(shares, share)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating shares: %w", err)
+	}
+
+	return shares, nil
+}
+
+// Revoke removes shareUserID's access to a note. The owner can revoke any
+// share and a recipient can remove their own.
+func (s *ShareService) Revoke(ctx context.Context, userID, noteID, shareUserID uuid.UUID) error {
+	result, err := s.db.Exec(ctx,
+		`DELETE FROM note_shares s USING notes
+		 WHERE s.note_id = $1 AND s.user_id = $3 AND notes.id = s.note_id
+		   AND (notes.user_id = $2 OR s.user_id = $2)`,
+		noteID, userID, shareUserID,
+	)
+	if err != nil {
+		return fmt.Errorf("revoking share: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrShareNotFound
+	}
+	return nil
+}
+
+// ListSharedWithUser returns the notes other users shared with userID, most
+// recently updated first. Trashed notes are left out.
+func (s *ShareService) ListSharedWithUser(ctx context.Context, userID uuid.UUID) ([]*models.SharedNote, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+noteColumns+`, s.permission, u.username
+		 FROM note_shares s
+		 JOIN notes ON notes.id = s.note_id
+		 JOIN users u ON u.id = notes.user_id
+		 WHERE s.user_id = $1 AND notes.deleted_at IS NULL
+		 ORDER BY notes.updated_at DESC, notes.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing shared notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []*models.SharedNote{}
+	for rows.Next() {
+		shared := &models.SharedNote{Note: &models.Note{}}
+		if err := scanNote(rows, shared.Note, &shared.Permission, &shared.OwnerUsername); err != nil {
+			return nil, fmt.Errorf("scanning shared note: %w", err)
+		}
+		notes = append(notes, shared)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating shared notes: %w", err)
+	}
+
+	return notes, nil
+}
+
+// checkNoteOwner returns ErrNoteNotFound unless userID owns the live note.
+func checkNoteOwner(ctx context.Context, db DBConn, userID, noteID uuid.UUID) error {
+	var exists bool
+	if err := db.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM notes WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL)`,
+		noteID, userID,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("checking note: %w", err)
+	}
+	if !exists {
+		return ErrNoteNotFound
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestShareService_Share(t *testing.T) {
+	ownerID := uuid.New()
+	recipientID := uuid.New()
+	now := time.Now()
+
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			switch {
+			case strings.Contains(sql, "SELECT EXISTS"):
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*bool) = true
+					return nil
+				}}
+			case strings.Contains(sql, "FROM users"):
+				if args[0] != "Bob@Example.com" {
+					t.Fatalf("unexpected recipient %v", args[0])
+				}
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*uuid.UUID) = recipientID
+					*dest[1].(*string) = "bob"
+					return nil
+				}}
+			default:
+				if !strings.Contains(sql, "ON CONFLICT (note_id, user_id) DO UPDATE") || args[2] != models.SharePermissionEditor {
+					t.Fatalf("unexpected insert %s %v", sql, args)
+				}
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*time.Time) = now
+					return nil
+				}}
+			}
+		},
+	}
+
+	share, err := NewShareService(db, nil).Share(context.Background(), ownerID, uuid.New(), " Bob@Example.com ", models.SharePermissionEditor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if share.UserID != recipientID || share.Username != "bob" || !share.CreatedAt.Equal(now) {
+		t.Fatalf("unexpected share %+v", share)
+	}
+}
+
+func TestShareService_Share_LimitsUnknownRecipients(t *testing.T) {
+	ownerID := uuid.New()
+	lookups := 0
+	db := &mockDB{
+		queryRow: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "SELECT EXISTS") {
+				return mockRow{scan: func(dest ...any) error {
+					*dest[0].(*bool) = true
+					return nil
+				}}
+			}
+			if !strings.Contains(sql, "LOWER(username) = LOWER($1)") {
+				t.Fatalf("expected usernames matched without regard to case, got %s", sql)
+			}
+			lookups++
+			return mockRow{scan: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+	}
+	svc := NewShareService(db, newFakeRedis())
+
+	for i := 0; i < shareMissLimit; i++ {
+		if _, err := svc.Share(context.Background(), ownerID, uuid.New(), "nobody", models.SharePermissionViewer); !errors.Is(err, ErrShareRecipientNotFound) {
+			t.Fatalf("attempt %d: expected ErrShareRecipientNotFound, got %v", i, err)
+		}
+	}
+	if _, err := svc.Share(context.Background(), ownerID, uuid.New(), "nobody", models.SharePermissionViewer); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("expected ErrTooManyAttempts, got %v", err)
+	}
+	if lookups != shareMissLimit {
+		t.Fatalf("expected no lookup once limited, got %d", lookups)
+	}
+	// Other users are not affected
+	if _, err := svc.Share(context.Background(), uuid.New(), uuid.New(), "nobody", models.SharePermissionViewer); !errors.Is(err, ErrShareRecipientNotFound) {
+		t.Fatalf("expected ErrShareRecipientNotFound for another user, got %v", err)
+	}
+}
+
+func TestShareService_Share_Errors(t *testing.T) {
+	ownerID := uuid.New()
+	tests := []struct {
+		name       string
+		permission string
+		owned      bool
+		recipient  *uuid.UUID
+		want       error
+	}{
+		{"bad permission", "owner", true, &ownerID, ErrInvalidSharePermission},
+		{"not owner", models.SharePermissionViewer, false, &ownerID, ErrNoteNotFound},
+		{"unknown recipient", models.SharePermissionViewer, true, nil, ErrShareRecipientNotFound},
+		{"self", models.SharePermissionViewer, true, &ownerID, ErrShareWithSelf},
+	}
+	for _, tt := range tests {
+		db := &mockDB{
+			queryRow: func(ctx context.Context, sql string, args ...any) Row {
+				if strings.Contains(sql, "SELECT EXISTS") {
+					return mockRow{scan: func(dest ...any) error {
+						*dest[0].(*bool) = tt.owned
+						return nil
+					}}
+				}
+				if strings.Contains(sql, "INSERT") {
+					t.Fatalf("%s: unexpected insert", tt.name)
+				}
+				return mockRow{scan: func(dest ...any) error {
+					if tt.recipient == nil {
+						return pgx.ErrNoRows
+					}
+					*dest[0].(*uuid.UUID) = *tt.recipient
+					return nil
+				}}
+			},
+		}
+
+		_, err := NewShareService(db, nil).Share(context.Background(), ownerID, uuid.New(), "someone", tt.permission)
+		if !errors.Is(err, tt.want) {
+			t.Fatalf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestShareService_Revoke_NotFound(t *testing.T) {
+	db := &mockDB{
+		exec: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if !strings.Contains(sql, "notes.user_id = $2 OR s.user_id = $2") {
+				t.Fatalf("expected owner or recipient check, got %s", sql)
+			}
+			return mockCommandTag{affected: 0}, nil
+		},
+	}
+
+	err := NewShareService(db, nil).Revoke(context.Background(), uuid.New(), uuid.New(), uuid.New())
+	if !errors.Is(err, ErrShareNotFound) {
+		t.Fatalf("expected ErrShareNotFound, got %v", err)
+	}
+}
+
+func TestShareService_ListSharedWithUser(t *testing.T) {
+	userID := uuid.New()
+	now := time.Now()
+	db := &mockDB{
+		query: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			return &mockRows{rows: [][]any{{uuid.New(), uuid.New(), "Title", "Body", now, now, []string{}, nil, 2, false, false, models.SharePermissionViewer, "alice"}}}, nil
+		},
+	}
+
+	notes, err := NewShareService(db, nil).ListSharedWithUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) != 1 || notes[0].Permission != models.SharePermissionViewer || notes[0].OwnerUsername != "alice" {
+		t.Fatalf("unexpected shared notes %+v", notes)
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+var (
+	ErrTagNotFound  = errors.New("tag not found")
+	ErrTagExists    = errors.New("tag already exists")
+	ErrInvalidTag   = errors.New("invalid tag")
+	ErrTooManyTags  = errors.New("too many tags")
+	ErrTagSelfMerge = errors.New("cannot merge a tag into itself")
+)
+
+const (
+	MaxTagLength   = 50
+	MaxTagsPerNote = 20
+)
+
+// NormalizeTagName trims a tag, drops a leading '#', lower-cases it and
+// collapses inner whitespace so "#Work  Stuff" and "work stuff" are one tag.
+func NormalizeTagName(name string) (string, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" || utf8.RuneCountInString(name) > MaxTagLength {
+		return "", ErrInvalidTag
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 || strings.Contains(name, ",") {
+		return "", ErrInvalidTag
+	}
+	return name, nil
+}
+
+// NormalizeTags normalizes, de-duplicates and sorts a note's tag list. A nil
+// list stays nil so callers can tell "not provided" from "clear all tags".
+func NormalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		name, err := NormalizeTagName(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	if len(normalized) > MaxTagsPerNote {
+		return nil, ErrTooManyTags
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+type TagService struct {
+	db     DB
+	events NoteEventPublisher
+}
+
+// NewTagService creates a tag service. Notes whose tags a rename, merge or
+// delete changes are reported to events; events may be nil.
+func NewTagService(db DB, events NoteEventPublisher) *TagService {
+	return &TagService{db: db, events: events}
+}
+
+const tagColumns = `tags.id, tags.name,
+	(SELECT COUNT(*) FROM note_tags nt JOIN notes n ON n.id = nt.note_id
+	 WHERE nt.tag_id = tags.id AND n.deleted_at IS NULL) AS note_count,
+	tags.created_at`
+
+func scanTag(row Row, tag *models.Tag) error {
+	return row.Scan(&tag.ID, &tag.Name, &tag.NoteCount, &tag.CreatedAt)
+}
+
+// touchTaggedNotes updates every note outside the trash carrying the tag, so
+// their versions move with their tag lists and cached ETags stop matching,
+// and returns the changes to report. Restoring a trashed note moves its
+// version anyway. It must run before the tag's links are removed.
+func touchTaggedNotes(ctx context.Context, tx Tx, tagID uuid.UUID) ([]noteChange, error) {
+	rows, err := tx.Query(ctx,
+		`UPDATE notes SET updated_at = NOW()
+		 WHERE id IN (SELECT note_id FROM note_tags WHERE tag_id = $1) AND deleted_at IS NULL
+		 RETURNING id, user_id, version`,
+		tagID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanNoteChanges(rows, models.NoteEventUpdated)
+}
+
+// ListByUser returns all of a user's tags with the number of notes carrying
+// each, including tags no note uses any more.
+func (s *TagService) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.Tag, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT `+tagColumns+` FROM tags WHERE user_id = $1 ORDER BY name`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := scanTag(rows, tag); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// Rename changes a tag's name on every note at once. It returns ErrTagExists
+// when the user already has a tag with that name; Merge combines them.
+func (s *TagService) Rename(ctx context.Context, userID, tagID uuid.UUID, name string) (*models.Tag, error) {
+	name, err := NormalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{}
+	var changes []noteChange
+	err = withTx(ctx, s.db, func(tx Tx) error {
+		var existing uuid.UUID
+		err := tx.QueryRow(ctx,
+			`SELECT id FROM tags WHERE user_id = $1 AND name = $2`,
+			userID, name,
+		).Scan(&existing)
+		if err == nil && existing != tagID {
+			return ErrTagExists
+		}
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		if err := scanTag(tx.QueryRow(ctx,
+			`UPDATE tags SET name = $1 WHERE id = $2 AND user_id = $3
+			 RETURNING `+tagColumns,
+			name, tagID, userID,
+		), tag); err != nil {
+			return err
+		}
+		changes, err = touchTaggedNotes(ctx, tx, tagID)
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrTagNotFound
+	}
+	if errors.Is(err, ErrTagExists) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("renaming tag: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changes...)
+	return tag, nil
+}
+
+// Merge moves every note tagged with source onto target and deletes source.
+func (s *TagService) Merge(ctx context.Context, userID, sourceID, targetID uuid.UUID) (*models.Tag, error) {
+	if sourceID == targetID {
+		return nil, ErrTagSelfMerge
+	}
+
+	tag := &models.Tag{}
+	var changes []noteChange
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		var owned int
+		if err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM tags WHERE user_id = $1 AND id IN ($2, $3)`,
+			userID, sourceID, targetID,
+		).Scan(&owned); err != nil {
+			return err
+		}
+		if owned != 2 {
+			return ErrTagNotFound
+		}
+
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO note_tags (note_id, tag_id)
+			 SELECT note_id, $2::uuid FROM note_tags WHERE tag_id = $1
+			 ON CONFLICT DO NOTHING`,
+			sourceID, targetID,
+		); err != nil {
+			return err
+		}
+		var err error
+		if changes, err = touchTaggedNotes(ctx, tx, sourceID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DELETE FROM tags WHERE id = $1`, sourceID); err != nil {
+			return err
+		}
+
+		return scanTag(tx.QueryRow(ctx, `SELECT `+tagColumns+` FROM tags WHERE id = $1`, targetID), tag)
+	})
+	if errors.Is(err, ErrTagNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("merging tags: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changes...)
+	return tag, nil
+}
+
+// Delete removes a tag from every note and deletes it.
+func (s *TagService) Delete(ctx context.Context, userID, tagID uuid.UUID) error {
+	var changes []noteChange
+	err := withTx(ctx, s.db, func(tx Tx) error {
+		// The lock keeps notes from being tagged between the touch and the
+		// delete
+		var id uuid.UUID
+		err := tx.QueryRow(ctx,
+			`SELECT id FROM tags WHERE id = $1 AND user_id = $2 FOR UPDATE`,
+			tagID, userID,
+		).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrTagNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if changes, err = touchTaggedNotes(ctx, tx, tagID); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM tags WHERE id = $1`, tagID)
+		return err
+	})
+	if errors.Is(err, ErrTagNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("deleting tag: %w", err)
+	}
+
+	publishNoteChanges(ctx, s.events, changes...)
+	return nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/notes-template/internal/models"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := NormalizeTags([]string{"  #Work ", "work", "Side   Project", "home"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(tags, "|") != "home|side project|work" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	if tags, err := NormalizeTags(nil); err != nil || tags != nil {
+		t.Fatalf("expected nil tags to stay nil, got %v, %v", tags, err)
+	}
+	if tags, err := NormalizeTags([]string{}); err != nil || tags == nil || len(tags) != 0 {
+		t.Fatalf("expected empty tags to stay empty, got %v, %v", tags, err)
+	}
+
+	for _, bad := range []string{"", "#", "a,b", strings.Repeat("x", MaxTagLength+1), "bell\x07"} {
+		if _, err := NormalizeTags([]string{bad}); !errors.Is(err, ErrInvalidTag) {
+			t.Fatalf("expected ErrInvalidTag for %q, got %v", bad, err)
+		}
+	}
+
+	many := make([]string, MaxTagsPerNote+1)
+	for i := range many {
+		many[i] = uuid.NewString()
+	}
+	if _, err := NormalizeTags(many); !errors.Is(err, ErrTooManyTags) {
+		t.Fatalf("expected ErrTooManyTags, got %v", err)
+	}
+}
+
+func TestTagService_Rename(t *testing.T) {
+	userID := uuid.New()
+	tagID := uuid.New()
+	now := time.Now()
+
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.HasPrefix(sql, "SELECT id FROM tags") {
+				if args[1] != "renamed tag" {
+					t.Fatalf("expected normalized name, got %v", args[1])
+				}
+				return fakeRow{scanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+			}
+			return rowFromValues(tagID, "renamed tag", 3, now)
+		},
+	}
+	noteID := uuid.New()
+	tx.QueryFunc = func(ctx context.Context, sql string, args ...any) (Rows, error) {
+		if !strings.HasPrefix(sql, "UPDATE notes SET updated_at") || args[0] != tagID {
+			t.Fatalf("unexpected query %s %v", sql, args)
+		}
+		return &fakeRows{rows: [][]any{{noteID, userID, 4}}}, nil
+	}
+	events := &mockNoteEventPublisher{}
+	svc := NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, events)
+
+	tag, err := svc.Rename(context.Background(), userID, tagID, " Renamed  Tag ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tag.Name != "renamed tag" || tag.NoteCount != 3 {
+		t.Fatalf("unexpected tag: %+v", tag)
+	}
+	// The touched notes' versions moved, so their clients must hear of it
+	if len(events.published) != 1 || events.published[0].userID != userID ||
+		events.published[0].event != (models.NoteEvent{Type: models.NoteEventUpdated, NoteID: noteID, Version: 4}) {
+		t.Fatalf("expected an update for the touched note, got %+v", events.published)
+	}
+}
+
+func TestTagService_Rename_Exists(t *testing.T) {
+	rolledBack := false
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return rowFromValues(uuid.New())
+		},
+		RollbackFunc: func(ctx context.Context) error {
+			rolledBack = true
+			return nil
+		},
+	}
+	svc := NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+
+	if _, err := svc.Rename(context.Background(), uuid.New(), uuid.New(), "taken"); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected ErrTagExists, got %v", err)
+	}
+	if !rolledBack {
+		t.Fatal("expected transaction to roll back")
+	}
+}
+
+func TestTagService_Merge(t *testing.T) {
+	sourceID := uuid.New()
+	targetID := uuid.New()
+
+	svc := NewTagService(&fakeDB{}, nil)
+	if _, err := svc.Merge(context.Background(), uuid.New(), sourceID, sourceID); !errors.Is(err, ErrTagSelfMerge) {
+		t.Fatalf("expected ErrTagSelfMerge, got %v", err)
+	}
+
+	// Only one of the two tags belongs to the user
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return rowFromValues(1)
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			t.Fatalf("unexpected statement: %s", sql)
+			return nil, nil
+		},
+	}
+	svc = NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+	if _, err := svc.Merge(context.Background(), uuid.New(), sourceID, targetID); !errors.Is(err, ErrTagNotFound) {
+		t.Fatalf("expected ErrTagNotFound, got %v", err)
+	}
+}
+
+func TestTagService_Merge_Publishes(t *testing.T) {
+	userID, sourceID, targetID := uuid.New(), uuid.New(), uuid.New()
+	noteIDs := []uuid.UUID{uuid.New(), uuid.New()}
+	var statements []string
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			if strings.Contains(sql, "COUNT(*) FROM tags") {
+				return rowFromValues(2)
+			}
+			return rowFromValues(targetID, "target", 2, time.Now())
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			if args[0] != sourceID {
+				t.Fatalf("expected the source tag's notes touched, got %v", args[0])
+			}
+			statements = append(statements, sql)
+			return &fakeRows{rows: [][]any{{noteIDs[0], userID, 3}, {noteIDs[1], userID, 5}}}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			statements = append(statements, sql)
+			return fakeCommandTag{rowsAffected: 1}, nil
+		},
+	}
+	events := &mockNoteEventPublisher{}
+	svc := NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, events)
+
+	if _, err := svc.Merge(context.Background(), userID, sourceID, targetID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(statements) != 3 || !strings.HasPrefix(statements[1], "UPDATE notes SET updated_at") ||
+		!strings.HasPrefix(statements[2], "DELETE FROM tags") {
+		t.Fatalf("expected the source tag's notes touched before it is deleted, got %v", statements)
+	}
+	if len(events.published) != 2 || events.published[0].event.NoteID != noteIDs[0] ||
+		events.published[1].event.NoteID != noteIDs[1] || events.published[1].event.Version != 5 {
+		t.Fatalf("expected an update per touched note, got %+v", events.published)
+	}
+}
+
+func TestTagService_Delete(t *testing.T) {
+	tagID := uuid.New()
+	userID, noteID := uuid.New(), uuid.New()
+	var statements []string
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return rowFromValues(tagID)
+		},
+		QueryFunc: func(ctx context.Context, sql string, args ...any) (Rows, error) {
+			if args[0] != tagID {
+				t.Fatalf("expected the tag id, got %v", args[0])
+			}
+			statements = append(statements, sql)
+			return &fakeRows{rows: [][]any{{noteID, userID, 2}}}, nil
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			if args[0] != tagID {
+				t.Fatalf("expected the tag id, got %v", args[0])
+			}
+			statements = append(statements, sql)
+			return fakeCommandTag{rowsAffected: 1}, nil
+		},
+	}
+	events := &mockNoteEventPublisher{}
+	svc := NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, events)
+
+	if err := svc.Delete(context.Background(), userID, tagID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The notes must be touched while the tag still links them
+	if len(statements) != 2 || !strings.HasPrefix(statements[0], "UPDATE notes SET updated_at") ||
+		!strings.HasPrefix(statements[1], "DELETE FROM tags") {
+		t.Fatalf("expected the tagged notes touched before the delete, got %v", statements)
+	}
+	if len(events.published) != 1 || events.published[0].event.NoteID != noteID || events.published[0].event.Version != 2 {
+		t.Fatalf("expected an update for the touched note, got %+v", events.published)
+	}
+}
+
+func TestTagService_Delete_NotFound(t *testing.T) {
+	tx := &fakeTx{
+		QueryRowFunc: func(ctx context.Context, sql string, args ...any) Row {
+			return fakeRow{scanFunc: func(dest ...any) error { return pgx.ErrNoRows }}
+		},
+		ExecFunc: func(ctx context.Context, sql string, args ...any) (CommandTag, error) {
+			t.Fatalf("unexpected statement: %s", sql)
+			return nil, nil
+		},
+	}
+	svc := NewTagService(&fakeDB{BeginFunc: func(ctx context.Context) (Tx, error) { return tx, nil }}, nil)
+
+	if err := svc.Delete(context.Background(), uuid.New(), uuid.New()); !errors.Is(err, ErrTagNotFound) {
+		t.Fatalf("expected ErrTagNotFound, got %v", err)
+	}
+}
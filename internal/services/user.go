@@ -47,15 +47,16 @@ func (s *UserService) Create(ctx context.Context, params models.CreateUserParams
 
 	user := &models.User{}
 	err = s.db.QueryRow(ctx,
-		`INSERT INTO users (email, password_hash, username, email_verified)
-		 VALUES ($1, $2, $3, false)
+		`INSERT INTO users (email, password_hash, username, email_verified, email_verified_at)
+		 VALUES ($1, $2, $3, $4, CASE WHEN $4 THEN NOW() END)
 		 RETURNING id, email, password_hash, username, email_verified, email_verified_at, created_at, updated_at`,
-		params.Email, params.PasswordHash, params.Username,
+		params.Email, params.PasswordHash, params.Username, params.EmailVerified,
 	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Username, &user.EmailVerified, &user.EmailVerifiedAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("creating user: %w", err)
 	}
+	user.HasPassword = user.PasswordHash != ""
 
 	return user, nil
 }
@@ -74,6 +75,7 @@ func (s *UserService) GetByID(ctx context.Context, id uuid.UUID) (*models.User,
 	if err != nil {
 		return nil, fmt.Errorf("getting user by id: %w", err)
 	}
+	user.HasPassword = user.PasswordHash != ""
 
 	return user, nil
 }
@@ -92,6 +94,7 @@ func (s *UserService) GetByEmail(ctx context.Context, email string) (*models.Use
 	if err != nil {
 		return nil, fmt.Errorf("getting user by email: %w", err)
 	}
+	user.HasPassword = user.PasswordHash != ""
 
 	return user, nil
 }
@@ -0,0 +1,38 @@
+// Package storage keeps file contents, such as note attachments, outside the
+// database.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// ErrBlobNotFound is returned by Open for keys that were never stored or
+// have been deleted.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore stores immutable blobs under keys chosen by the caller.
+type BlobStore interface {
+	// Put stores size bytes from r under key, replacing any existing blob.
+	// A reader that yields a different number of bytes is an error.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns a seekable reader for the blob, so it can serve range
+	// requests.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Keys become file names and object names, so they are limited to
+// characters that are safe in both.
+var validKey = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{2,127}$`)
+
+func checkKey(key string) error {
+	if !validKey.MatchString(key) {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for an S3 bucket. It handles the single
+// part uploads, ranged downloads and deletes the S3 store uses.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.URL.Path
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = body
+		w.Header().Set("ETag", `"etag"`)
+	case http.MethodGet, http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("ETag", `"etag"`)
+		http.ServeContent(w, r, "", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), strings.NewReader(string(body)))
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestS3Store(t *testing.T) (*S3Store, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewTLSServer(fake)
+	t.Cleanup(srv.Close)
+
+	u, _ := url.Parse(srv.URL)
+	store, err := NewS3Store(S3Options{
+		Endpoint:  u.Host,
+		Bucket:    "notes",
+		Region:    "us-east-1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		UseSSL:    true,
+		Prefix:    "attachments/",
+		Transport: srv.Client().Transport,
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+	return store, fake
+}
+
+func testBlobStore(t *testing.T, store BlobStore) {
+	t.Helper()
+	ctx := context.Background()
+	const key = "0b6e7c4e-5c1a-4f4e-9d43-1f1a2b3c4d5e"
+	content := "hello, attachment"
+
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Open before Put: expected ErrBlobNotFound, got %v", err)
+	}
+
+	if err := store.Put(ctx, key, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	blob, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := blob.Seek(7, io.SeekStart); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	rest, err := io.ReadAll(blob)
+	_ = blob.Close()
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(rest) != "attachment" {
+		t.Fatalf("expected %q after seeking, got %q", "attachment", rest)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete of a missing blob: %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Open after Delete: expected ErrBlobNotFound, got %v", err)
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	testBlobStore(t, store)
+}
+
+func TestLocalStore_Put_SizeMismatch(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "abc123", strings.NewReader("too long"), 3, ""); err == nil {
+		t.Fatal("expected an error for a reader longer than size")
+	}
+	if _, err := store.Open(ctx, "abc123"); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("expected no blob after a failed Put, got %v", err)
+	}
+}
+
+func TestBlobStore_InvalidKey(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+	for _, key := range []string{"", "../../etc/passwd", "a/b/c", ".hidden"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	store, fake := newTestS3Store(t)
+	testBlobStore(t, store)
+
+	if err := store.Put(context.Background(), "abc123", strings.NewReader("x"), 1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, ok := fake.objects["/notes/attachments/abc123"]; !ok {
+		t.Fatalf("expected object under the bucket and prefix, got %v", fake.objects)
+	}
+}
@@ -0,0 +1 @@
+ALTER TABLE magic_link_tokens DROP COLUMN IF EXISTS username;
@@ -0,0 +1,2 @@
+-- Requested username for magic links that sign up a new account
+ALTER TABLE magic_link_tokens ADD COLUMN username VARCHAR(100);
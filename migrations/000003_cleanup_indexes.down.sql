@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_password_reset_expires_at;
+DROP INDEX IF EXISTS idx_magic_link_expires_at;
+DROP INDEX IF EXISTS idx_email_verification_expires_at;
@@ -0,0 +1,4 @@
+-- Support the janitor's expiry scans on token tables
+CREATE INDEX idx_email_verification_expires_at ON email_verification_tokens(expires_at);
+CREATE INDEX idx_magic_link_expires_at ON magic_link_tokens(expires_at);
+CREATE INDEX idx_password_reset_expires_at ON password_reset_tokens(expires_at);
@@ -0,0 +1,4 @@
+-- Time the session last confirmed a password or magic link, for step-up
+-- re-authentication. Existing sessions stay NULL so they must re-authenticate.
+ALTER TABLE sessions ADD COLUMN authenticated_at TIMESTAMPTZ;
+ALTER TABLE sessions ALTER COLUMN authenticated_at SET DEFAULT NOW();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_notes_user_title;
+DROP INDEX IF EXISTS idx_notes_user_created;
+DROP INDEX IF EXISTS idx_notes_user_updated;
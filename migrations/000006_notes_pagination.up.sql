@@ -0,0 +1,5 @@
+-- Keyset pagination on GET /api/notes orders by (column, id) within a user.
+-- Each index serves both directions through a backward scan.
+CREATE INDEX idx_notes_user_updated ON notes(user_id, updated_at DESC, id DESC);
+CREATE INDEX idx_notes_user_created ON notes(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_notes_user_title ON notes(user_id, title, id);
@@ -0,0 +1,9 @@
+-- Full-text search over notes. Titles are weighted above bodies so ts_rank
+-- favours notes whose title matches.
+ALTER TABLE notes ADD COLUMN search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(body, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_notes_search_vector ON notes USING GIN (search_vector);
@@ -0,0 +1,18 @@
+-- User-scoped tags. Names are stored normalized (trimmed, lower-case) so the
+-- unique index also prevents case-only duplicates.
+CREATE TABLE tags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE note_tags (
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    tag_id UUID NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+-- Tag counts and ?tag= filtering look up by tag
+CREATE INDEX idx_note_tags_tag_id ON note_tags(tag_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notes_notebook_id;
+ALTER TABLE notes DROP COLUMN IF EXISTS notebook_id;
+
+DROP TRIGGER IF EXISTS update_notebooks_updated_at ON notebooks;
+DROP TABLE IF EXISTS notebooks;
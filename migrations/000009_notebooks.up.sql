@@ -0,0 +1,24 @@
+-- Notebooks nest through parent_id; deleting a notebook deletes its
+-- sub-notebooks. Notes fall back to the root when their notebook goes away,
+-- and NotebookService.Delete removes them first when asked to cascade.
+CREATE TABLE notebooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES notebooks(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_notebooks_user_parent ON notebooks(user_id, parent_id, position);
+CREATE INDEX idx_notebooks_parent_id ON notebooks(parent_id);
+
+CREATE TRIGGER update_notebooks_updated_at
+    BEFORE UPDATE ON notebooks
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE notes ADD COLUMN notebook_id UUID REFERENCES notebooks(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_notes_notebook_id ON notes(notebook_id);
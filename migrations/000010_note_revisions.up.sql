@@ -0,0 +1,18 @@
+-- Every saved version of a note, including the current one. Revisions are
+-- numbered per note; the highest number matches the note's content.
+CREATE TABLE note_revisions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (note_id, revision)
+);
+
+-- Supports the janitor's retention scan
+CREATE INDEX idx_note_revisions_created_at ON note_revisions(created_at);
+
+-- Existing notes start their history at their current content
+INSERT INTO note_revisions (note_id, revision, title, body, created_at)
+SELECT id, 1, title, body, COALESCE(updated_at, NOW()) FROM notes;
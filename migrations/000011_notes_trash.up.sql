@@ -0,0 +1,7 @@
+-- Deleting a note moves it to the trash; the janitor purges it later.
+ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- GET /api/notes/trash lists a user's trashed notes, newest first.
+CREATE INDEX idx_notes_user_deleted_at ON notes(user_id, deleted_at DESC) WHERE deleted_at IS NOT NULL;
+-- The purge task scans trashed notes across users by age.
+CREATE INDEX idx_notes_deleted_at ON notes(deleted_at) WHERE deleted_at IS NOT NULL;
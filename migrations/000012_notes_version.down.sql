@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS bump_notes_version ON notes;
+DROP FUNCTION IF EXISTS bump_note_version();
+ALTER TABLE notes DROP COLUMN IF EXISTS version;
@@ -0,0 +1,16 @@
+-- Notes carry a version for ETag/If-Match optimistic concurrency. The trigger
+-- bumps it on every update, including ones made by foreign key actions.
+ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_note_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER bump_notes_version
+    BEFORE UPDATE ON notes
+    FOR EACH ROW
+    EXECUTE FUNCTION bump_note_version();
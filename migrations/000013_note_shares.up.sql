@@ -0,0 +1,12 @@
+-- Notes can be shared with other users as viewers (read only) or editors
+-- (read and write). Only the owner can share, change tags or notebooks,
+-- restore from the trash or purge.
+CREATE TABLE note_shares (
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(10) NOT NULL CHECK (permission IN ('viewer', 'editor')),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX idx_note_shares_user_id ON note_shares(user_id);
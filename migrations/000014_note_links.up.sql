@@ -0,0 +1,14 @@
+-- Public read-only links to a single note. Only the SHA-256 of the link token
+-- is stored, like other tokens; an optional password is stored as a bcrypt
+-- hash. Revoking a link deletes its row.
+CREATE TABLE note_links (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    password_hash VARCHAR(255),
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_note_links_note_id ON note_links(note_id);
+CREATE INDEX idx_note_links_expires_at ON note_links(expires_at) WHERE expires_at IS NOT NULL;
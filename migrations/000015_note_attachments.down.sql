@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS note_attachments_queue_blob_deletion ON note_attachments;
+DROP FUNCTION IF EXISTS queue_attachment_blob_deletion();
+DROP TABLE IF EXISTS note_attachments;
+DROP TABLE IF EXISTS attachment_blob_deletions;
@@ -0,0 +1,37 @@
+-- Files attached to notes. The contents live in the configured blob store
+-- under the attachment id; size counts against the uploader's quota.
+CREATE TABLE note_attachments (
+    id UUID PRIMARY KEY,
+    note_id UUID NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL CHECK (size >= 0),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_note_attachments_note_id ON note_attachments(note_id);
+CREATE INDEX idx_note_attachments_user_id ON note_attachments(user_id);
+
+-- Blobs whose rows are gone, including rows removed by cascades when notes
+-- are purged or users deleted. The attachment service deletes the blobs and
+-- then these rows.
+CREATE TABLE attachment_blob_deletions (
+    storage_key VARCHAR(128) PRIMARY KEY,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE OR REPLACE FUNCTION queue_attachment_blob_deletion()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO attachment_blob_deletions (storage_key)
+    VALUES (OLD.id::text)
+    ON CONFLICT DO NOTHING;
+    RETURN OLD;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER note_attachments_queue_blob_deletion
+    AFTER DELETE ON note_attachments
+    FOR EACH ROW
+    EXECUTE FUNCTION queue_attachment_blob_deletion();
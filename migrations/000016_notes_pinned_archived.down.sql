@@ -0,0 +1,15 @@
+DROP INDEX IF EXISTS idx_notes_user_updated;
+DROP INDEX IF EXISTS idx_notes_user_created;
+DROP INDEX IF EXISTS idx_notes_user_title;
+CREATE INDEX idx_notes_user_updated ON notes(user_id, updated_at DESC, id DESC);
+CREATE INDEX idx_notes_user_created ON notes(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_notes_user_title ON notes(user_id, title, id);
+
+DROP TRIGGER IF EXISTS update_notes_updated_at ON notes;
+CREATE TRIGGER update_notes_updated_at
+    BEFORE UPDATE ON notes
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE notes DROP COLUMN IF EXISTS archived;
+ALTER TABLE notes DROP COLUMN IF EXISTS pinned;
@@ -0,0 +1,20 @@
+-- Pinned notes sort first in GET /api/notes and archived notes are left out
+-- of it unless asked for. Neither flag is an edit, so an update that changes
+-- one of them keeps updated_at (the version still moves, as the ETag must).
+ALTER TABLE notes ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE notes ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP TRIGGER IF EXISTS update_notes_updated_at ON notes;
+CREATE TRIGGER update_notes_updated_at
+    BEFORE UPDATE ON notes
+    FOR EACH ROW
+    WHEN (OLD.pinned = NEW.pinned AND OLD.archived = NEW.archived)
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Keyset pagination now orders by (pinned, column, id) within a user.
+DROP INDEX IF EXISTS idx_notes_user_updated;
+DROP INDEX IF EXISTS idx_notes_user_created;
+DROP INDEX IF EXISTS idx_notes_user_title;
+CREATE INDEX idx_notes_user_updated ON notes(user_id, pinned DESC, updated_at DESC, id DESC);
+CREATE INDEX idx_notes_user_created ON notes(user_id, pinned DESC, created_at DESC, id DESC);
+CREATE INDEX idx_notes_user_title ON notes(user_id, pinned DESC, title, id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS note_imports;
+
+DROP INDEX IF EXISTS idx_notes_user_import_key;
+ALTER TABLE notes DROP COLUMN IF EXISTS import_key;
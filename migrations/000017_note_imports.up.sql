@@ -0,0 +1,21 @@
+-- Imported notes carry a key derived from their content, unique per user, so
+-- retrying an import skips the notes that already made it in.
+ALTER TABLE notes ADD COLUMN import_key VARCHAR(64);
+CREATE UNIQUE INDEX idx_notes_user_import_key ON notes(user_id, import_key) WHERE import_key IS NOT NULL;
+
+-- Progress of POST /api/notes/import. errors holds up to 100
+-- {"source", "error"} objects for notes that could not be imported.
+CREATE TABLE note_imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    total INTEGER NOT NULL,
+    imported INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_note_imports_user_id ON note_imports(user_id, created_at DESC);
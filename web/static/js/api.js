@@ -30,6 +30,7 @@ const API = {
   async request(method, path, body = null, options = {}) {
     const headers = {
       'Content-Type': 'application/json',
+      ...options.headers,
     };
 
     if (this.csrfToken && ['POST', 'PUT', 'DELETE', 'PATCH'].includes(method)) {
@@ -42,7 +43,11 @@ const API = {
       credentials: 'same-origin',
     };
 
-    if (body && method !== 'GET') {
+    if (body instanceof FormData) {
+      // The browser sets the multipart boundary
+      delete headers['Content-Type'];
+      fetchOptions.body = body;
+    } else if (body && method !== 'GET') {
       fetchOptions.body = JSON.stringify(body);
     }
 
@@ -98,17 +103,55 @@ const API = {
     }
   },
 
+  // requestWithChallenge solves a proof-of-work challenge before sending the
+  // request, and retries once with a fresh challenge if it was rejected.
+  async requestWithChallenge(method, path, body = null, options = {}) {
+    const headers = await this.solveChallenge();
+    try {
+      return await this.request(method, path, body, { ...options, headers });
+    } catch (error) {
+      if (error instanceof APIError && error.data?.code === 'challenge_required' && !options.challengeRetried) {
+        return this.requestWithChallenge(method, path, body, { ...options, challengeRetried: true });
+      }
+      throw error;
+    }
+  },
+
+  async solveChallenge() {
+    const data = await this.request('GET', '/api/auth/challenge');
+    const { token, difficulty } = data.challenge;
+    let solution;
+    if (typeof Worker !== 'undefined') {
+      solution = await new Promise((resolve, reject) => {
+        const worker = new Worker('/static/js/challenge.js');
+        worker.onmessage = (event) => {
+          worker.terminate();
+          resolve(event.data.solution);
+        };
+        worker.onerror = (event) => {
+          worker.terminate();
+          reject(new APIError(event.message || 'Unable to complete the security check.', 0));
+        };
+        worker.postMessage({ token, difficulty });
+      });
+    } else {
+      solution = Challenge.solve(token, difficulty);
+    }
+    return { 'X-Challenge-Token': token, 'X-Challenge-Solution': solution };
+  },
+
   auth: {
-    async register(email, password, username) {
-      return API.request('POST', '/api/auth/register', {
+    async register(email, password, username, inviteCode) {
+      return API.requestWithChallenge('POST', '/api/auth/register', {
         email,
         password,
         username,
+        invite_code: inviteCode || undefined,
       });
     },
 
-    async login(email, password) {
-      return API.request('POST', '/api/auth/login', { email, password });
+    async login(email, password, next) {
+      return API.request('POST', '/api/auth/login', { email, password, next });
     },
 
     async logout() {
@@ -126,6 +169,16 @@ const API = {
       });
     },
 
+    // Sensitive endpoints fail with 401 and data.code === 'reauth_required'
+    // until the session is re-confirmed with one of these.
+    async reauthenticate(password) {
+      return API.request('POST', '/api/auth/reauthenticate', { method: 'password', password });
+    },
+
+    async reauthenticateWithMagicLink(next) {
+      return API.request('POST', '/api/auth/reauthenticate', { method: 'magic_link', next });
+    },
+
     async verifyEmail(token) {
       return API.request('POST', '/api/auth/verify-email', { token });
     },
@@ -134,40 +187,251 @@ const API = {
       return API.request('POST', '/api/auth/resend-verification');
     },
 
-    async magicLink(email) {
-      return API.request('POST', '/api/auth/magic-link', { email });
+    async magicLink(email, username, next) {
+      const body = { email };
+      if (username) {
+        body.username = username;
+      }
+      if (next) {
+        body.next = next;
+      }
+      return API.requestWithChallenge('POST', '/api/auth/magic-link', body);
     },
 
-    async verifyMagicLink(token) {
+    async verifyMagicLink(token, next, invite) {
       const params = new URLSearchParams({ token });
+      if (next) {
+        params.set('next', next);
+      }
+      if (invite) {
+        params.set('invite', invite);
+      }
       return API.request('GET', `/api/auth/magic-link/verify?${params.toString()}`);
     },
 
-    async forgotPassword(email) {
-      return API.request('POST', '/api/auth/forgot-password', { email });
+    async forgotPassword(email, next) {
+      return API.requestWithChallenge('POST', '/api/auth/forgot-password', { email, next });
     },
 
-    async resetPassword(token, password) {
-      return API.request('POST', '/api/auth/reset-password', { token, password });
+    async resetPassword(token, password, next) {
+      return API.request('POST', '/api/auth/reset-password', { token, password, next });
     },
   },
 
   notes: {
-    async list() {
-      return API.request('GET', '/api/notes');
+    // params: { limit, sort, order, cursor, archived, created_after, ... };
+    // pass the previous response's next_cursor as cursor to fetch the next
+    // page. archived: true lists only archived notes.
+    async list(params = {}) {
+      const query = new URLSearchParams();
+      Object.entries(params).forEach(([key, value]) => {
+        if (value !== undefined && value !== null && value !== '') {
+          query.set(key, value);
+        }
+      });
+      const qs = query.toString();
+      return API.request('GET', qs ? `/api/notes?${qs}` : '/api/notes');
+    },
+
+    async search(q, limit) {
+      const query = new URLSearchParams({ q });
+      if (limit) {
+        query.set('limit', limit);
+      }
+      return API.request('GET', `/api/notes/search?${query.toString()}`);
+    },
+
+    async create(title, body, tags, notebookId) {
+      return API.request('POST', '/api/notes', { title, body, tags, notebook_id: notebookId });
+    },
+
+    // tags replaces the note's tags and notebookId moves the note (null for
+    // the root); leave either undefined to keep the current value. With a
+    // version the update fails with 412 if the note changed since then.
+    async update(id, title, body, tags, notebookId, version) {
+      const headers = version ? { 'If-Match': `"${version}"` } : {};
+      return API.request('PUT', `/api/notes/${id}`, { title, body, tags, notebook_id: notebookId }, { headers });
     },
 
-    async create(title, body) {
-      return API.request('POST', '/api/notes', { title, body });
+    // fields is a JSON Merge Patch: only the fields to change, with null to
+    // clear tags or move the note to the root.
+    async patch(id, fields, version) {
+      const headers = { 'Content-Type': 'application/merge-patch+json' };
+      if (version) {
+        headers['If-Match'] = `"${version}"`;
+      }
+      return API.request('PATCH', `/api/notes/${id}`, fields, { headers });
+    },
+
+    // Pinned notes list first; archived notes are hidden from the default
+    // list. Owner only.
+    async setPinned(id, pinned) {
+      return API.request(pinned ? 'PUT' : 'DELETE', `/api/notes/${id}/pin`);
+    },
+
+    async setArchived(id, archived) {
+      return API.request(archived ? 'PUT' : 'DELETE', `/api/notes/${id}/archive`);
+    },
+
+    // operations: [{ op, note_ids, notebook_id, tags }] with op one of delete,
+    // move, tag, untag, archive or unarchive. With bestEffort the notes that
+    // can be changed are, and results says which failed.
+    async bulk(operations, bestEffort = false) {
+      return API.request('POST', '/api/notes/bulk', { mode: bestEffort ? 'best_effort' : 'atomic', operations });
+    },
+
+    // Imports Markdown, text, zip or .enex files. Large imports answer 202;
+    // follow them with importStatus.
+    async importNotes(files) {
+      const form = new FormData();
+      for (const file of files) {
+        form.append('file', file);
+      }
+      return API.request('POST', '/api/notes/import', form, { timeout: 300000 });
     },
 
-    async update(id, title, body) {
-      return API.request('PUT', `/api/notes/${id}`, { title, body });
+    async importStatus(importId) {
+      return API.request('GET', `/api/imports/${importId}`);
     },
 
+    // exportURL is for links and <a download>; format is 'zip' or 'json'.
+    exportURL(format = 'zip') {
+      return `/api/notes/export?format=${encodeURIComponent(format)}`;
+    },
+
+    // events opens the change feed. EventSource reconnects and resumes on its
+    // own; reload the notes on its 'ready' and 'reset' events.
+    events() {
+      return new EventSource('/api/notes/events');
+    },
+
+    // Moves the note to the trash.
     async remove(id) {
       return API.request('DELETE', `/api/notes/${id}`);
     },
+
+    // Notes other users shared with the current user.
+    async sharedWithMe() {
+      return API.request('GET', '/api/notes/shared');
+    },
+
+    async listShares(id) {
+      return API.request('GET', `/api/notes/${id}/shares`);
+    },
+
+    // recipient is an email address or username; permission is 'viewer' or
+    // 'editor'.
+    async share(id, recipient, permission) {
+      return API.request('POST', `/api/notes/${id}/shares`, { recipient, permission });
+    },
+
+    async revokeShare(id, userId) {
+      return API.request('DELETE', `/api/notes/${id}/shares/${userId}`);
+    },
+
+    async listLinks(id) {
+      return API.request('GET', `/api/notes/${id}/links`);
+    },
+
+    // expiresAt (ISO 8601) and password are optional. The response's
+    // link.url is only returned here.
+    async createLink(id, expiresAt, password) {
+      return API.request('POST', `/api/notes/${id}/links`, { expires_at: expiresAt || undefined, password: password || undefined });
+    },
+
+    async revokeLink(id, linkId) {
+      return API.request('DELETE', `/api/notes/${id}/links/${linkId}`);
+    },
+
+    async listAttachments(id) {
+      return API.request('GET', `/api/notes/${id}/attachments`);
+    },
+
+    // file is a File or Blob. Uploads get a longer timeout than other calls.
+    async uploadAttachment(id, file) {
+      const form = new FormData();
+      form.append('file', file);
+      return API.request('POST', `/api/notes/${id}/attachments`, form, { timeout: 300000 });
+    },
+
+    // attachmentURL is for links and <a download>; the browser sends the
+    // session cookie.
+    attachmentURL(id, attachmentId) {
+      return `/api/notes/${id}/attachments/${attachmentId}`;
+    },
+
+    async deleteAttachment(id, attachmentId) {
+      return API.request('DELETE', `/api/notes/${id}/attachments/${attachmentId}`);
+    },
+
+    async trash() {
+      return API.request('GET', '/api/notes/trash');
+    },
+
+    async restore(id) {
+      return API.request('POST', `/api/notes/${id}/restore`);
+    },
+
+    async emptyTrash() {
+      return API.request('DELETE', '/api/notes/trash');
+    },
+
+    async revisions(id) {
+      return API.request('GET', `/api/notes/${id}/revisions`);
+    },
+
+    // Omit to to compare against the latest revision.
+    async diffRevisions(id, from, to) {
+      const query = new URLSearchParams({ from });
+      if (to) {
+        query.set('to', to);
+      }
+      return API.request('GET', `/api/notes/${id}/revisions/diff?${query.toString()}`);
+    },
+
+    async restoreRevision(id, revision) {
+      return API.request('POST', `/api/notes/${id}/revisions/${revision}/restore`);
+    },
+  },
+
+  notebooks: {
+    async list() {
+      return API.request('GET', '/api/notebooks');
+    },
+
+    async create(name, parentId) {
+      return API.request('POST', '/api/notebooks', { name, parent_id: parentId || null });
+    },
+
+    async rename(id, name) {
+      return API.request('PUT', `/api/notebooks/${id}`, { name });
+    },
+
+    async move(id, parentId, position) {
+      return API.request('POST', `/api/notebooks/${id}/move`, { parent_id: parentId || null, position });
+    },
+
+    async remove(id, deleteNotes = false) {
+      return API.request('DELETE', `/api/notebooks/${id}?delete_notes=${deleteNotes}`);
+    },
+  },
+
+  tags: {
+    async list() {
+      return API.request('GET', '/api/tags');
+    },
+
+    async rename(id, name) {
+      return API.request('PUT', `/api/tags/${id}`, { name });
+    },
+
+    async merge(id, into) {
+      return API.request('POST', `/api/tags/${id}/merge`, { into });
+    },
+
+    async remove(id) {
+      return API.request('DELETE', `/api/tags/${id}`);
+    },
   },
 };
 
@@ -3,7 +3,14 @@
 const App = {
   user: null,
   notes: [],
+  notesCursor: null,
+  searchResults: null,
+  notesTag: null,
+  notebooks: [],
+  notesNotebook: '',
+  trash: null,
   editingNoteId: null,
+  editingNoteVersion: null,
   _lastHash: '',
 
   async init() {
@@ -52,6 +59,32 @@ const App = {
     return { route: route || 'home', params };
   },
 
+  // Navigates to a post-login target, defaulting to #app. The target may
+  // come straight from the URL, so it gets the same checks as the server's
+  // safeNext: a hash route or a same-origin path, and nothing a browser
+  // could read as another host.
+  goNext(next) {
+    if (typeof next !== 'string' || next.length > 2048 || /[\\\u0000-\u001f\u007f-\u009f]/.test(next)) {
+      window.location.hash = '#app';
+      return;
+    }
+    if (next.startsWith('#')) {
+      window.location.hash = next;
+      return;
+    }
+    if (next.startsWith('/') && !next.startsWith('//')) {
+      window.location.assign(next);
+      return;
+    }
+    window.location.hash = '#app';
+  },
+
+  withNext(hash, next) {
+    if (!next) return hash;
+    const separator = hash.includes('?') ? '&' : '?';
+    return `${hash}${separator}next=${encodeURIComponent(next)}`;
+  },
+
   async checkAuth() {
     try {
       const response = await API.auth.me();
@@ -100,6 +133,25 @@ const App = {
       event.preventDefault();
       this.handleActionSubmit(action, form);
     });
+
+    document.addEventListener('change', (event) => {
+      const field = event.target;
+      if (!field?.dataset?.change) return;
+      this.handleActionChange(field.dataset.change, field);
+    });
+  },
+
+  async handleActionChange(action, field) {
+    switch (action) {
+      case 'filter-notebook':
+        this.notesNotebook = field.value;
+        this.searchResults = null;
+        await this.loadNotes();
+        this.renderNotes();
+        break;
+      default:
+        break;
+    }
   },
 
   async handleActionClick(action, target) {
@@ -116,9 +168,37 @@ const App = {
       case 'delete-note':
         await this.deleteNote(target.dataset.noteId);
         break;
+      case 'note-history':
+        await this.toggleHistory(target.dataset.noteId, target.closest('.note-item'));
+        break;
+      case 'diff-revision':
+        await this.showRevisionDiff(target.dataset.noteId, target.dataset.revision, target.closest('.note-history'));
+        break;
+      case 'restore-revision':
+        await this.restoreRevision(target.dataset.noteId, target.dataset.revision);
+        break;
       case 'cancel-edit':
         this.clearNoteForm();
         break;
+      case 'clear-search':
+        this.clearSearch();
+        break;
+      case 'filter-tag':
+        await this.filterByTag(target.dataset.tag || null);
+        break;
+      case 'toggle-trash':
+        await this.toggleTrash();
+        break;
+      case 'restore-note':
+        await this.restoreNote(target.dataset.noteId);
+        break;
+      case 'empty-trash':
+        await this.emptyTrash();
+        break;
+      case 'load-more-notes':
+        await this.loadNotes({ append: true });
+        this.renderNotes();
+        break;
       default:
         break;
     }
@@ -129,6 +209,12 @@ const App = {
       case 'register':
         await this.register(form);
         break;
+      case 'search-notes':
+        await this.searchNotes(form);
+        break;
+      case 'create-notebook':
+        await this.createNotebook(form);
+        break;
       case 'login':
         await this.login(form);
         break;
@@ -150,12 +236,12 @@ const App = {
     const { route, params } = this.parseHash(window.location.hash);
 
     if (['app'].includes(route) && !this.user) {
-      window.location.hash = '#login';
+      window.location.hash = this.withNext('#login', window.location.hash);
       return;
     }
 
     if (['login', 'register'].includes(route) && this.user) {
-      window.location.hash = '#app';
+      this.goNext(params.next);
       return;
     }
 
@@ -164,10 +250,10 @@ const App = {
         this.renderHome();
         break;
       case 'login':
-        this.renderLogin();
+        this.renderLogin(params.next);
         break;
       case 'register':
-        this.renderRegister();
+        this.renderRegister(params.invite);
         break;
       case 'check-email':
         this.renderCheckEmail(params);
@@ -176,13 +262,13 @@ const App = {
         this.verifyEmail(params.token);
         break;
       case 'magic-link':
-        this.verifyMagicLink(params.token);
+        this.verifyMagicLink(params.token, params.next, params.invite);
         break;
       case 'forgot-password':
-        this.renderForgotPassword();
+        this.renderForgotPassword(params.next);
         break;
       case 'reset-password':
-        this.renderResetPassword(params.token);
+        this.renderResetPassword(params.token, params.next);
         break;
       case 'app':
         this.renderNotesApp();
@@ -219,7 +305,7 @@ const App = {
     `;
   },
 
-  renderLogin() {
+  renderLogin(next) {
     const container = this.qs('main-container');
     if (!container) return;
     container.innerHTML = `
@@ -228,6 +314,7 @@ const App = {
           <h2>Welcome back</h2>
           <p class="muted">Sign in with your password or request a magic link.</p>
           <form id="login-form" data-action="login">
+            <input type="hidden" id="next" name="next" value="${this.escapeHtml(next || '')}" />
             <label>Email
               <input type="email" id="email" name="email" required />
             </label>
@@ -238,7 +325,7 @@ const App = {
           </form>
           <div class="auth-links">
             <button class="button button-ghost" data-action="magic-link">Email me a magic link</button>
-            <a href="#forgot-password">Forgot password?</a>
+            <a href="${this.escapeHtml(this.withNext('#forgot-password', next))}">Forgot password?</a>
           </div>
         </div>
       </section>
@@ -253,7 +340,7 @@ const App = {
           return;
         }
         try {
-          await API.auth.magicLink(email);
+          await API.auth.magicLink(email, '', next);
           window.location.hash = `#check-email?type=magic-link&email=${encodeURIComponent(email)}`;
         } catch (error) {
           this.toast(error.message || 'Unable to send magic link.');
@@ -262,7 +349,7 @@ const App = {
     }
   },
 
-  renderRegister() {
+  renderRegister(invite) {
     const container = this.qs('main-container');
     if (!container) return;
     container.innerHTML = `
@@ -280,6 +367,9 @@ const App = {
             <label>Password
               <input type="password" id="password" name="password" required minlength="8" />
             </label>
+            <label>Invite code <span class="muted">(if you have one)</span>
+              <input type="text" id="invite_code" name="invite_code" autocomplete="off" value="${this.escapeHtml(invite || '')}" />
+            </label>
             <button class="button button-primary" type="submit">Create account</button>
           </form>
           <p class="auth-links">Already have an account? <a href="#login">Sign in</a></p>
@@ -351,7 +441,7 @@ const App = {
     }
   },
 
-  async verifyMagicLink(token) {
+  async verifyMagicLink(token, next, invite) {
     const container = this.qs('main-container');
     if (!container) return;
     if (!token) {
@@ -360,10 +450,10 @@ const App = {
     }
     container.innerHTML = '<div class="loading-state"><div class="spinner"></div><p>Signing you in...</p></div>';
     try {
-      const response = await API.auth.verifyMagicLink(token);
+      const response = await API.auth.verifyMagicLink(token, next, invite);
       this.user = response.user || null;
       this.renderNav();
-      window.location.hash = '#app';
+      this.goNext(response.next);
     } catch (error) {
       container.innerHTML = `
         <section class="auth">
@@ -377,7 +467,7 @@ const App = {
     }
   },
 
-  renderForgotPassword() {
+  renderForgotPassword(next) {
     const container = this.qs('main-container');
     if (!container) return;
     container.innerHTML = `
@@ -386,6 +476,7 @@ const App = {
           <h2>Reset your password</h2>
           <p class="muted">We will email you a link to reset your password.</p>
           <form id="forgot-password-form" data-action="forgot-password">
+            <input type="hidden" id="next" name="next" value="${this.escapeHtml(next || '')}" />
             <label>Email
               <input type="email" id="email" name="email" required />
             </label>
@@ -397,7 +488,7 @@ const App = {
     `;
   },
 
-  renderResetPassword(token) {
+  renderResetPassword(token, next) {
     const container = this.qs('main-container');
     if (!container) return;
     if (!token) {
@@ -411,6 +502,7 @@ const App = {
           <p class="muted">Choose a strong password to secure your account.</p>
           <form id="reset-password-form" data-action="reset-password">
             <input type="hidden" id="token" name="token" value="${this.escapeHtml(token)}" />
+            <input type="hidden" id="next" name="next" value="${this.escapeHtml(next || '')}" />
             <label>New password
               <input type="password" id="password" name="password" required minlength="8" />
             </label>
@@ -445,6 +537,12 @@ const App = {
               <label>Body
                 <textarea id="note-body" name="body" rows="6" required maxlength="5000"></textarea>
               </label>
+              <label>Tags
+                <input type="text" id="note-tags" name="tags" placeholder="work, ideas" />
+              </label>
+              <label>Notebook
+                <select id="note-notebook" name="notebook_id"></select>
+              </label>
               <div class="form-actions">
                 <button class="button button-primary" type="submit">${this.editingNoteId ? 'Save changes' : 'Add note'}</button>
                 <button class="button button-ghost" type="button" data-action="cancel-edit">Clear</button>
@@ -456,13 +554,34 @@ const App = {
               <h3>Recent notes</h3>
               <span class="muted" id="notes-count">0 notes</span>
             </div>
+            <form id="notes-search" class="notes-search" data-action="search-notes" role="search">
+              <input type="search" name="q" maxlength="200" placeholder='Search notes: words, "phrases", prefix*' aria-label="Search notes" />
+              <button class="button button-ghost" type="submit">Search</button>
+              <button class="button button-ghost" type="button" data-action="clear-search">Clear</button>
+            </form>
+            <div class="notes-toolbar">
+              <select id="notes-notebook" data-change="filter-notebook" aria-label="Notebook"></select>
+              <form class="notes-search" data-action="create-notebook">
+                <input type="text" name="name" maxlength="100" placeholder="New notebook" aria-label="New notebook name" required />
+                <button class="button button-ghost" type="submit">Add</button>
+              </form>
+            </div>
+            <div id="notes-filter" class="notes-filter" hidden></div>
             <div id="notes-list" class="notes-list"></div>
+            <button class="button button-ghost" type="button" id="notes-more" data-action="load-more-notes" hidden>Load more</button>
+            <div class="notes-list-header">
+              <button class="button button-ghost" type="button" data-action="toggle-trash">Trash</button>
+              <button class="button button-ghost" type="button" id="trash-empty" data-action="empty-trash" hidden>Empty trash</button>
+            </div>
+            <div id="notes-trash" class="notes-list" hidden></div>
           </div>
         </div>
       </section>
     `;
 
-    await this.loadNotes();
+    this.trash = null;
+    await Promise.all([this.loadNotes(), this.loadNotebooks()]);
+    this.renderNotebookSelects();
     this.renderNotes();
   },
 
@@ -485,9 +604,10 @@ const App = {
     const username = formData.get('username')?.toString().trim();
     const email = formData.get('email')?.toString().trim();
     const password = formData.get('password')?.toString();
+    const inviteCode = formData.get('invite_code')?.toString().trim();
 
     try {
-      const response = await API.auth.register(email, password, username);
+      const response = await API.auth.register(email, password, username, inviteCode);
       this.user = response.user || null;
       this.renderNav();
       window.location.hash = `#check-email?type=verification&email=${encodeURIComponent(email)}`;
@@ -500,12 +620,13 @@ const App = {
     const formData = new FormData(form);
     const email = formData.get('email')?.toString().trim();
     const password = formData.get('password')?.toString();
+    const next = formData.get('next')?.toString() || '';
 
     try {
-      const response = await API.auth.login(email, password);
+      const response = await API.auth.login(email, password, next);
       this.user = response.user || null;
       this.renderNav();
-      window.location.hash = '#app';
+      this.goNext(response.next);
     } catch (error) {
       this.toast(error.message || 'Unable to sign in.');
     }
@@ -519,6 +640,12 @@ const App = {
     }
     this.user = null;
     this.notes = [];
+    this.notesCursor = null;
+    this.searchResults = null;
+    this.notesTag = null;
+    this.notebooks = [];
+    this.notesNotebook = '';
+    this.trash = null;
     this.renderNav();
     window.location.hash = '#home';
   },
@@ -535,9 +662,10 @@ const App = {
   async forgotPassword(form) {
     const formData = new FormData(form);
     const email = formData.get('email')?.toString().trim();
+    const next = formData.get('next')?.toString() || '';
 
     try {
-      await API.auth.forgotPassword(email);
+      await API.auth.forgotPassword(email, next);
       window.location.hash = `#check-email?type=reset&email=${encodeURIComponent(email)}`;
     } catch (error) {
       this.toast(error.message || 'Unable to send reset email.');
@@ -548,43 +676,111 @@ const App = {
     const formData = new FormData(form);
     const token = formData.get('token')?.toString();
     const password = formData.get('password')?.toString();
+    const next = formData.get('next')?.toString() || '';
 
     try {
-      await API.auth.resetPassword(token, password);
+      const response = await API.auth.resetPassword(token, password, next);
       await this.checkAuth();
       this.renderNav();
-      window.location.hash = '#app';
+      this.goNext(response?.next);
     } catch (error) {
       this.toast(error.message || 'Unable to reset password.');
     }
   },
 
-  async loadNotes() {
+  async loadNotes({ append = false } = {}) {
     try {
-      const response = await API.notes.list();
-      this.notes = response.notes || [];
+      const params = { tag: this.notesTag, notebook_id: this.notesNotebook, format: 'html' };
+      if (append && this.notesCursor) params.cursor = this.notesCursor;
+      const response = await API.notes.list(params);
+      const notes = response.notes || [];
+      this.notes = append ? [...this.notes, ...notes] : notes;
+      this.notesCursor = response.next_cursor || null;
     } catch (error) {
       this.toast(error.message || 'Unable to load notes.');
     }
   },
 
+  async loadNotebooks() {
+    try {
+      const response = await API.notebooks.list();
+      this.notebooks = response.notebooks || [];
+    } catch (error) {
+      this.toast(error.message || 'Unable to load notebooks.');
+    }
+  },
+
+  // notebookTree returns notebooks depth-first with their nesting depth.
+  notebookTree() {
+    const children = new Map();
+    this.notebooks.forEach((notebook) => {
+      const key = notebook.parent_id || '';
+      if (!children.has(key)) children.set(key, []);
+      children.get(key).push(notebook);
+    });
+    const ordered = [];
+    const walk = (parentId, depth) => {
+      (children.get(parentId) || []).forEach((notebook) => {
+        ordered.push({ notebook, depth });
+        walk(notebook.id, depth + 1);
+      });
+    };
+    walk('', 0);
+    return ordered;
+  },
+
+  renderNotebookSelects() {
+    const fill = (select, first, selected) => {
+      if (!select) return;
+      select.innerHTML = '';
+      first.forEach(([value, label]) => select.appendChild(new Option(label, value)));
+      this.notebookTree().forEach(({ notebook, depth }) => {
+        select.appendChild(new Option(`${'\u00a0\u00a0'.repeat(depth)}${notebook.name}`, notebook.id));
+      });
+      select.value = selected;
+    };
+    fill(this.qs('notes-notebook'), [['', 'All notes'], ['root', 'Not in a notebook']], this.notesNotebook);
+    const current = this.qs('note-notebook')?.value || '';
+    fill(this.qs('note-notebook'), [['', 'No notebook']], current);
+  },
+
+  async createNotebook(form) {
+    const name = new FormData(form).get('name')?.toString().trim();
+    if (!name) return;
+    try {
+      const response = await API.notebooks.create(name);
+      this.notebooks = [...this.notebooks, response.notebook];
+      form.reset();
+      this.renderNotebookSelects();
+      this.toast('Notebook added.');
+    } catch (error) {
+      if (this.handleUnverified(error)) return;
+      this.toast(error.message || 'Unable to add notebook.');
+    }
+  },
+
   renderNotes() {
     const list = this.qs('notes-list');
     const count = this.qs('notes-count');
     if (!list || !count) return;
 
     list.innerHTML = '';
+    const searching = this.searchResults !== null;
+    const notes = searching ? this.searchResults : this.notes;
+    const more = this.qs('notes-more');
+    if (more) more.hidden = searching || !this.notesCursor;
+    this.renderTagFilter(searching);
 
-    if (this.notes.length === 0) {
+    if (notes.length === 0) {
       const empty = document.createElement('div');
       empty.className = 'empty-state';
-      empty.textContent = 'No notes yet. Write your first one.';
+      empty.textContent = searching ? 'No notes match your search.' : 'No notes yet. Write your first one.';
       list.appendChild(empty);
-      count.textContent = '0 notes';
+      count.textContent = searching ? '0 results' : '0 notes';
       return;
     }
 
-    this.notes.forEach((note) => {
+    notes.forEach((note) => {
       const item = document.createElement('div');
       item.className = 'note-item';
 
@@ -604,6 +800,13 @@ const App = {
       edit.dataset.noteId = note.id;
       edit.textContent = 'Edit';
 
+      const history = document.createElement('button');
+      history.className = 'button button-ghost';
+      history.type = 'button';
+      history.dataset.action = 'note-history';
+      history.dataset.noteId = note.id;
+      history.textContent = 'History';
+
       const del = document.createElement('button');
       del.className = 'button button-ghost';
       del.type = 'button';
@@ -612,31 +815,56 @@ const App = {
       del.textContent = 'Delete';
 
       actions.appendChild(edit);
+      actions.appendChild(history);
       actions.appendChild(del);
       header.appendChild(title);
       header.appendChild(actions);
 
-      const body = document.createElement('p');
-      body.textContent = note.body;
+      let body = document.createElement('p');
+      if (searching && note.snippet) {
+        // The server HTML-escapes snippets and only adds <mark> tags.
+        body.innerHTML = note.snippet;
+      } else if (note.rendered_html) {
+        // The server sanitizes rendered Markdown to an allowlist of tags.
+        body = document.createElement('div');
+        body.className = 'note-markdown';
+        body.innerHTML = note.rendered_html;
+      } else {
+        body.textContent = note.body;
+      }
 
       item.appendChild(header);
       item.appendChild(body);
+      if (note.tags?.length) {
+        item.appendChild(this.renderTagList(note.tags));
+      }
 
       list.appendChild(item);
     });
 
-    count.textContent = `${this.notes.length} ${this.notes.length === 1 ? 'note' : 'notes'}`;
+    if (searching) {
+      count.textContent = `${notes.length} ${notes.length === 1 ? 'result' : 'results'}`;
+      return;
+    }
+    const shown = `${this.notes.length}${this.notesCursor ? '+' : ''}`;
+    count.textContent = `${shown} ${this.notes.length === 1 && !this.notesCursor ? 'note' : 'notes'}`;
   },
 
   startEditingNote(noteId) {
-    const note = this.notes.find((item) => item.id === noteId);
+    const note = this.notes.find((item) => item.id === noteId)
+      || this.searchResults?.find((item) => item.id === noteId);
     if (!note) return;
     this.editingNoteId = note.id;
+    this.editingNoteVersion = note.version;
 
     const titleInput = this.qs('note-title');
     const bodyInput = this.qs('note-body');
     if (titleInput) titleInput.value = note.title;
     if (bodyInput) bodyInput.value = note.body;
+    const tagsInput = this.qs('note-tags');
+    if (tagsInput) tagsInput.value = (note.tags || []).join(', ');
+    const notebookInput = this.qs('note-notebook');
+    if (notebookInput) notebookInput.value = note.notebook_id || '';
 
     const form = this.qs('note-form');
     if (form) {
@@ -647,10 +875,15 @@ const App = {
 
   clearNoteForm() {
     this.editingNoteId = null;
+    this.editingNoteVersion = null;
     const titleInput = this.qs('note-title');
     const bodyInput = this.qs('note-body');
     if (titleInput) titleInput.value = '';
     if (bodyInput) bodyInput.value = '';
+    const tagsInput = this.qs('note-tags');
+    if (tagsInput) tagsInput.value = '';
+    const notebookInput = this.qs('note-notebook');
+    if (notebookInput) notebookInput.value = '';
     const form = this.qs('note-form');
     if (form) {
       const button = form.querySelector('button[type="submit"]');
@@ -662,6 +895,11 @@ const App = {
     const formData = new FormData(form);
     const title = formData.get('title')?.toString().trim();
     const body = formData.get('body')?.toString().trim();
+    const tags = (formData.get('tags')?.toString() || '')
+      .split(',')
+      .map((tag) => tag.trim())
+      .filter(Boolean);
+    const notebookId = formData.get('notebook_id')?.toString() || null;
 
     if (!title || !body) {
       this.toast('Title and body are required.');
@@ -670,34 +908,294 @@ const App = {
 
     try {
       if (this.editingNoteId) {
-        const response = await API.notes.update(this.editingNoteId, title, body);
+        const response = await API.notes.update(this.editingNoteId, title, body, tags, notebookId, this.editingNoteVersion);
         const updated = response.note;
         this.notes = this.notes.map((note) => (note.id === updated.id ? updated : note));
         this.toast('Note updated.');
       } else {
-        const response = await API.notes.create(title, body);
+        const response = await API.notes.create(title, body, tags, notebookId);
         this.notes = [response.note, ...this.notes];
         this.toast('Note added.');
       }
       this.clearNoteForm();
+      this.searchResults = null;
       this.renderNotes();
     } catch (error) {
+      if (this.handleUnverified(error)) return;
+      if (error.status === 412 && error.data?.note) {
+        // Keep the user's text in the form; saving again overwrites the newer version.
+        const current = error.data.note;
+        this.notes = this.notes.map((note) => (note.id === current.id ? current : note));
+        this.editingNoteVersion = current.version;
+        this.renderNotes();
+        this.toast('This note was changed elsewhere. Save again to overwrite it.');
+        return;
+      }
       this.toast(error.message || 'Unable to save note.');
     }
   },
 
+  renderTagList(tags) {
+    const list = document.createElement('div');
+    list.className = 'note-tags';
+    tags.forEach((tag) => {
+      const chip = document.createElement('button');
+      chip.type = 'button';
+      chip.className = 'tag';
+      chip.dataset.action = 'filter-tag';
+      chip.dataset.tag = tag;
+      chip.textContent = `#${tag}`;
+      list.appendChild(chip);
+    });
+    return list;
+  },
+
+  renderTagFilter(searching) {
+    const filter = this.qs('notes-filter');
+    if (!filter) return;
+    filter.innerHTML = '';
+    filter.hidden = searching || !this.notesTag;
+    if (filter.hidden) return;
+
+    const label = document.createElement('span');
+    label.className = 'muted';
+    label.textContent = `Tagged #${this.notesTag}`;
+    const clear = document.createElement('button');
+    clear.type = 'button';
+    clear.className = 'button button-ghost';
+    clear.dataset.action = 'filter-tag';
+    clear.textContent = 'Show all';
+    filter.appendChild(label);
+    filter.appendChild(clear);
+  },
+
+  async filterByTag(tag) {
+    this.notesTag = tag;
+    this.searchResults = null;
+    const input = document.querySelector('#notes-search input[name="q"]');
+    if (input) input.value = '';
+    await this.loadNotes();
+    this.renderNotes();
+  },
+
+  async searchNotes(form) {
+    const q = new FormData(form).get('q')?.toString().trim();
+    if (!q) {
+      this.clearSearch();
+      return;
+    }
+    try {
+      const response = await API.notes.search(q);
+      this.searchResults = response.results || [];
+      this.renderNotes();
+    } catch (error) {
+      this.toast(error.message || 'Unable to search notes.');
+    }
+  },
+
+  clearSearch() {
+    const input = document.querySelector('#notes-search input[name="q"]');
+    if (input) input.value = '';
+    this.searchResults = null;
+    this.renderNotes();
+  },
+
+  async toggleHistory(noteId, item) {
+    if (!noteId || !item) return;
+    const open = item.querySelector('.note-history');
+    if (open) {
+      open.remove();
+      return;
+    }
+    try {
+      const response = await API.notes.revisions(noteId);
+      const panel = document.createElement('div');
+      panel.className = 'note-history';
+      (response.revisions || []).forEach((revision, index) => {
+        const row = document.createElement('div');
+        row.className = 'note-history-row';
+        const label = document.createElement('span');
+        label.className = 'muted';
+        label.textContent = `#${revision.revision} · ${new Date(revision.created_at).toLocaleString()} · ${revision.title}`;
+        row.appendChild(label);
+
+        // The newest revision is the note as it stands.
+        if (index > 0) {
+          const actions = document.createElement('div');
+          actions.className = 'note-actions';
+          [['diff-revision', 'Compare'], ['restore-revision', 'Restore']].forEach(([action, text]) => {
+            const button = document.createElement('button');
+            button.className = 'button button-ghost';
+            button.type = 'button';
+            button.dataset.action = action;
+            button.dataset.noteId = noteId;
+            button.dataset.revision = revision.revision;
+            button.textContent = text;
+            actions.appendChild(button);
+          });
+          row.appendChild(actions);
+        }
+        panel.appendChild(row);
+      });
+      const diff = document.createElement('pre');
+      diff.className = 'note-diff';
+      diff.hidden = true;
+      panel.appendChild(diff);
+      item.appendChild(panel);
+    } catch (error) {
+      this.toast(error.message || 'Unable to load history.');
+    }
+  },
+
+  async showRevisionDiff(noteId, revision, panel) {
+    const output = panel?.querySelector('.note-diff');
+    if (!noteId || !output) return;
+    try {
+      const response = await API.notes.diffRevisions(noteId, revision);
+      const prefixes = { equal: '  ', insert: '+ ', delete: '- ' };
+      output.innerHTML = '';
+      response.diff.lines.forEach((line) => {
+        const span = document.createElement('span');
+        span.className = `diff-${line.op}`;
+        span.textContent = `${prefixes[line.op] || '  '}${line.text}\n`;
+        output.appendChild(span);
+      });
+      output.hidden = false;
+    } catch (error) {
+      this.toast(error.message || 'Unable to compare revisions.');
+    }
+  },
+
+  async restoreRevision(noteId, revision) {
+    if (!noteId || !revision) return;
+    try {
+      const response = await API.notes.restoreRevision(noteId, revision);
+      const restored = response.note;
+      this.notes = this.notes.map((note) => (note.id === restored.id ? restored : note));
+      this.searchResults = null;
+      this.renderNotes();
+      this.toast(`Restored revision ${revision}.`);
+    } catch (error) {
+      if (this.handleUnverified(error)) return;
+      this.toast(error.message || 'Unable to restore revision.');
+    }
+  },
+
+  async toggleTrash() {
+    const panel = this.qs('notes-trash');
+    if (!panel) return;
+    if (this.trash) {
+      this.trash = null;
+      this.renderTrash();
+      return;
+    }
+    await this.loadTrash();
+  },
+
+  async loadTrash() {
+    try {
+      const response = await API.notes.trash();
+      this.trash = response.notes || [];
+      this.renderTrash();
+    } catch (error) {
+      this.toast(error.message || 'Unable to load trash.');
+    }
+  },
+
+  renderTrash() {
+    const panel = this.qs('notes-trash');
+    const empty = this.qs('trash-empty');
+    if (!panel) return;
+    panel.innerHTML = '';
+    panel.hidden = !this.trash;
+    if (empty) empty.hidden = !this.trash?.length;
+    if (!this.trash) return;
+
+    if (this.trash.length === 0) {
+      const state = document.createElement('div');
+      state.className = 'empty-state';
+      state.textContent = 'Trash is empty.';
+      panel.appendChild(state);
+      return;
+    }
+
+    this.trash.forEach((note) => {
+      const item = document.createElement('div');
+      item.className = 'note-item';
+      const header = document.createElement('div');
+      header.className = 'note-header';
+      const title = document.createElement('h4');
+      title.textContent = note.title;
+      const restore = document.createElement('button');
+      restore.className = 'button button-ghost';
+      restore.type = 'button';
+      restore.dataset.action = 'restore-note';
+      restore.dataset.noteId = note.id;
+      restore.textContent = 'Restore';
+      header.appendChild(title);
+      header.appendChild(restore);
+
+      const meta = document.createElement('p');
+      meta.textContent = `Deleted ${new Date(note.deleted_at).toLocaleString()}`;
+      item.appendChild(header);
+      item.appendChild(meta);
+      panel.appendChild(item);
+    });
+  },
+
+  async restoreNote(noteId) {
+    if (!noteId) return;
+    try {
+      const response = await API.notes.restore(noteId);
+      this.trash = (this.trash || []).filter((note) => note.id !== noteId);
+      this.notes = [response.note, ...this.notes.filter((note) => note.id !== noteId)];
+      this.renderTrash();
+      this.renderNotes();
+      this.toast('Note restored.');
+    } catch (error) {
+      this.toast(error.message || 'Unable to restore note.');
+    }
+  },
+
+  async emptyTrash() {
+    if (!window.confirm('Permanently delete every note in the trash?')) return;
+    try {
+      const response = await API.notes.emptyTrash();
+      this.trash = [];
+      this.renderTrash();
+      this.toast(`Deleted ${response.deleted} ${response.deleted === 1 ? 'note' : 'notes'} permanently.`);
+    } catch (error) {
+      this.toast(error.message || 'Unable to empty trash.');
+    }
+  },
+
   async deleteNote(noteId) {
     if (!noteId) return;
     try {
       await API.notes.remove(noteId);
       this.notes = this.notes.filter((note) => note.id !== noteId);
+      if (this.searchResults) {
+        this.searchResults = this.searchResults.filter((note) => note.id !== noteId);
+      }
       this.renderNotes();
-      this.toast('Note deleted.');
+      if (this.trash) await this.loadTrash();
+      this.toast('Note moved to trash.');
     } catch (error) {
       this.toast(error.message || 'Unable to delete note.');
     }
   },
 
+  // handleUnverified sends users blocked by RequireVerified to the resend flow.
+  handleUnverified(error) {
+    if (error?.data?.code !== 'email_unverified') {
+      return false;
+    }
+    this.toast(error.message);
+    const email = error.data.email || this.user?.email || '';
+    window.location.hash = `#check-email?type=verification&email=${encodeURIComponent(email)}`;
+    return true;
+  },
+
   toast(message) {
     const container = this.qs('toast-container');
     if (!container) return;
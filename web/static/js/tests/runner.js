@@ -2,6 +2,7 @@
 
 const path = require('path');
 
+require(path.join(__dirname, '../challenge.js'));
 require(path.join(__dirname, '../api.js'));
 require(path.join(__dirname, '../app.js'));
 
@@ -61,6 +62,43 @@ describe('parseHash', () => {
   });
 });
 
+describe('withNext', () => {
+  test('returns hash unchanged without next', () => {
+    expect(App.withNext('#login', '')).toBe('#login');
+  });
+
+  test('appends encoded next', () => {
+    expect(App.withNext('#login', '#app?note=1')).toBe('#login?next=%23app%3Fnote%3D1');
+  });
+
+  test('round-trips through parseHash', () => {
+    expect(App.parseHash(App.withNext('#login', '#app?note=1'))).toEqual({
+      route: 'login',
+      params: { next: '#app?note=1' },
+    });
+  });
+});
+
+describe('Challenge', () => {
+  test('sha256 matches known digest', () => {
+    expect(Challenge.toHex(Challenge.sha256('abc'))).toBe(
+      'ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad',
+    );
+  });
+
+  test('solve finds a solution with enough zero bits', () => {
+    const token = '8.1700000000.00112233445566778899aabbccddeeff.sig';
+    const solution = Challenge.solve(token, 8);
+    expect(Challenge.leadingZeroBits(Challenge.sha256(`${token}:${solution}`)) >= 8).toBe(true);
+  });
+});
+
+describe('handleUnverified', () => {
+  test('ignores other errors', () => {
+    expect(App.handleUnverified({ data: { code: 'reauth_required' } })).toBe(false);
+  });
+});
+
 describe('API Client Structure', () => {
   test('auth namespace exists', () => {
     expect(typeof API.auth).toBe('object');